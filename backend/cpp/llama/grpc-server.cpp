@@ -34,6 +34,8 @@
 #include <grpcpp/health_check_service_interface.h>
 #include <atomic>
 #include <signal.h>
+#include <cmath>
+#include <algorithm>
 
 using grpc::Server;
 using grpc::ServerBuilder;
@@ -160,6 +162,37 @@ static void add_rpc_devices(std::string servers) {
     }
 }
 
+// fill the logprobs of a reply from the completion probabilities of a result
+static void set_reply_logprobs(backend::Reply *reply, const json &result, int top_logprobs)
+{
+    if (!result.contains("completion_probabilities"))
+    {
+        return;
+    }
+    for (const auto &prob : result.at("completion_probabilities"))
+    {
+        backend::Logprob *logprob = reply->add_logprobs();
+        const std::string token = prob.value("content", "");
+        logprob->set_token(token);
+        logprob->set_bytes(token);
+        logprob->set_logprob(prob.value("logprob", 0.0));
+
+        int n = 0;
+        for (const auto &candidate : prob.at("probs"))
+        {
+            if (n++ >= top_logprobs)
+            {
+                break;
+            }
+            const std::string tok_str = candidate.value("tok_str", "");
+            backend::TopLogprob *top = logprob->add_top_logprobs();
+            top->set_token(tok_str);
+            top->set_bytes(tok_str);
+            top->set_logprob(candidate.value("logprob", 0.0));
+        }
+    }
+}
+
 // convert a vector of completion_token_output to json
 static json probs_vector_to_json(const llama_context *ctx, const std::vector<completion_token_output> &probs)
 {
@@ -174,11 +207,13 @@ static json probs_vector_to_json(const llama_context *ctx, const std::vector<com
             {
                 {"tok_str", tok_str},
                 {"prob",    p.prob},
+                {"logprob", p.logprob},
             });
         }
         std::string tok_str = tokens_to_output_formatted_string(ctx, prob.tok);
         out.push_back(json{
             {"content", tok_str},
+            {"logprob", prob.logprob},
             {"probs",   probs_for_token},
         });
     }
@@ -1057,6 +1092,43 @@ struct llama_server_context
         return stop_pos;
     }
 
+    // set the logprob of the sampled token and the n_probs most likely tokens, from the logits of the model
+    // before the sampling, as the sampled token might have been dropped from the candidates by the samplers
+    void set_token_probabilities(completion_token_output &result, int idx, size_t n_probs)
+    {
+        const float *logits = llama_get_logits_ith(ctx, idx);
+        const int n_vocab = llama_vocab_n_tokens(vocab);
+
+        float max_logit = logits[0];
+        for (int i = 1; i < n_vocab; i++)
+        {
+            max_logit = std::max(max_logit, logits[i]);
+        }
+        double sum = 0.0;
+        for (int i = 0; i < n_vocab; i++)
+        {
+            sum += std::exp(logits[i] - max_logit);
+        }
+        // the log-sum-exp keeps the logprobs of the unlikely tokens finite
+        const float log_sum = max_logit + std::log(sum);
+
+        std::vector<llama_token_data> cur(n_vocab);
+        for (llama_token id = 0; id < n_vocab; id++)
+        {
+            cur[id] = llama_token_data{id, logits[id], std::exp(logits[id] - log_sum)};
+        }
+        result.logprob = logits[result.tok] - log_sum;
+
+        n_probs = std::min(n_probs, cur.size());
+        std::partial_sort(cur.begin(), cur.begin() + n_probs, cur.end(), [](const llama_token_data &a, const llama_token_data &b) {
+            return a.logit > b.logit;
+        });
+        for (size_t i = 0; i < n_probs; i++)
+        {
+            result.probs.push_back({cur[i].id, cur[i].p, cur[i].logit - log_sum});
+        }
+    }
+
     bool process_token(completion_token_output &result, llama_client_slot &slot) {
         // remember which tokens were sampled - used for repetition penalties during sampling
         const std::string token_str = common_token_to_piece(ctx, result.tok);
@@ -2003,13 +2075,9 @@ struct llama_server_context
                 }
 
                 result.tok = id;
-                const auto * cur_p = common_sampler_get_candidates(slot.ctx_sampling);
-
-                for (size_t i = 0; i < (size_t) slot.sparams.n_probs; ++i) {
-                    result.probs.push_back({
-                        cur_p->data[i].id,
-                        i >= cur_p->size ? 0.0f : cur_p->data[i].p,
-                    });
+                if (slot.sparams.n_probs > 0)
+                {
+                    set_token_probabilities(result, slot.i_batch - i, slot.sparams.n_probs);
                 }
 
                 if (!process_token(result, slot))
@@ -2173,7 +2241,10 @@ json parse_options(bool streaming, const backend::PredictOptions* predict, llama
     }
 
     data["stop"] = predict->stopprompts();
-    // data["n_probs"] = predict->nprobs();
+    // the probabilities of the sampled token are needed also without alternatives
+    if (predict->logprobs()) {
+        data["n_probs"] = std::max(predict->toplogprobs(), 1);
+    }
     //TODO: images,
 
     return data;
@@ -2440,6 +2511,11 @@ public:
                     double timing_token_generation = result.result_json.at("timings").value("predicted_ms", 0.0);
                     reply.set_timing_token_generation(timing_token_generation);
                 }
+
+                // The final result repeats the probabilities of all the tokens already streamed
+                if (request->logprobs() && !result.stop) {
+                    set_reply_logprobs(&reply, result.result_json, request->toplogprobs());
+                }
                 
                 // Log Request Correlation Id
                 LOG_VERBOSE("correlation:", {
@@ -2488,6 +2564,10 @@ public:
                 double timing_token_generation = result.result_json.at("timings").value("predicted_ms", 0.0);
                 reply->set_timing_token_generation(timing_token_generation);
             }
+
+            if (request->logprobs()) {
+                set_reply_logprobs(reply, result.result_json, request->toplogprobs());
+            }
         }
         else
         {
@@ -127,10 +127,13 @@ struct completion_token_output
     {
         llama_token tok;
         float prob;
+        float logprob;
     };
 
     std::vector<token_prob> probs;
     llama_token tok;
+    // logprob of the sampled token, which is not necessarily among the most likely ones
+    float logprob = 0.0f;
     std::string text_to_send;
 };
 
@@ -5,6 +5,8 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
 	"github.com/go-audio/wav"
@@ -74,6 +76,15 @@ func (sd *Whisper) AudioTranscription(opts *pb.TranscriptRequest) (pb.Transcript
 		context.SetTranslate(true)
 	}
 
+	if opts.WordTimestamps {
+		context.SetTokenTimestamps(true)
+	}
+
+	if opts.Prompt != "" {
+		context.SetInitialPrompt(opts.Prompt)
+	}
+	// Note: the temperature is not exposed by the whisper.cpp bindings, so the default one is used
+
 	if err := context.Process(data, nil, nil); err != nil {
 		return pb.TranscriptResult{}, err
 	}
@@ -87,11 +98,23 @@ func (sd *Whisper) AudioTranscription(opts *pb.TranscriptRequest) (pb.Transcript
 		}
 
 		var tokens []int32
+		var words []*pb.TranscriptWord
 		for _, t := range s.Tokens {
 			tokens = append(tokens, int32(t.Id))
+
+			if !opts.WordTimestamps || !context.IsText(t) {
+				continue
+			}
+			// Tokens starting with a space begin a new word, the others continue the current one
+			if len(words) == 0 || strings.HasPrefix(t.Text, " ") {
+				words = append(words, &pb.TranscriptWord{Word: strings.TrimSpace(t.Text), Start: int64(t.Start), End: int64(t.End)})
+			} else {
+				words[len(words)-1].Word += t.Text
+				words[len(words)-1].End = int64(t.End)
+			}
 		}
 
-		segment := &pb.TranscriptSegment{Id: int32(s.Num), Text: s.Text, Start: int64(s.Start), End: int64(s.End), Tokens: tokens}
+		segment := &pb.TranscriptSegment{Id: int32(s.Num), Text: s.Text, Start: int64(s.Start), End: int64(s.End), Tokens: tokens, Words: words}
 		segments = append(segments, segment)
 
 		text += s.Text
@@ -100,6 +123,8 @@ func (sd *Whisper) AudioTranscription(opts *pb.TranscriptRequest) (pb.Transcript
 	return pb.TranscriptResult{
 		Segments: segments,
 		Text:     text,
+		Language: opts.Language,
+		Duration: int64(time.Duration(len(data)) * time.Second / time.Duration(whisper.SampleRate)),
 	}, nil
 
 }
@@ -1,6 +1,8 @@
 package application
 
 import (
+	"sync/atomic"
+
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/templates"
@@ -11,6 +13,12 @@ type Application struct {
 	modelLoader        *model.ModelLoader
 	applicationConfig  *config.ApplicationConfig
 	templatesEvaluator *templates.Evaluator
+
+	draining atomic.Bool
+	// stopped is closed once the backends are stopped, after the application context is canceled
+	stopped chan struct{}
+
+	preload preload
 }
 
 func newApplication(appConfig *config.ApplicationConfig) *Application {
@@ -19,6 +27,8 @@ func newApplication(appConfig *config.ApplicationConfig) *Application {
 		modelLoader:        model.NewModelLoader(appConfig.ModelPath),
 		applicationConfig:  appConfig,
 		templatesEvaluator: templates.NewEvaluator(appConfig.ModelPath),
+		stopped:            make(chan struct{}),
+		preload:            preload{done: make(chan struct{})},
 	}
 }
 
@@ -37,3 +47,18 @@ func (a *Application) ApplicationConfig() *config.ApplicationConfig {
 func (a *Application) TemplatesEvaluator() *templates.Evaluator {
 	return a.templatesEvaluator
 }
+
+// Drain marks the application as shutting down: /readyz reports it is not ready anymore, so the load balancers
+// stop sending it new requests. The requests are still served until the listener is closed.
+func (a *Application) Drain() {
+	a.draining.Store(true)
+}
+
+func (a *Application) Draining() bool {
+	return a.draining.Load()
+}
+
+// Stopped is closed once the backends are stopped, after the context of the application is canceled
+func (a *Application) Stopped() <-chan struct{} {
+	return a.stopped
+}
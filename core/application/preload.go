@@ -0,0 +1,76 @@
+package application
+
+import (
+	"sync"
+
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/rs/zerolog/log"
+)
+
+// preload tracks the models loaded at startup (LoadToMemory)
+type preload struct {
+	sync.Mutex
+	models []schema.ModelPreload
+	// done is closed once all the models are loaded, or failed loading
+	done chan struct{}
+}
+
+// Preload returns the state of the models loaded at startup
+func (a *Application) Preload() []schema.ModelPreload {
+	a.preload.Lock()
+	defer a.preload.Unlock()
+	return append([]schema.ModelPreload{}, a.preload.models...)
+}
+
+// Preloaded is closed once the models to load at startup are loaded, or failed loading
+func (a *Application) Preloaded() <-chan struct{} {
+	return a.preload.done
+}
+
+func (a *Application) setPreload(i int, state string, err error) {
+	a.preload.Lock()
+	defer a.preload.Unlock()
+	a.preload.models[i].State = state
+	if err != nil {
+		a.preload.models[i].Error = err.Error()
+	}
+}
+
+// preloadModels loads the models in memory in the background, so the API can report its readiness meanwhile
+func (a *Application) preloadModels(options *config.ApplicationConfig) {
+	for _, m := range options.LoadToMemory {
+		a.preload.models = append(a.preload.models, schema.ModelPreload{Model: m, State: schema.PreloadPending})
+	}
+
+	go func() {
+		defer close(a.preload.done)
+
+		for i, m := range options.LoadToMemory {
+			cfg, err := a.BackendLoader().LoadBackendConfigFileByName(m, options.ModelPath,
+				config.LoadOptionDebug(options.Debug),
+				config.LoadOptionThreads(options.Threads),
+				config.LoadOptionContextSize(options.ContextSize),
+				config.LoadOptionF16(options.F16),
+				config.ModelPath(options.ModelPath),
+			)
+			if err != nil {
+				log.Error().Err(err).Str("model", m).Msg("error loading the model configuration")
+				a.setPreload(i, schema.PreloadFailed, err)
+				continue
+			}
+
+			log.Debug().Msgf("Auto loading model %s into memory from file: %s", m, cfg.Model)
+
+			o := backend.ModelOptions(*cfg, options)
+
+			if _, err := a.ModelLoader().Load(o...); err != nil {
+				log.Error().Err(err).Str("model", m).Msg("error loading the model into memory")
+				a.setPreload(i, schema.PreloadFailed, err)
+				continue
+			}
+			a.setPreload(i, schema.PreloadLoaded, nil)
+		}
+	}()
+}
@@ -0,0 +1,164 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/grpc"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// healthCheckTimeout is how long the backends have to answer their health check
+var healthCheckTimeout = 5 * time.Second
+
+// Readiness reports whether the application can serve requests: it is not shutting down and the models to
+// load at startup are loaded. When models are given, they must be ready too.
+func (a *Application) Readiness(models []string) schema.ReadinessResponse {
+	resp := schema.ReadinessResponse{
+		Draining: a.Draining(),
+		Preload:  a.Preload(),
+	}
+	resp.Ready = !resp.Draining
+	for _, p := range resp.Preload {
+		if p.State != schema.PreloadLoaded {
+			resp.Ready = false
+		}
+	}
+
+	for _, m := range models {
+		r := a.modelReadiness(m, resp.Preload)
+		resp.Ready = resp.Ready && r.Ready
+		resp.Models = append(resp.Models, r)
+	}
+	return resp
+}
+
+// modelReadiness checks that the model is configured, that its files are in the models path, and that
+// its backend, if running, is healthy. A model which is not loaded is ready, unless it is loaded at startup.
+func (a *Application) modelReadiness(name string, preload []schema.ModelPreload) schema.ModelReadiness {
+	r := schema.ModelReadiness{Model: name}
+
+	cfg, exists := a.backendLoader.GetBackendConfig(name)
+	if !exists {
+		r.Error = fmt.Sprintf("model %s is not configured", name)
+		return r
+	}
+	r.ConfigLoaded = true
+	r.MissingFiles = a.missingFiles(cfg)
+
+	i := slices.IndexFunc(preload, func(p schema.ModelPreload) bool { return p.Model == name })
+	if i >= 0 {
+		r.Preload = preload[i].State
+		if r.Preload == schema.PreloadFailed {
+			r.Error = "the model failed loading at startup"
+		}
+	}
+
+	// the backends which crashed are restarted by the next request, unless they crashed too many times
+	crashed := false
+	if err := a.modelLoader.Available(cfg.Name); errors.Is(err, model.ErrBackendRestarting) {
+		r.Error = model.ErrBackendRestarting.Error()
+	} else if errors.Is(err, model.ErrBackendFailed) {
+		r.Error = model.ErrBackendFailed.Error()
+		crashed = true
+	}
+
+	for _, m := range a.modelLoader.ListModels() {
+		if m.ID != cfg.Name {
+			continue
+		}
+		r.Loaded = true
+		// the backends started outside of LocalAI have no process to check
+		if p := m.Process(); p != nil && !p.IsAlive() {
+			r.Error = "the backend is not running"
+			break
+		}
+		r.Healthy = backendHealthy(m.GRPC(false, nil))
+		if !r.Healthy {
+			r.Error = "the backend doesn't answer its health check"
+		}
+	}
+
+	r.Ready = len(r.MissingFiles) == 0 &&
+		!crashed &&
+		(!r.Loaded || r.Healthy) &&
+		(r.Preload == "" || r.Preload == schema.PreloadLoaded)
+	return r
+}
+
+// backendHealthy calls the health check of a backend. A busy backend is healthy: the health check waits
+// for the requests being served, so it is not waited for past the timeout if a request started meanwhile.
+func backendHealthy(client grpc.Backend) bool {
+	if client.IsBusy() {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	result := make(chan bool, 1)
+	go func() {
+		healthy, _ := client.HealthCheck(ctx)
+		result <- healthy
+	}()
+
+	// the health check itself returns once the timeout expires, it is still waiting afterwards only
+	// if it didn't start because of the request being served
+	timer := time.NewTimer(healthCheckTimeout + time.Second)
+	defer timer.Stop()
+	select {
+	case healthy := <-result:
+		return healthy
+	case <-timer.C:
+		return true
+	}
+}
+
+// missingFiles returns the files of the model which are not in the models path. The model itself is a file
+// only for the backends shipped with LocalAI: the other ones (e.g. transformers) can download it by name.
+func (a *Application) missingFiles(cfg config.BackendConfig) []string {
+	files := []string{}
+	for _, f := range cfg.DownloadFiles {
+		files = append(files, f.Filename)
+	}
+	if cfg.MMProj != "" {
+		files = append(files, cfg.MMProj)
+	}
+	if cfg.Model != "" && a.loadsModelFile(cfg.Backend) {
+		files = append(files, cfg.Model)
+	}
+
+	var missing []string
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(a.applicationConfig.ModelPath, f)); err != nil && !slices.Contains(missing, f) {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
+
+// loadsModelFile returns true if the backend loads the model from a file of the models path
+func (a *Application) loadsModelFile(backend string) bool {
+	if alias, exists := model.Aliases[backend]; exists {
+		backend = alias
+	}
+	switch backend {
+	case "":
+		// the backends shipped with LocalAI are tried
+		return true
+	case model.LCHuggingFaceBackend, model.LocalStoreBackend:
+		return false
+	}
+	if strings.HasPrefix(backend, model.LLamaCPP) {
+		return true
+	}
+	backends, err := a.modelLoader.ListAvailableBackends(a.applicationConfig.AssetsDestination)
+	return err == nil && slices.Contains(backends, backend)
+}
@@ -0,0 +1,103 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadiness(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "present.yaml"), []byte("name: present\nbackend: llama-cpp\nparameters:\n  model: present.gguf\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "present.gguf"), nil, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "missing.yaml"), []byte("name: missing\nbackend: llama-cpp\nmmproj: mmproj.gguf\nparameters:\n  model: missing.gguf\n"), 0600))
+	// the transformers backend downloads the model by name
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "remote.yaml"), []byte("name: remote\nbackend: transformers\nparameters:\n  model: org/model\n"), 0600))
+
+	app := newApplication(config.NewApplicationConfig(config.WithModelPath(dir)))
+	require.NoError(t, app.BackendLoader().LoadBackendConfigsFromPath(dir))
+
+	resp := app.Readiness(nil)
+	require.True(t, resp.Ready)
+
+	resp = app.Readiness([]string{"present", "remote"})
+	require.True(t, resp.Ready)
+	require.Equal(t, []schema.ModelReadiness{
+		{Model: "present", Ready: true, ConfigLoaded: true},
+		{Model: "remote", Ready: true, ConfigLoaded: true},
+	}, resp.Models)
+
+	resp = app.Readiness([]string{"present", "missing", "unknown"})
+	require.False(t, resp.Ready)
+	require.Equal(t, []string{"mmproj.gguf", "missing.gguf"}, resp.Models[1].MissingFiles)
+	require.False(t, resp.Models[1].Ready)
+	require.False(t, resp.Models[2].ConfigLoaded)
+	require.False(t, resp.Models[2].Ready)
+
+	// the models loaded at startup must be loaded
+	app.preload.models = []schema.ModelPreload{{Model: "present", State: schema.PreloadPending}}
+	resp = app.Readiness([]string{"present"})
+	require.False(t, resp.Ready)
+	require.Equal(t, schema.PreloadPending, resp.Models[0].Preload)
+
+	// the errors are not returned, as they can hold the details of the host
+	app.setPreload(0, schema.PreloadFailed, errors.New("open /models/present.gguf: permission denied"))
+	resp = app.Readiness([]string{"present"})
+	require.False(t, resp.Ready)
+	require.Equal(t, "the model failed loading at startup", resp.Models[0].Error)
+	data, err := json.Marshal(resp)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "permission denied")
+
+	app.setPreload(0, schema.PreloadLoaded, nil)
+	require.True(t, app.Readiness(nil).Ready)
+
+	app.Drain()
+	resp = app.Readiness(nil)
+	require.False(t, resp.Ready)
+	require.True(t, resp.Draining)
+}
+
+func TestReadinessUnresponsiveBackend(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hung.yaml"), []byte("name: hung\nbackend: transformers\nparameters:\n  model: org/model\n"), 0600))
+
+	// the backend accepts the connections, and never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	app := newApplication(config.NewApplicationConfig(config.WithModelPath(dir)))
+	require.NoError(t, app.BackendLoader().LoadBackendConfigsFromPath(dir))
+	_, err = app.ModelLoader().LoadModel("hung", "org/model", func(modelID, modelName, modelFile string) (*model.Model, error) {
+		return model.NewModel(modelID, listener.Addr().String(), nil), nil
+	})
+	require.NoError(t, err)
+
+	defer func(timeout time.Duration) { healthCheckTimeout = timeout }(healthCheckTimeout)
+	healthCheckTimeout = 500 * time.Millisecond
+
+	resp := app.Readiness([]string{"hung"})
+	require.False(t, resp.Ready)
+	require.True(t, resp.Models[0].Loaded)
+	require.False(t, resp.Models[0].Healthy)
+	require.Equal(t, "the backend doesn't answer its health check", resp.Models[0].Error)
+}
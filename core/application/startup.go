@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/internal"
@@ -125,8 +124,17 @@ func New(opts ...config.AppOption) (*Application, error) {
 		if err != nil {
 			log.Error().Err(err).Msg("error while stopping all grpc backends")
 		}
+		close(application.stopped)
 	}()
 
+	if options.ShutdownGracePeriod > 0 {
+		// the backends are stopped above, once the in-flight requests are completed
+		application.ModelLoader().DisableStopOnSignal()
+	}
+
+	application.ModelLoader().SetMemoryBudget(options.MemoryBudget)
+	application.ModelLoader().SetRestartPolicy(options.BackendMaxCrashes, options.BackendRestartBackoff)
+
 	if options.WatchDog {
 		wd := model.NewWatchDog(
 			application.ModelLoader(),
@@ -143,30 +151,7 @@ func New(opts ...config.AppOption) (*Application, error) {
 		}()
 	}
 
-	if options.LoadToMemory != nil {
-		for _, m := range options.LoadToMemory {
-			cfg, err := application.BackendLoader().LoadBackendConfigFileByName(m, options.ModelPath,
-				config.LoadOptionDebug(options.Debug),
-				config.LoadOptionThreads(options.Threads),
-				config.LoadOptionContextSize(options.ContextSize),
-				config.LoadOptionF16(options.F16),
-				config.ModelPath(options.ModelPath),
-			)
-			if err != nil {
-				return nil, err
-			}
-
-			log.Debug().Msgf("Auto loading model %s into memory from file: %s", m, cfg.Model)
-
-			o := backend.ModelOptions(*cfg, options)
-
-			var backendErr error
-			_, backendErr = application.ModelLoader().Load(o...)
-			if backendErr != nil {
-				return nil, err
-			}
-		}
-	}
+	application.preloadModels(options)
 
 	// Watch the configuration directory
 	startWatcher(options)
@@ -24,6 +24,7 @@ import (
 type LLMResponse struct {
 	Response string // should this be []byte?
 	Usage    TokenUsage
+	Logprobs []schema.LogprobContent
 }
 
 type TokenUsage struct {
@@ -33,7 +34,7 @@ type TokenUsage struct {
 	TimingTokenGeneration  float64
 }
 
-func ModelInference(ctx context.Context, s string, messages []schema.Message, images, videos, audios []string, loader *model.ModelLoader, c config.BackendConfig, o *config.ApplicationConfig, tokenCallback func(string, TokenUsage) bool) (func() (LLMResponse, error), error) {
+func ModelInference(ctx context.Context, s string, messages []schema.Message, images, videos, audios []string, loader *model.ModelLoader, c config.BackendConfig, o *config.ApplicationConfig, tokenCallback func(string, TokenUsage, []schema.LogprobContent) bool) (func() (LLMResponse, error), error) {
 	modelFile := c.Model
 
 	// Check if the modelFile exists, if it doesn't try to load it from the gallery
@@ -93,13 +94,15 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 		opts.Audios = audios
 
 		tokenUsage := TokenUsage{}
+		// the callback is wrapped on each call, so predictions can run concurrently
+		tokenCallback := tokenCallback
 
 		// check the per-model feature flag for usage, since tokenCallback may have a cost.
 		// Defaults to off as for now it is still experimental
 		if c.FeatureFlag.Enabled("usage") {
 			userTokenCallback := tokenCallback
 			if userTokenCallback == nil {
-				userTokenCallback = func(token string, usage TokenUsage) bool {
+				userTokenCallback = func(token string, usage TokenUsage, logprobs []schema.LogprobContent) bool {
 					return true
 				}
 			}
@@ -109,20 +112,27 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 				tokenUsage.Prompt = int(promptInfo.Length)
 			}
 
-			tokenCallback = func(token string, usage TokenUsage) bool {
+			tokenCallback = func(token string, usage TokenUsage, logprobs []schema.LogprobContent) bool {
 				tokenUsage.Completion++
-				return userTokenCallback(token, tokenUsage)
+				return userTokenCallback(token, tokenUsage, logprobs)
 			}
 		}
 
 		if tokenCallback != nil {
 			ss := ""
+			logprobs := []schema.LogprobContent{}
 
 			var partialRune []byte
+			// logprobs are sent along with the first rune decoded after they are received
+			var pendingLogprobs []schema.LogprobContent
 			err := inferenceModel.PredictStream(ctx, opts, func(reply *proto.Reply) {
 				msg := reply.Message
 				partialRune = append(partialRune, msg...)
 
+				replyLogprobs := toLogprobs(reply.Logprobs)
+				logprobs = append(logprobs, replyLogprobs...)
+				pendingLogprobs = append(pendingLogprobs, replyLogprobs...)
+
 				tokenUsage.Prompt = int(reply.PromptTokens)
 				tokenUsage.Completion = int(reply.Tokens)
 				tokenUsage.TimingTokenGeneration = reply.TimingTokenGeneration
@@ -135,19 +145,22 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 						break
 					}
 
-					tokenCallback(string(r), tokenUsage)
+					tokenCallback(string(r), tokenUsage, pendingLogprobs)
+					pendingLogprobs = nil
 					ss += string(r)
 
 					partialRune = partialRune[size:]
 				}
 
 				if len(msg) == 0 {
-					tokenCallback("", tokenUsage)
+					tokenCallback("", tokenUsage, pendingLogprobs)
+					pendingLogprobs = nil
 				}
 			})
 			return LLMResponse{
 				Response: ss,
 				Usage:    tokenUsage,
+				Logprobs: logprobs,
 			}, err
 		} else {
 			// TODO: Is the chicken bit the only way to get here? is that acceptable?
@@ -168,6 +181,7 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 			return LLMResponse{
 				Response: string(reply.Message),
 				Usage:    tokenUsage,
+				Logprobs: toLogprobs(reply.Logprobs),
 			}, err
 		}
 	}
@@ -175,6 +189,41 @@ func ModelInference(ctx context.Context, s string, messages []schema.Message, im
 	return fn, nil
 }
 
+// toLogprobs converts the logprobs returned by the backends to their OpenAI representation
+func toLogprobs(logprobs []*proto.Logprob) []schema.LogprobContent {
+	if len(logprobs) == 0 {
+		return nil
+	}
+
+	result := make([]schema.LogprobContent, 0, len(logprobs))
+	for _, l := range logprobs {
+		content := schema.LogprobContent{
+			Token:       l.Token,
+			Logprob:     l.Logprob,
+			Bytes:       toIntBytes(l.Bytes),
+			TopLogprobs: []schema.TopLogprob{},
+		}
+		for _, t := range l.TopLogprobs {
+			content.TopLogprobs = append(content.TopLogprobs, schema.TopLogprob{
+				Token:   t.Token,
+				Logprob: t.Logprob,
+				Bytes:   toIntBytes(t.Bytes),
+			})
+		}
+		result = append(result, content)
+	}
+	return result
+}
+
+// toIntBytes returns the bytes as integers, as in the OpenAI API (a []byte would be encoded as base64)
+func toIntBytes(b []byte) []int {
+	result := make([]int, len(b))
+	for i := range b {
+		result[i] = int(b[i])
+	}
+	return result
+}
+
 var cutstrings map[string]*regexp.Regexp = make(map[string]*regexp.Regexp)
 var mu sync.Mutex = sync.Mutex{}
 
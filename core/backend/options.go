@@ -4,18 +4,25 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/mudler/LocalAI/core/config"
 	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
 	"github.com/mudler/LocalAI/pkg/model"
 	"github.com/rs/zerolog/log"
 )
 
-func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...model.Option) []model.Option {
-	name := c.Name
-	if name == "" {
-		name = c.Model
+// ModelID returns the ID of the model in the model loader
+func ModelID(c config.BackendConfig) string {
+	if c.Name == "" {
+		return c.Model
 	}
+	return c.Name
+}
+
+func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...model.Option) []model.Option {
+	name := ModelID(c)
 
 	defOpts := []model.Option{
 		model.WithBackendString(c.Backend),
@@ -44,6 +51,11 @@ func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...
 		defOpts = append(defOpts, model.WithSingleActiveBackend())
 	}
 
+	// any name can be requested, the failures of the models without a configuration are not tracked
+	if !c.IsConfigured() {
+		defOpts = append(defOpts, model.WithoutCrashTracking())
+	}
+
 	if so.ParallelBackendRequests {
 		defOpts = append(defOpts, model.EnableParallelRequests)
 	}
@@ -56,6 +68,47 @@ func ModelOptions(c config.BackendConfig, so *config.ApplicationConfig, opts ...
 		defOpts = append(defOpts, model.WithGRPCAttemptsDelay(c.GRPC.AttemptsSleepTime))
 	}
 
+	if c.Queue.MaxConcurrency > 0 {
+		queue := model.QueueOptions{
+			MaxConcurrency: c.Queue.MaxConcurrency,
+			MaxLength:      c.Queue.MaxLength,
+		}
+		if c.Queue.Timeout != "" {
+			timeout, err := time.ParseDuration(c.Queue.Timeout)
+			if err != nil {
+				log.Warn().Err(err).Str("model", name).Msg("invalid queue timeout, requests will wait without limit")
+			}
+			queue.Timeout = timeout
+		}
+		defOpts = append(defOpts, model.WithQueue(queue))
+	}
+
+	if c.Pinned {
+		defOpts = append(defOpts, model.WithPinned())
+	}
+
+	limits := model.ProcessLimits{
+		Nice:         c.Limits.Nice,
+		MaxOpenFiles: c.Limits.MaxOpenFiles,
+	}
+	if c.Limits.Memory != "" {
+		memory, err := humanize.ParseBytes(c.Limits.Memory)
+		if err != nil {
+			log.Warn().Err(err).Str("model", name).Msg("invalid memory limit, the memory of the backend is not limited")
+		}
+		limits.Memory = memory
+	}
+	if c.Limits.CPUs != "" {
+		cpus, err := model.ParseCPUSet(c.Limits.CPUs)
+		if err != nil {
+			log.Warn().Err(err).Str("model", name).Msg("invalid cpus limit, the backend can run on any CPU")
+		}
+		limits.CPUs = cpus
+	}
+	if !limits.IsZero() {
+		defOpts = append(defOpts, model.WithProcessLimits(limits))
+	}
+
 	for k, v := range so.ExternalGRPCBackends {
 		defOpts = append(defOpts, model.WithExternalBackend(k, v))
 	}
@@ -231,5 +284,7 @@ func gRPCPredictOpts(c config.BackendConfig, modelPath string) *pb.PredictOption
 		TensorSplit:         c.TensorSplit,
 		TailFreeSamplingZ:   float32(*c.TFZ),
 		TypicalP:            float32(*c.TypicalP),
+		Logprobs:            c.Logprobs,
+		TopLogprobs:         int32(c.TopLogprobs),
 	}
 }
@@ -12,7 +12,7 @@ import (
 	"github.com/mudler/LocalAI/pkg/model"
 )
 
-func ModelTranscription(audio, language string, translate bool, ml *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (*schema.TranscriptionResult, error) {
+func ModelTranscription(audio, language string, translate, wordTimestamps bool, prompt string, temperature float32, ml *model.ModelLoader, backendConfig config.BackendConfig, appConfig *config.ApplicationConfig) (*schema.TranscriptionResult, error) {
 
 	if backendConfig.Backend == "" {
 		backendConfig.Backend = model.WhisperBackend
@@ -30,22 +30,35 @@ func ModelTranscription(audio, language string, translate bool, ml *model.ModelL
 	}
 
 	r, err := transcriptionModel.AudioTranscription(context.Background(), &proto.TranscriptRequest{
-		Dst:       audio,
-		Language:  language,
-		Translate: translate,
-		Threads:   uint32(*backendConfig.Threads),
+		Dst:            audio,
+		Language:       language,
+		Translate:      translate,
+		Threads:        uint32(*backendConfig.Threads),
+		WordTimestamps: wordTimestamps,
+		Prompt:         prompt,
+		Temperature:    temperature,
 	})
 	if err != nil {
 		return nil, err
 	}
 	tr := &schema.TranscriptionResult{
-		Text: r.Text,
+		Text:     r.Text,
+		Language: r.Language,
+		Duration: time.Duration(r.Duration),
 	}
 	for _, s := range r.Segments {
 		var tks []int
 		for _, t := range s.Tokens {
 			tks = append(tks, int(t))
 		}
+		var words []schema.Word
+		for _, w := range s.Words {
+			words = append(words, schema.Word{
+				Word:  w.Word,
+				Start: time.Duration(w.Start),
+				End:   time.Duration(w.End),
+			})
+		}
 		tr.Segments = append(tr.Segments,
 			schema.Segment{
 				Text:   s.Text,
@@ -53,6 +66,7 @@ func ModelTranscription(audio, language string, translate bool, ml *model.ModelL
 				Start:  time.Duration(s.Start),
 				End:    time.Duration(s.End),
 				Tokens: tks,
+				Words:  words,
 			})
 	}
 	return tr, err
@@ -0,0 +1,18 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+func VAD(ctx context.Context, request *proto.VADRequest, ml *model.ModelLoader, appConfig *config.ApplicationConfig, backendConfig config.BackendConfig) (*proto.VADResponse, error) {
+	opts := ModelOptions(backendConfig, appConfig, model.WithBackendString(backendConfig.Backend), model.WithModel(backendConfig.Model))
+	vadModel, err := ml.Load(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return vadModel.VAD(ctx, request)
+}
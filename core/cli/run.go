@@ -3,15 +3,21 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/mudler/LocalAI/core/application"
 	cli_api "github.com/mudler/LocalAI/core/cli/api"
 	cliContext "github.com/mudler/LocalAI/core/cli/context"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http"
 	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/mudler/LocalAI/core/schema"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -48,6 +54,7 @@ type RunCMD struct {
 	CSRF                               bool     `env:"LOCALAI_CSRF" help:"Enables fiber CSRF middleware" group:"api"`
 	UploadLimit                        int      `env:"LOCALAI_UPLOAD_LIMIT,UPLOAD_LIMIT" default:"15" help:"Default upload-limit in MB" group:"api"`
 	APIKeys                            []string `env:"LOCALAI_API_KEY,API_KEY" help:"List of API Keys to enable API authentication. When this is set, all the requests must be authenticated with one of these API keys" group:"api"`
+	APIKeyPriorities                   []string `env:"LOCALAI_API_KEY_PRIORITIES,API_KEY_PRIORITIES" help:"List of API Keys with their priority in the model queues, in the form key=priority (requests with a higher priority are served first)" group:"api"`
 	DisableWebUI                       bool     `env:"LOCALAI_DISABLE_WEBUI,DISABLE_WEBUI" default:"false" help:"Disable webui" group:"api"`
 	DisablePredownloadScan             bool     `env:"LOCALAI_DISABLE_PREDOWNLOAD_SCAN" help:"If true, disables the best-effort security scanner before downloading any files." group:"hardening" default:"false"`
 	OpaqueErrors                       bool     `env:"LOCALAI_OPAQUE_ERRORS" default:"false" help:"If true, all error responses are replaced with blank 500 errors. This is intended only for hardening against information leaks and is normally not recommended." group:"hardening"`
@@ -62,6 +69,9 @@ type RunCMD struct {
 	Peer2PeerNetworkID                 string   `env:"LOCALAI_P2P_NETWORK_ID,P2P_NETWORK_ID" help:"Network ID for P2P mode, can be set arbitrarly by the user for grouping a set of instances" group:"p2p"`
 	ParallelRequests                   bool     `env:"LOCALAI_PARALLEL_REQUESTS,PARALLEL_REQUESTS" help:"Enable backends to handle multiple requests in parallel if they support it (e.g.: llama.cpp or vllm)" group:"backends"`
 	SingleActiveBackend                bool     `env:"LOCALAI_SINGLE_ACTIVE_BACKEND,SINGLE_ACTIVE_BACKEND" help:"Allow only one backend to be run at a time" group:"backends"`
+	MemoryBudget                       string   `env:"LOCALAI_MEMORY_BUDGET,MEMORY_BUDGET" help:"RAM that the loaded models can use (e.g. 48GB), the VRAM is not considered. When loading a model would exceed it, the least recently used idle models are stopped" group:"backends"`
+	BackendMaxCrashes                  int      `env:"LOCALAI_BACKEND_MAX_CRASHES,BACKEND_MAX_CRASHES" default:"5" help:"Number of crashes after which a backend is not restarted anymore, until it is reset with /backend/reset (0 to always restart it)" group:"backends"`
+	BackendRestartBackoff              string   `env:"LOCALAI_BACKEND_RESTART_BACKOFF,BACKEND_RESTART_BACKOFF" default:"2s" help:"Delay before restarting a backend which crashed, doubled at each crash" group:"backends"`
 	PreloadBackendOnly                 bool     `env:"LOCALAI_PRELOAD_BACKEND_ONLY,PRELOAD_BACKEND_ONLY" default:"false" help:"Do not launch the API services, only the preloaded models / backends are started (useful for multi-node setups)" group:"backends"`
 	ExternalGRPCBackends               []string `env:"LOCALAI_EXTERNAL_GRPC_BACKENDS,EXTERNAL_GRPC_BACKENDS" help:"A list of external grpc backends" group:"backends"`
 	EnableWatchdogIdle                 bool     `env:"LOCALAI_WATCHDOG_IDLE,WATCHDOG_IDLE" default:"false" help:"Enable watchdog for stopping backends that are idle longer than the watchdog-idle-timeout" group:"backends"`
@@ -71,6 +81,8 @@ type RunCMD struct {
 	Federated                          bool     `env:"LOCALAI_FEDERATED,FEDERATED" help:"Enable federated instance" group:"federated"`
 	DisableGalleryEndpoint             bool     `env:"LOCALAI_DISABLE_GALLERY_ENDPOINT,DISABLE_GALLERY_ENDPOINT" help:"Disable the gallery endpoints" group:"api"`
 	MachineTag                         string   `env:"LOCALAI_MACHINE_TAG,MACHINE_TAG" help:"Add Machine-Tag header to each response which is useful to track the machine in the P2P network" group:"api"`
+	ShutdownGracePeriod                string   `env:"LOCALAI_SHUTDOWN_GRACE_PERIOD,SHUTDOWN_GRACE_PERIOD" default:"30s" help:"On SIGINT or SIGTERM, time given to the in-flight requests to complete before stopping the backends (0 to stop right away)" group:"api"`
+	ShutdownDelay                      string   `env:"LOCALAI_SHUTDOWN_DELAY,SHUTDOWN_DELAY" default:"5s" help:"On SIGINT or SIGTERM, time during which the new requests are still accepted while /readyz reports not ready, before the listener is closed" group:"api"`
 	LoadToMemory                       []string `env:"LOCALAI_LOAD_TO_MEMORY,LOAD_TO_MEMORY" help:"A list of models to load into memory at startup" group:"models"`
 }
 
@@ -175,6 +187,45 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 	if r.SingleActiveBackend {
 		opts = append(opts, config.EnableSingleBackend)
 	}
+	restartBackoff, err := time.ParseDuration(r.BackendRestartBackoff)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, config.SetBackendRestartPolicy(r.BackendMaxCrashes, restartBackoff))
+	shutdownGracePeriod, err := time.ParseDuration(r.ShutdownGracePeriod)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, config.WithShutdownGracePeriod(shutdownGracePeriod))
+	shutdownDelay, err := time.ParseDuration(r.ShutdownDelay)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, config.WithShutdownDelay(shutdownDelay))
+	// canceled once the in-flight requests are completed, to stop the backends
+	appCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts = append(opts, config.WithContext(appCtx))
+	if r.MemoryBudget != "" {
+		budget, err := humanize.ParseBytes(r.MemoryBudget)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, config.SetMemoryBudget(budget))
+	}
+
+	// split the last "=" to get the API key and its priority
+	for _, v := range r.APIKeyPriorities {
+		i := strings.LastIndexByte(v, '=')
+		if i < 0 {
+			return fmt.Errorf("invalid API key priority %q, expected key=priority", v)
+		}
+		priority, err := strconv.Atoi(v[i+1:])
+		if err != nil {
+			return fmt.Errorf("invalid API key priority %q: %w", v, err)
+		}
+		opts = append(opts, config.WithApiKeyPriority(v[:i], priority))
+	}
 
 	// split ":" to get backend name and the uri
 	for _, v := range r.ExternalGRPCBackends {
@@ -188,8 +239,17 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 	}
 
 	if r.PreloadBackendOnly {
-		_, err := application.New(opts...)
-		return err
+		app, err := application.New(opts...)
+		if err != nil {
+			return err
+		}
+		<-app.Preloaded()
+		for _, p := range app.Preload() {
+			if p.State == schema.PreloadFailed {
+				return fmt.Errorf("failed loading model %s: %s", p.Model, p.Error)
+			}
+		}
+		return nil
 	}
 
 	app, err := application.New(opts...)
@@ -203,5 +263,41 @@ func (r *RunCMD) Run(ctx *cliContext.Context) error {
 		return err
 	}
 
-	return appHTTP.Listen(r.Address)
+	if shutdownGracePeriod == 0 {
+		return appHTTP.Listen(r.Address)
+	}
+
+	// Drain the requests on SIGINT or SIGTERM, instead of exiting right away
+	signal.Reset(os.Interrupt, syscall.SIGTERM)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	drained := make(chan struct{})
+	go func() {
+		<-signals
+		go func() {
+			<-signals
+			log.Warn().Msg("Shutting down right away")
+			os.Exit(1)
+		}()
+
+		// the load balancers need to see /readyz failing before the listener is closed
+		app.Drain()
+		if shutdownDelay > 0 {
+			log.Info().Msgf("Shutting down, accepting the requests for %s more while not ready", shutdownDelay)
+			time.Sleep(shutdownDelay)
+		}
+		log.Info().Msgf("Shutting down, waiting up to %s for the in-flight requests to complete", shutdownGracePeriod)
+		if err := appHTTP.ShutdownWithTimeout(shutdownGracePeriod); err != nil {
+			log.Warn().Err(err).Msg("Interrupting the requests still in-flight")
+		}
+		cancel()
+		<-app.Stopped()
+		close(drained)
+	}()
+
+	if err := appHTTP.Listen(r.Address); err != nil {
+		return err
+	}
+	<-drained
+	return nil
 }
@@ -31,6 +31,7 @@ type ApplicationConfig struct {
 	PreloadModelsFromPath               string
 	CORSAllowOrigins                    string
 	ApiKeys                             []string
+	ApiKeyPriorities                    map[string]int
 	P2PToken                            string
 	P2PNetworkID                        string
 
@@ -58,6 +59,14 @@ type ApplicationConfig struct {
 	SingleBackend           bool
 	ParallelBackendRequests bool
 
+	MemoryBudget uint64
+
+	BackendMaxCrashes     int
+	BackendRestartBackoff time.Duration
+
+	ShutdownGracePeriod time.Duration
+	ShutdownDelay       time.Duration
+
 	WatchDogIdle bool
 	WatchDogBusy bool
 	WatchDog     bool
@@ -176,6 +185,37 @@ var EnableSingleBackend = func(o *ApplicationConfig) {
 	o.SingleBackend = true
 }
 
+func SetMemoryBudget(budget uint64) AppOption {
+	return func(o *ApplicationConfig) {
+		o.MemoryBudget = budget
+	}
+}
+
+// SetBackendRestartPolicy sets the backoff between the restarts of the backends which crash,
+// and after how many crashes they are not restarted anymore
+func SetBackendRestartPolicy(maxCrashes int, backoff time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.BackendMaxCrashes = maxCrashes
+		o.BackendRestartBackoff = backoff
+	}
+}
+
+// WithShutdownGracePeriod sets how long the in-flight requests can take to complete when shutting down,
+// before the backends are stopped
+func WithShutdownGracePeriod(gracePeriod time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ShutdownGracePeriod = gracePeriod
+	}
+}
+
+// WithShutdownDelay sets how long the requests are still accepted when shutting down, while /readyz reports
+// LocalAI is not ready, so the load balancers can stop sending new requests before the listener is closed
+func WithShutdownDelay(delay time.Duration) AppOption {
+	return func(o *ApplicationConfig) {
+		o.ShutdownDelay = delay
+	}
+}
+
 var EnableParallelBackendRequests = func(o *ApplicationConfig) {
 	o.ParallelBackendRequests = true
 }
@@ -329,6 +369,16 @@ func WithApiKeys(apiKeys []string) AppOption {
 	}
 }
 
+// WithApiKeyPriority sets the priority in the model queues of the requests using the API key
+func WithApiKeyPriority(apiKey string, priority int) AppOption {
+	return func(o *ApplicationConfig) {
+		if o.ApiKeyPriorities == nil {
+			o.ApiKeyPriorities = make(map[string]int)
+		}
+		o.ApiKeyPriorities[apiKey] = priority
+	}
+}
+
 func WithEnforcedPredownloadScans(enforced bool) AppOption {
 	return func(o *ApplicationConfig) {
 		o.EnforcePredownloadScans = enforced
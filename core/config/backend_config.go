@@ -28,6 +28,18 @@ type BackendConfig struct {
 	schema.PredictionOptions `yaml:"parameters"`
 	Name                     string `yaml:"name"`
 
+	// Targets makes the model a virtual model, served by the first healthy model of the list
+	Targets []string `yaml:"targets"`
+	// Variants split the requests between models by weight, the model served is picked before the targets
+	Variants []Variant `yaml:"variants"`
+	// Shadow mirrors part of the requests to another model, whose responses are only logged
+	Shadow Shadow `yaml:"shadow"`
+
+	// Pinned keeps the model loaded, it is neither stopped by the watchdog nor evicted to load other models
+	Pinned bool `yaml:"pinned"`
+	// Limits caps the resources used by the backend process of the model
+	Limits Limits `yaml:"limits"`
+
 	F16                 *bool                  `yaml:"f16"`
 	Threads             *int                   `yaml:"threads"`
 	Debug               *bool                  `yaml:"debug"`
@@ -43,9 +55,16 @@ type BackendConfig struct {
 	functionCallString, functionCallNameString string                 `yaml:"-"`
 	ResponseFormat                             string                 `yaml:"-"`
 	ResponseFormatMap                          map[string]interface{} `yaml:"-"`
+	Logprobs                                   bool                   `yaml:"-"`
+	TopLogprobs                                int                    `yaml:"-"`
+	// unconfigured is true for the models without a configuration, requested by the name of their file
+	unconfigured bool
 
 	FunctionsConfig functions.FunctionsConfig `yaml:"function"`
 
+	// StructuredOutput configures the validation of the outputs requested with a response_format
+	StructuredOutput StructuredOutput `yaml:"structured_output"`
+
 	FeatureFlag FeatureFlag `yaml:"feature_flags"` // Feature Flag registry. We move fast, and features may break on a per model/backend basis. Registry for (usually temporary) flags that indicate aborting something early.
 	// LLM configs (GPT4ALL, Llama.cpp, ...)
 	LLMConfig `yaml:",inline"`
@@ -60,9 +79,15 @@ type BackendConfig struct {
 	// GRPC Options
 	GRPC GRPC `yaml:"grpc"`
 
+	// Queue limits the requests served concurrently by the model
+	Queue Queue `yaml:"queue"`
+
 	// TTS specifics
 	TTSConfig `yaml:"tts"`
 
+	// Pipeline of models used by the realtime API
+	Pipeline Pipeline `yaml:"pipeline"`
+
 	// CUDA
 	// Explicitly enable CUDA or not (some backends might need it)
 	CUDA bool `yaml:"cuda"`
@@ -88,6 +113,66 @@ func (ff FeatureFlag) Enabled(s string) bool {
 	return exist && v != nil && *v
 }
 
+// Pipeline defines the models used to process a realtime conversation turn:
+// voice activity detection, transcription, the reply of the LLM and the text-to-speech
+type Pipeline struct {
+	VAD           string `yaml:"vad"`
+	Transcription string `yaml:"transcription"`
+	LLM           string `yaml:"llm"`
+	TTS           string `yaml:"tts"`
+}
+
+// IsEmpty returns true if no model is defined in the pipeline
+func (p Pipeline) IsEmpty() bool {
+	return p.VAD == "" && p.Transcription == "" && p.LLM == "" && p.TTS == ""
+}
+
+// StructuredOutput defines how the outputs requested with a JSON schema are validated.
+// The outputs of strict requests are always validated.
+type StructuredOutput struct {
+	// Validate checks the outputs even if the request is not strict
+	Validate bool `yaml:"validate"`
+	// Retries is the number of times an invalid output is generated again
+	Retries int `yaml:"retries"`
+	// Repair sends the invalid output and the validation error back to the model on retries, asking to fix it
+	Repair bool `yaml:"repair"`
+}
+
+// Queue defines the queue in front of the model: requests exceeding the concurrency limit wait
+// for their turn by priority, and are rejected when too many requests are waiting.
+type Queue struct {
+	// MaxConcurrency is the number of requests served at the same time, 0 disables the queue
+	MaxConcurrency int `yaml:"max_concurrency"`
+	// MaxLength is the number of requests that can wait, 0 means no limit
+	MaxLength int `yaml:"max_length"`
+	// Timeout is how long a request can wait (e.g. 30s), empty means no limit
+	Timeout string `yaml:"timeout"`
+}
+
+// Limits caps the resources of the backend process, a runaway backend can't starve the other ones
+type Limits struct {
+	// Memory is the memory the backend can use (e.g. 8GB), empty means no limit
+	Memory string `yaml:"memory"`
+	// CPUs are the CPUs the backend can run on, as a cpuset (e.g. 0-3,8)
+	CPUs string `yaml:"cpus"`
+	// Nice is the nice level of the backend, from -20 to 19
+	Nice int `yaml:"nice"`
+	// MaxOpenFiles is the number of files the backend can open, 0 means no limit
+	MaxOpenFiles uint64 `yaml:"max_open_files"`
+}
+
+// Variant is a model serving a share of the requests of a virtual model
+type Variant struct {
+	Model  string `yaml:"model"`
+	Weight int    `yaml:"weight"`
+}
+
+type Shadow struct {
+	Model string `yaml:"model"`
+	// Percent is the percentage of the requests mirrored to the model
+	Percent float64 `yaml:"percent"`
+}
+
 type GRPC struct {
 	Attempts          int `yaml:"attempts"`
 	AttemptsSleepTime int `yaml:"attempts_sleep_time"`
@@ -188,6 +273,10 @@ type TemplateConfig struct {
 	// Edit is the template used for edit completion requests
 	Edit string `yaml:"edit"`
 
+	// FIM is the template used for fill-in-the-middle completion requests, when a suffix is given.
+	// The prefix is available as .Input and the suffix as .Suffix
+	FIM string `yaml:"fim"`
+
 	// Functions is the template used when tools are present in the client requests
 	Functions string `yaml:"function"`
 
@@ -216,6 +305,11 @@ func (c *BackendConfig) UnmarshalYAML(value *yaml.Node) error {
 	return nil
 }
 
+// IsConfigured returns false for the models without a configuration, requested by the name of their file
+func (c *BackendConfig) IsConfigured() bool {
+	return !c.unconfigured
+}
+
 func (c *BackendConfig) SetFunctionCallString(s string) {
 	c.functionCallString = s
 }
@@ -232,6 +326,11 @@ func (c *BackendConfig) ShouldCallSpecificFunction() bool {
 	return len(c.functionCallNameString) > 0
 }
 
+// ShouldCallAnyFunction returns true if the request requires the LLM to call at least a function
+func (c *BackendConfig) ShouldCallAnyFunction() bool {
+	return c.functionCallString == "required"
+}
+
 // MMProjFileName returns the filename of the MMProj file
 // If the MMProj is a URL, it will return the MD5 of the URL which is the filename
 func (c *BackendConfig) MMProjFileName() string {
@@ -272,6 +371,11 @@ func (c *BackendConfig) FunctionToCall() string {
 		return c.functionCallNameString
 	}
 
+	switch c.functionCallString {
+	case "none", "auto", "required":
+		// these select the calling mode, not a function
+		return ""
+	}
 	return c.functionCallString
 }
 
@@ -137,6 +137,8 @@ func (bcl *BackendConfigLoader) LoadBackendConfigFileByName(modelName, modelPath
 			if exists {
 				cfg = &cfgExisting
 			}
+		} else {
+			cfg.unconfigured = true
 		}
 	}
 
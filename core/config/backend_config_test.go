@@ -4,6 +4,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -65,6 +66,21 @@ parameters:
 			Expect(config.Name).To(Equal("hermes-2-pro-mistral"))
 			Expect(config.Validate()).To(BeTrue())
 		})
+		It("Tells the models without a configuration", func() {
+			dir, err := os.MkdirTemp("", "models")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			Expect(os.WriteFile(filepath.Join(dir, "foo.yaml"), []byte("name: foo\nparameters:\n  model: foo.gguf\n"), 0600)).To(Succeed())
+
+			bcl := NewBackendConfigLoader(dir)
+			config, err := bcl.LoadBackendConfigFileByName("foo", dir)
+			Expect(err).To(BeNil())
+			Expect(config.IsConfigured()).To(BeTrue())
+
+			config, err = bcl.LoadBackendConfigFileByName("bar.gguf", dir)
+			Expect(err).To(BeNil())
+			Expect(config.IsConfigured()).To(BeFalse())
+		})
 	})
 	It("Properly handles backend usecase matching", func() {
 
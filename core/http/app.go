@@ -9,6 +9,7 @@ import (
 	"github.com/dave-gray101/v2keyauth"
 	"github.com/mudler/LocalAI/pkg/utils"
 
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/http/endpoints/openai"
 	"github.com/mudler/LocalAI/core/http/middleware"
@@ -62,15 +63,14 @@ func API(application *application.Application) (*fiber.App, error) {
 	if !application.ApplicationConfig().OpaqueErrors {
 		// Normally, return errors as JSON responses
 		fiberCfg.ErrorHandler = func(ctx *fiber.Ctx, err error) error {
-			// Status code defaults to 500
-			code := fiber.StatusInternalServerError
-
-			// Retrieve the custom status code if it's a *fiber.Error
-			var e *fiber.Error
-			if errors.As(err, &e) {
-				code = e.Code
+			// The handler already sent the error in the format of its API
+			var respErr *fiberContext.ResponseError
+			if errors.As(err, &respErr) {
+				return nil
 			}
 
+			code := fiberContext.ErrorStatus(err)
+
 			// Send custom error page
 			return ctx.Status(code).JSON(
 				schema.ErrorResponse{
@@ -133,7 +133,7 @@ func API(application *application.Application) (*fiber.App, error) {
 
 	}
 	// Health Checks should always be exempt from auth, so register these first
-	routes.HealthRoutes(router)
+	routes.HealthRoutes(router, application)
 
 	kaConfig, err := middleware.GetKeyAuthConfig(application.ApplicationConfig())
 	if err != nil || kaConfig == nil {
@@ -159,10 +159,19 @@ func API(application *application.Application) (*fiber.App, error) {
 		router.Use(csrf.New())
 	}
 
+	routes.ModelReadinessRoutes(router, application)
+
 	// Load config jsons
 	utils.LoadConfig(application.ApplicationConfig().UploadDir, openai.UploadedFilesFile, &openai.UploadedFiles)
 	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.AssistantsConfigFile, &openai.Assistants)
 	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.AssistantsFileConfigFile, &openai.AssistantFiles)
+	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.ThreadsConfigFile, &openai.Threads)
+	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.ThreadMessagesConfigFile, &openai.ThreadMessages)
+	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.RunsConfigFile, &openai.Runs)
+	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.RunStepsConfigFile, &openai.RunSteps)
+	openai.RecoverRuns(application.ApplicationConfig())
+	utils.LoadConfig(application.ApplicationConfig().ConfigsDir, openai.BatchesConfigFile, &openai.Batches)
+	openai.RecoverBatches(application.ApplicationConfig())
 
 	galleryService := services.NewGalleryService(application.ApplicationConfig())
 	galleryService.Start(application.ApplicationConfig().Context, application.BackendLoader())
@@ -174,6 +183,8 @@ func API(application *application.Application) (*fiber.App, error) {
 		routes.RegisterUIRoutes(router, application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig(), galleryService)
 	}
 	routes.RegisterJINARoutes(router, application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())
+	routes.RegisterAnthropicRoutes(router, application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig())
+	routes.RegisterOllamaRoutes(router, application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig(), galleryService)
 
 	httpFS := http.FS(embedDirStatic)
 
@@ -0,0 +1,38 @@
+package fiberContext
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// ResponseError is returned by the handlers which already sent the error response, in the format of their API.
+// The middlewares can still act on the error (e.g. to fall back on another model), while the error handler keeps the response.
+type ResponseError struct {
+	Err error
+}
+
+func (e *ResponseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorStatus returns the HTTP status of an error: 429 when the model queue is full, 503 when the request waited
+// too long in the queue or the backend of the model is crashing, the status of fiber errors, and 500 otherwise
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, model.ErrQueueFull):
+		return fiber.StatusTooManyRequests
+	case errors.Is(err, model.ErrQueueTimeout), errors.Is(err, model.ErrBackendRestarting), errors.Is(err, model.ErrBackendFailed):
+		return fiber.StatusServiceUnavailable
+	}
+	var e *fiber.Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return fiber.StatusInternalServerError
+}
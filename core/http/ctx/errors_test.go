@@ -0,0 +1,19 @@
+package fiberContext
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorStatus(t *testing.T) {
+	require.Equal(t, fiber.StatusTooManyRequests, ErrorStatus(model.ErrQueueFull))
+	require.Equal(t, fiber.StatusTooManyRequests, ErrorStatus(&ResponseError{Err: fmt.Errorf("failed: %w", model.ErrQueueFull)}))
+	require.Equal(t, fiber.StatusServiceUnavailable, ErrorStatus(model.ErrQueueTimeout))
+	require.Equal(t, fiber.StatusServiceUnavailable, ErrorStatus(fmt.Errorf("%w: model crashed", model.ErrBackendRestarting)))
+	require.Equal(t, fiber.StatusBadRequest, ErrorStatus(fiber.ErrBadRequest))
+	require.Equal(t, fiber.StatusInternalServerError, ErrorStatus(fmt.Errorf("failed")))
+}
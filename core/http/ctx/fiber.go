@@ -2,6 +2,7 @@ package fiberContext
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,6 +17,10 @@ import (
 // Takes a model string as input which should be the one received from the user request.
 // It returns the model name resolved from the context and an error if any.
 func ModelFromContext(ctx *fiber.Ctx, cl *config.BackendConfigLoader, loader *model.ModelLoader, modelInput string, firstModel bool) (string, error) {
+	// The model router already picked the model serving the request
+	if routed, ok := ctx.Locals(RoutedModelKey).(string); ok && routed != "" {
+		return routed, nil
+	}
 	if ctx.Params("model") != "" {
 		modelInput = ctx.Params("model")
 	}
@@ -45,3 +50,25 @@ func ModelFromContext(ctx *fiber.Ctx, cl *config.BackendConfigLoader, loader *mo
 	}
 	return modelInput, nil
 }
+
+// RoutedModelKey is the key of the request locals holding the model picked by the model router
+const RoutedModelKey = "routed_model"
+
+// PriorityHeader is the request header used to set the priority of a request in the model queues
+const PriorityHeader = "X-Priority"
+
+// PriorityFromContext returns the priority of the request in the model queues.
+// The priority comes from the API key used, 0 by default, and can only be lowered with the priority header.
+func PriorityFromContext(ctx *fiber.Ctx, appConfig *config.ApplicationConfig) int {
+	apiKey := ctx.Get("x-api-key")
+	if bearer := ctx.Get("authorization"); strings.HasPrefix(bearer, "Bearer ") {
+		apiKey = strings.TrimPrefix(bearer, "Bearer ")
+	}
+	keyPriority := appConfig.ApiKeyPriorities[apiKey]
+
+	priority, err := strconv.Atoi(ctx.Get(PriorityHeader))
+	if err != nil || priority > keyPriority {
+		return keyPriority
+	}
+	return priority
+}
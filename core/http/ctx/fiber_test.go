@@ -0,0 +1,50 @@
+package fiberContext
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityFromContext(t *testing.T) {
+	appConfig := &config.ApplicationConfig{ApiKeyPriorities: map[string]int{"interactive": 10, "batch": -5}}
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString(strconv.Itoa(PriorityFromContext(c, appConfig)))
+	})
+
+	for _, tc := range []struct {
+		name     string
+		apiKey   string
+		header   string
+		expected string
+	}{
+		{name: "no key", expected: "0"},
+		{name: "no key raising", header: "1000000", expected: "0"},
+		{name: "no key lowering", header: "-3", expected: "-3"},
+		{name: "key", apiKey: "interactive", expected: "10"},
+		{name: "key lowering", apiKey: "interactive", header: "5", expected: "5"},
+		{name: "key raising", apiKey: "batch", header: "10", expected: "-5"},
+		{name: "key without priority raising", apiKey: "other", header: "10", expected: "0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tc.apiKey != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.apiKey)
+			}
+			if tc.header != "" {
+				req.Header.Set(PriorityHeader, tc.header)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			body := make([]byte, 32)
+			n, _ := resp.Body.Read(body)
+			require.Equal(t, tc.expected, string(body[:n]))
+		})
+	}
+}
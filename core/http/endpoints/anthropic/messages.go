@@ -0,0 +1,458 @@
+package anthropic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/functions/grammars"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// MessagesEndpoint is the Anthropic Messages API endpoint https://docs.anthropic.com/en/api/messages
+// @Summary Generate a message for the given conversation and model.
+// @Param request body schema.AnthropicRequest true "query params"
+// @Success 200 {object} schema.AnthropicResponse "Response"
+// @Router /v1/messages [post]
+func MessagesEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		id := "msg_" + uuid.New().String()
+
+		input := new(schema.AnthropicRequest)
+		if err := c.BodyParser(input); err != nil {
+			return sendError(c, fiber.StatusBadRequest, "invalid_request_error", fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+		if len(input.Messages) == 0 {
+			return sendError(c, fiber.StatusBadRequest, "invalid_request_error", "messages is required")
+		}
+
+		modelFile, err := fiberContext.ModelFromContext(c, cl, ml, input.Model, true)
+		if err != nil {
+			return sendError(c, fiber.StatusBadRequest, "invalid_request_error", err.Error())
+		}
+
+		cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
+			config.LoadOptionDebug(appConfig.Debug),
+			config.LoadOptionThreads(appConfig.Threads),
+			config.LoadOptionContextSize(appConfig.ContextSize),
+			config.LoadOptionF16(appConfig.F16),
+		)
+		if err != nil {
+			return sendError(c, fiber.StatusInternalServerError, "api_error", err.Error())
+		}
+		if !cfg.Validate() {
+			return sendError(c, fiber.StatusBadRequest, "invalid_request_error", "failed to validate config")
+		}
+
+		updateRequestConfig(cfg, input)
+
+		messages, err := toMessages(input, cfg)
+		if err != nil {
+			return sendError(c, fiber.StatusBadRequest, "invalid_request_error", err.Error())
+		}
+
+		funcs, noActionName := toolFunctions(cfg, input)
+		shouldUseFn := len(funcs) > 0
+
+		predInput := ""
+		// If we are using the tokenizer template, we don't need to process the messages
+		// unless we are processing functions
+		if !cfg.TemplateConfig.UseTokenizerTemplate || shouldUseFn {
+			predInput = evaluator.TemplateMessages(messages, cfg, funcs, shouldUseFn)
+			log.Debug().Msgf("Prompt (after templating): %s", predInput)
+		}
+
+		ctx, cancel := context.WithCancel(appConfig.Context)
+		ctx = model.WithPriority(ctx, fiberContext.PriorityFromContext(c, appConfig))
+
+		response := &schema.AnthropicResponse{
+			ID:      id,
+			Type:    "message",
+			Role:    "assistant",
+			Model:   input.Model,
+			Content: []schema.AnthropicContentBlock{},
+		}
+
+		if !input.Stream {
+			defer cancel()
+			prediction, err := predict(ctx, predInput, messages, cfg, ml, appConfig, nil)
+			if err != nil {
+				return sendModelError(c, err)
+			}
+
+			response.Content, response.StopReason = toContentBlocks(prediction, cfg, predInput, shouldUseFn, noActionName)
+			response.Usage = schema.AnthropicUsage{
+				InputTokens:  prediction.Usage.Prompt,
+				OutputTokens: prediction.Usage.Completion,
+			}
+			return c.JSON(response)
+		}
+
+		// the status of the response can't change once the stream starts
+		if err := ml.Available(backend.ModelID(*cfg)); err != nil {
+			cancel()
+			return sendModelError(c, err)
+		}
+
+		c.Context().SetContentType("text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("Transfer-Encoding", "chunked")
+
+		events := make(chan schema.AnthropicStreamEvent)
+		go func() {
+			defer close(events)
+			streamMessage(ctx, events, response, predInput, messages, cfg, ml, appConfig, shouldUseFn, noActionName)
+		}()
+
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer cancel()
+			for ev := range events {
+				data, _ := json.Marshal(ev)
+				log.Debug().Msgf("Sending event: %s", data)
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+					log.Debug().Msgf("Sending event failed: %v", err)
+					cancel()
+				}
+				w.Flush()
+			}
+		}))
+		return nil
+	}
+}
+
+// streamMessage sends the events of the Anthropic stream format. Text is streamed token by token, while
+// tool calls are sent once the generation is complete, as the result has to be parsed first
+func streamMessage(ctx context.Context, events chan schema.AnthropicStreamEvent, response *schema.AnthropicResponse, predInput string, messages []schema.Message, cfg *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig, shouldUseFn bool, noActionName string) {
+	events <- schema.AnthropicStreamEvent{Type: "message_start", Message: response}
+
+	index := 0
+	var tokenCallback func(string, backend.TokenUsage, []schema.LogprobContent) bool
+	if !shouldUseFn {
+		events <- schema.AnthropicStreamEvent{Type: "content_block_start", Index: &index, ContentBlock: &schema.AnthropicContentBlock{Type: "text"}}
+		tokenCallback = func(token string, _ backend.TokenUsage, _ []schema.LogprobContent) bool {
+			if token != "" {
+				events <- schema.AnthropicStreamEvent{Type: "content_block_delta", Index: &index, Delta: &schema.AnthropicDelta{Type: "text_delta", Text: token}}
+			}
+			return true
+		}
+	}
+
+	prediction, err := predict(ctx, predInput, messages, cfg, ml, appConfig, tokenCallback)
+	if err != nil {
+		log.Error().Err(err).Msg("anthropic: prediction failed")
+		events <- schema.AnthropicStreamEvent{Type: "error", Error: &schema.AnthropicError{Type: "api_error", Message: err.Error()}}
+		return
+	}
+
+	blocks, stopReason := toContentBlocks(prediction, cfg, predInput, shouldUseFn, noActionName)
+	if !shouldUseFn {
+		events <- schema.AnthropicStreamEvent{Type: "content_block_stop", Index: &index}
+	} else {
+		for i, block := range blocks {
+			i := i
+			start := block
+			var delta *schema.AnthropicDelta
+			switch block.Type {
+			case "tool_use":
+				start.Input = json.RawMessage("{}")
+				delta = &schema.AnthropicDelta{Type: "input_json_delta", PartialJSON: string(block.Input)}
+			default:
+				start.Text = ""
+				delta = &schema.AnthropicDelta{Type: "text_delta", Text: block.Text}
+			}
+			events <- schema.AnthropicStreamEvent{Type: "content_block_start", Index: &i, ContentBlock: &start}
+			events <- schema.AnthropicStreamEvent{Type: "content_block_delta", Index: &i, Delta: delta}
+			events <- schema.AnthropicStreamEvent{Type: "content_block_stop", Index: &i}
+		}
+	}
+
+	events <- schema.AnthropicStreamEvent{
+		Type:  "message_delta",
+		Delta: &schema.AnthropicDelta{StopReason: *stopReason},
+		Usage: &schema.AnthropicUsage{InputTokens: prediction.Usage.Prompt, OutputTokens: prediction.Usage.Completion},
+	}
+	events <- schema.AnthropicStreamEvent{Type: "message_stop"}
+}
+
+func predict(ctx context.Context, predInput string, messages []schema.Message, cfg *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig, tokenCallback func(string, backend.TokenUsage, []schema.LogprobContent) bool) (backend.LLMResponse, error) {
+	images := []string{}
+	for _, m := range messages {
+		images = append(images, m.StringImages...)
+	}
+
+	predFunc, err := backend.ModelInference(ctx, predInput, messages, images, nil, nil, ml, *cfg, appConfig, tokenCallback)
+	if err != nil {
+		return backend.LLMResponse{}, err
+	}
+	prediction, err := predFunc()
+	if err != nil {
+		return backend.LLMResponse{}, err
+	}
+	prediction.Response = backend.Finetune(*cfg, predInput, prediction.Response)
+	return prediction, nil
+}
+
+// toContentBlocks converts the prediction to content blocks, parsing the tool calls if tools are in use
+func toContentBlocks(prediction backend.LLMResponse, cfg *config.BackendConfig, predInput string, shouldUseFn bool, noActionName string) ([]schema.AnthropicContentBlock, *string) {
+	stopReason := "end_turn"
+	if cfg.Maxtokens != nil && *cfg.Maxtokens > 0 && prediction.Usage.Completion >= *cfg.Maxtokens {
+		stopReason = "max_tokens"
+	}
+
+	if !shouldUseFn {
+		return []schema.AnthropicContentBlock{{Type: "text", Text: prediction.Response}}, &stopReason
+	}
+
+	textContent := functions.ParseTextContent(prediction.Response, cfg.FunctionsConfig)
+	result := functions.CleanupLLMResult(prediction.Response, cfg.FunctionsConfig)
+	results := functions.ParseFunctionCall(result, cfg.FunctionsConfig)
+
+	if len(results) == 0 || results[0].Name == noActionName {
+		text := result
+		if len(results) > 0 {
+			// the no action function carries the reply in its message argument
+			arguments := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(results[0].Arguments), &arguments); err == nil {
+				if message, ok := arguments["message"].(string); ok {
+					text = backend.Finetune(*cfg, predInput, message)
+				}
+			}
+		}
+		return []schema.AnthropicContentBlock{{Type: "text", Text: text}}, &stopReason
+	}
+
+	blocks := []schema.AnthropicContentBlock{}
+	if textContent != "" {
+		blocks = append(blocks, schema.AnthropicContentBlock{Type: "text", Text: textContent})
+	}
+	for _, r := range results {
+		input := json.RawMessage(r.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, schema.AnthropicContentBlock{
+			Type:  "tool_use",
+			ID:    "toolu_" + uuid.New().String(),
+			Name:  r.Name,
+			Input: input,
+		})
+	}
+	stopReason = "tool_use"
+	return blocks, &stopReason
+}
+
+func updateRequestConfig(cfg *config.BackendConfig, input *schema.AnthropicRequest) {
+	if input.MaxTokens > 0 {
+		cfg.Maxtokens = &input.MaxTokens
+	}
+	if input.Temperature != nil {
+		cfg.Temperature = input.Temperature
+	}
+	if input.TopP != nil {
+		cfg.TopP = input.TopP
+	}
+	if input.TopK != nil {
+		cfg.TopK = input.TopK
+	}
+	cfg.StopWords = append(cfg.StopWords, input.StopSequences...)
+}
+
+// toolFunctions returns the functions the model can call, along with the name of the function used to answer without calling any tool
+func toolFunctions(cfg *config.BackendConfig, input *schema.AnthropicRequest) (functions.Functions, string) {
+	noActionName := "answer"
+	noActionDescription := "use this action to answer without performing any action"
+	if cfg.FunctionsConfig.NoActionFunctionName != "" {
+		noActionName = cfg.FunctionsConfig.NoActionFunctionName
+	}
+	if cfg.FunctionsConfig.NoActionDescriptionName != "" {
+		noActionDescription = cfg.FunctionsConfig.NoActionDescriptionName
+	}
+
+	if len(input.Tools) == 0 || (input.ToolChoice != nil && input.ToolChoice.Type == "none") {
+		return nil, noActionName
+	}
+
+	funcs := functions.Functions{}
+	for _, t := range input.Tools {
+		funcs = append(funcs, functions.Function{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.InputSchema,
+		})
+	}
+
+	if input.ToolChoice != nil && input.ToolChoice.Type == "tool" {
+		funcs = funcs.Select(input.ToolChoice.Name)
+	}
+
+	if cfg.FunctionsConfig.GrammarConfig.NoGrammar {
+		return funcs, noActionName
+	}
+
+	// with any or a specific tool, the model has to call a tool
+	if !cfg.FunctionsConfig.DisableNoAction && (input.ToolChoice == nil || input.ToolChoice.Type == "auto") {
+		funcs = append(funcs, functions.Function{
+			Name:        noActionName,
+			Description: noActionDescription,
+			Parameters: map[string]interface{}{
+				"properties": map[string]interface{}{
+					"message": map[string]interface{}{
+						"type":        "string",
+						"description": "The message to reply the user with",
+					}},
+			},
+		})
+	}
+
+	grammarOpts := cfg.FunctionsConfig.GrammarOptions()
+	if input.ToolChoice != nil && (input.ToolChoice.Type == "any" || input.ToolChoice.Type == "tool") {
+		grammarOpts = append(grammarOpts, grammars.RequireFunctionCall)
+	}
+	jsStruct := funcs.ToJSONStructure(cfg.FunctionsConfig.FunctionNameKey, cfg.FunctionsConfig.FunctionNameKey)
+	if g, err := jsStruct.Grammar(grammarOpts...); err == nil {
+		cfg.Grammar = g
+	}
+	return funcs, noActionName
+}
+
+// toMessages converts the system prompt and the messages of the request to the internal representation
+func toMessages(input *schema.AnthropicRequest, cfg *config.BackendConfig) ([]schema.Message, error) {
+	messages := []schema.Message{}
+
+	system, err := decodeBlocks(input.System)
+	if err != nil {
+		return nil, fmt.Errorf("invalid system: %w", err)
+	}
+	if len(system) > 0 {
+		text := ""
+		for _, b := range system {
+			text += b.Text
+		}
+		messages = append(messages, schema.Message{Role: "system", Content: text, StringContent: text})
+	}
+
+	// tool results only reference the id of the tool call
+	toolNames := map[string]string{}
+	totalImages := 0
+	for _, m := range input.Messages {
+		blocks, err := decodeBlocks(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content: %w", err)
+		}
+
+		message := schema.Message{Role: m.Role}
+		text := ""
+		for _, b := range blocks {
+			switch b.Type {
+			case "text":
+				text += b.Text
+			case "image":
+				if b.Source == nil {
+					return nil, fmt.Errorf("image block without source")
+				}
+				image := b.Source.Data
+				if b.Source.Type == "url" {
+					if image, err = utils.GetContentURIAsBase64(b.Source.URL); err != nil {
+						return nil, fmt.Errorf("failed fetching image: %w", err)
+					}
+				}
+				message.StringImages = append(message.StringImages, image)
+			case "tool_use":
+				toolNames[b.ID] = b.Name
+				message.ToolCalls = append(message.ToolCalls, schema.ToolCall{
+					Index:        len(message.ToolCalls),
+					ID:           b.ID,
+					Type:         "function",
+					FunctionCall: schema.FunctionCall{Name: b.Name, Arguments: string(b.Input)},
+				})
+			case "tool_result":
+				result, err := decodeBlocks(b.Content)
+				if err != nil {
+					return nil, fmt.Errorf("invalid tool result: %w", err)
+				}
+				content := ""
+				for _, r := range result {
+					content += r.Text
+				}
+				messages = append(messages, schema.Message{Role: "tool", Name: toolNames[b.ToolUseID], Content: content, StringContent: content})
+			default:
+				return nil, fmt.Errorf("unsupported content block type %q", b.Type)
+			}
+		}
+
+		if text == "" && len(message.StringImages) == 0 && len(message.ToolCalls) == 0 {
+			continue
+		}
+
+		totalImages += len(message.StringImages)
+		message.Content = text
+		message.StringContent = text
+		if len(message.StringImages) > 0 {
+			message.StringContent, _ = templates.TemplateMultiModal(cfg.TemplateConfig.Multimodal, templates.MultiModalOptions{
+				TotalImages:     totalImages,
+				ImagesInMessage: len(message.StringImages),
+			}, text)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// decodeBlocks returns the content blocks of a content that is either a string or a list of blocks
+func decodeBlocks(content interface{}) ([]schema.AnthropicContentBlock, error) {
+	switch c := content.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []schema.AnthropicContentBlock{{Type: "text", Text: c}}, nil
+	case []interface{}:
+		dat, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		blocks := []schema.AnthropicContentBlock{}
+		if err := json.Unmarshal(dat, &blocks); err != nil {
+			return nil, err
+		}
+		return blocks, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %T", content)
+	}
+}
+
+func sendError(c *fiber.Ctx, status int, errorType, message string) error {
+	return c.Status(status).JSON(schema.AnthropicErrorResponse{
+		Type:  "error",
+		Error: schema.AnthropicError{Type: errorType, Message: message},
+	})
+}
+
+// sendModelError sends an error of the model, with the status matching it, e.g. 429 when its queue is full.
+// The error is returned, so the model router can fall back on another model when it fails loading.
+func sendModelError(c *fiber.Ctx, err error) error {
+	status := fiberContext.ErrorStatus(err)
+	errorType := "api_error"
+	switch status {
+	case fiber.StatusTooManyRequests:
+		errorType = "rate_limit_error"
+	case fiber.StatusServiceUnavailable:
+		errorType = "overloaded_error"
+	}
+	if sendErr := sendError(c, status, errorType, err.Error()); sendErr != nil {
+		return sendErr
+	}
+	return &fiberContext.ResponseError{Err: err}
+}
@@ -0,0 +1,138 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToMessages(t *testing.T) {
+	input := &schema.AnthropicRequest{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"system": [{"type": "text", "text": "Be brief"}],
+		"messages": [
+			{"role": "user", "content": "What is the weather in Rome?"},
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "Rome"}}]},
+			{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": [{"type": "text", "text": "Sunny"}]}]},
+			{"role": "user", "content": [{"type": "text", "text": "Describe this"}, {"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "aGVsbG8="}}]}
+		]
+	}`), input))
+
+	messages, err := toMessages(input, &config.BackendConfig{})
+	assert.NoError(t, err)
+	assert.Len(t, messages, 5)
+
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "Be brief", messages[0].StringContent)
+	assert.Equal(t, "What is the weather in Rome?", messages[1].StringContent)
+
+	assert.Equal(t, "assistant", messages[2].Role)
+	assert.Len(t, messages[2].ToolCalls, 1)
+	assert.Equal(t, "get_weather", messages[2].ToolCalls[0].FunctionCall.Name)
+	assert.JSONEq(t, `{"city": "Rome"}`, messages[2].ToolCalls[0].FunctionCall.Arguments)
+
+	assert.Equal(t, "tool", messages[3].Role)
+	assert.Equal(t, "get_weather", messages[3].Name)
+	assert.Equal(t, "Sunny", messages[3].StringContent)
+
+	assert.Equal(t, []string{"aGVsbG8="}, messages[4].StringImages)
+	assert.Contains(t, messages[4].StringContent, "Describe this")
+
+	input.Messages = []schema.AnthropicMessage{{Role: "user", Content: []interface{}{map[string]interface{}{"type": "document"}}}}
+	_, err = toMessages(input, &config.BackendConfig{})
+	assert.Error(t, err)
+}
+
+func TestToContentBlocks(t *testing.T) {
+	maxTokens := 10
+	cfg := &config.BackendConfig{}
+	cfg.Maxtokens = &maxTokens
+
+	blocks, stopReason := toContentBlocks(backend.LLMResponse{Response: "Hello", Usage: backend.TokenUsage{Completion: 2}}, cfg, "", false, "answer")
+	assert.Equal(t, []schema.AnthropicContentBlock{{Type: "text", Text: "Hello"}}, blocks)
+	assert.Equal(t, "end_turn", *stopReason)
+
+	_, stopReason = toContentBlocks(backend.LLMResponse{Response: "Hello", Usage: backend.TokenUsage{Completion: 10}}, cfg, "", false, "answer")
+	assert.Equal(t, "max_tokens", *stopReason)
+
+	blocks, stopReason = toContentBlocks(backend.LLMResponse{Response: `{"name": "get_weather", "arguments": {"city": "Rome"}}`}, cfg, "", true, "answer")
+	assert.Equal(t, "tool_use", *stopReason)
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, "tool_use", blocks[0].Type)
+	assert.Equal(t, "get_weather", blocks[0].Name)
+	assert.JSONEq(t, `{"city": "Rome"}`, string(blocks[0].Input))
+
+	blocks, stopReason = toContentBlocks(backend.LLMResponse{Response: `{"name": "answer", "arguments": {"message": "Hi!"}}`}, cfg, "", true, "answer")
+	assert.Equal(t, "end_turn", *stopReason)
+	assert.Equal(t, []schema.AnthropicContentBlock{{Type: "text", Text: "Hi!"}}, blocks)
+}
+
+func TestToolFunctions(t *testing.T) {
+	input := &schema.AnthropicRequest{Tools: []schema.AnthropicTool{{Name: "get_weather", InputSchema: map[string]interface{}{"type": "object"}}}}
+
+	funcs, noActionName := toolFunctions(&config.BackendConfig{}, input)
+	assert.Equal(t, "answer", noActionName)
+	assert.Len(t, funcs, 2)
+
+	input.ToolChoice = &schema.AnthropicToolChoice{Type: "any"}
+	funcs, _ = toolFunctions(&config.BackendConfig{}, input)
+	assert.Len(t, funcs, 1)
+
+	input.ToolChoice = &schema.AnthropicToolChoice{Type: "none"}
+	funcs, _ = toolFunctions(&config.BackendConfig{}, input)
+	assert.Empty(t, funcs)
+}
+
+func TestContentBlockJSON(t *testing.T) {
+	data, err := json.Marshal(schema.AnthropicContentBlock{Type: "text"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "text", "text": ""}`, string(data))
+
+	data, err = json.Marshal(schema.AnthropicContentBlock{Type: "tool_use", ID: "toolu_1", Name: "f", Input: json.RawMessage(`{}`)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"type": "tool_use", "id": "toolu_1", "name": "f", "input": {}}`, string(data))
+}
+
+func TestMessagesRequiresMessages(t *testing.T) {
+	app := fiber.New()
+	app.Post("/v1/messages", MessagesEndpoint(&config.BackendConfigLoader{}, model.NewModelLoader("/tmp/localai/model"), templates.NewEvaluator("/tmp/localai/model"), &config.ApplicationConfig{}))
+
+	req := httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{"model": "test", "max_tokens": 10, "messages": []}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	body := schema.AnthropicErrorResponse{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "error", body.Type)
+	assert.Equal(t, "invalid_request_error", body.Error.Type)
+}
+
+func TestSendModelError(t *testing.T) {
+	app := fiber.New()
+	var handlerErr error
+	app.Post("/", func(c *fiber.Ctx) error {
+		handlerErr = sendModelError(c, &model.LoadError{ModelID: "test", Err: model.ErrQueueFull})
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	var loadErr *model.LoadError
+	assert.ErrorAs(t, handlerErr, &loadErr)
+
+	body := schema.AnthropicErrorResponse{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "rate_limit_error", body.Error.Type)
+}
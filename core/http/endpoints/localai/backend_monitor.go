@@ -9,7 +9,7 @@ import (
 // BackendMonitorEndpoint returns the status of the specified backend
 // @Summary Backend monitor endpoint
 // @Param request body schema.BackendMonitorRequest true "Backend statistics request"
-// @Success 200 {object} proto.StatusResponse "Response"
+// @Success 200 {object} schema.BackendMonitorStatus "Response"
 // @Router /backend/monitor [get]
 func BackendMonitorEndpoint(bm *services.BackendMonitorService) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
@@ -43,3 +43,47 @@ func BackendShutdownEndpoint(bm *services.BackendMonitorService) func(c *fiber.C
 		return bm.ShutdownModel(input.Model)
 	}
 }
+
+// BackendResetEndpoint forgets the crashes of the specified backend, so it can be started again
+// @Summary Backend reset endpoint
+// @Param request body schema.BackendMonitorRequest true "Backend statistics request"
+// @Router /backend/reset [post]
+func BackendResetEndpoint(bm *services.BackendMonitorService) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.BackendMonitorRequest)
+		// Get input data from the request body
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		return bm.ResetModel(input.Model)
+	}
+}
+
+// BackendPinEndpoint keeps the specified backend loaded: it is neither stopped by the watchdog nor evicted
+// @Summary Backend pin endpoint
+// @Param request body schema.BackendMonitorRequest true "Backend statistics request"
+// @Router /backend/pin [post]
+func BackendPinEndpoint(bm *services.BackendMonitorService) func(c *fiber.Ctx) error {
+	return backendPinEndpoint(bm, true)
+}
+
+// BackendUnpinEndpoint lets the specified backend be stopped again, even if its configuration pins it
+// @Summary Backend unpin endpoint
+// @Param request body schema.BackendMonitorRequest true "Backend statistics request"
+// @Router /backend/unpin [post]
+func BackendUnpinEndpoint(bm *services.BackendMonitorService) func(c *fiber.Ctx) error {
+	return backendPinEndpoint(bm, false)
+}
+
+func backendPinEndpoint(bm *services.BackendMonitorService, pinned bool) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.BackendMonitorRequest)
+		// Get input data from the request body
+		if err := c.BodyParser(input); err != nil {
+			return err
+		}
+
+		return bm.PinModel(input.Model, pinned)
+	}
+}
@@ -24,12 +24,14 @@ func SystemInformations(ml *model.ModelLoader, appConfig *config.ApplicationConf
 
 		sysmodels := []schema.SysInfoModel{}
 		for _, m := range loadedModels {
-			sysmodels = append(sysmodels, schema.SysInfoModel{ID: m.ID})
+			sysmodels = append(sysmodels, schema.SysInfoModel{ID: m.ID, Pinned: ml.IsPinned(m.ID)})
 		}
 		return c.JSON(
 			schema.SystemInformationResponse{
 				Backends: availableBackends,
 				Models:   sysmodels,
+				Crashes:  ml.CrashStates(),
+				Pinned:   ml.PinnedModels(),
 			},
 		)
 	}
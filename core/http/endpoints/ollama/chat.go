@@ -0,0 +1,326 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// ChatEndpoint is the Ollama chat endpoint https://github.com/ollama/ollama/blob/main/docs/api.md#generate-a-chat-completion
+// @Summary Generate the next message in a chat with the given model.
+// @Param request body schema.OllamaChatRequest true "query params"
+// @Success 200 {object} schema.OllamaChatResponse "Response"
+// @Router /api/chat [post]
+func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.OllamaChatRequest)
+		if err := c.BodyParser(input); err != nil {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+
+		cfg, err := loadConfig(c, cl, ml, appConfig, input.Model)
+		if err != nil {
+			return sendError(c, fiber.StatusBadRequest, err.Error())
+		}
+		if err := updateRequestConfig(cfg, input.Options, input.Format); err != nil {
+			return sendError(c, fiber.StatusBadRequest, err.Error())
+		}
+
+		messages := []schema.Message{}
+		totalImages := 0
+		for _, m := range input.Messages {
+			message := schema.Message{Role: m.Role, Content: m.Content, StringContent: m.Content, StringImages: m.Images}
+			if len(m.Images) > 0 {
+				totalImages += len(m.Images)
+				message.StringContent, _ = templates.TemplateMultiModal(cfg.TemplateConfig.Multimodal, templates.MultiModalOptions{
+					TotalImages:     totalImages,
+					ImagesInMessage: len(m.Images),
+				}, m.Content)
+			}
+			messages = append(messages, message)
+		}
+
+		predInput := ""
+		if !cfg.TemplateConfig.UseTokenizerTemplate {
+			predInput = evaluator.TemplateMessages(messages, cfg, nil, false)
+			log.Debug().Msgf("Prompt (after templating): %s", predInput)
+		}
+
+		chunk := func(content string) interface{} {
+			return schema.OllamaChatResponse{
+				Model:     input.Model,
+				CreatedAt: time.Now().UTC(),
+				Message:   schema.OllamaMessage{Role: "assistant", Content: content},
+			}
+		}
+		final := func(content, doneReason string, metrics schema.OllamaMetrics) interface{} {
+			return schema.OllamaChatResponse{
+				Model:         input.Model,
+				CreatedAt:     time.Now().UTC(),
+				Message:       schema.OllamaMessage{Role: "assistant", Content: content},
+				Done:          true,
+				DoneReason:    doneReason,
+				OllamaMetrics: metrics,
+			}
+		}
+		return respond(c, input.Stream, predInput, messages, cfg, ml, appConfig, chunk, final)
+	}
+}
+
+// GenerateEndpoint is the Ollama completion endpoint https://github.com/ollama/ollama/blob/main/docs/api.md#generate-a-completion
+// @Summary Generate a response for the given prompt with the given model.
+// @Param request body schema.OllamaGenerateRequest true "query params"
+// @Success 200 {object} schema.OllamaGenerateResponse "Response"
+// @Router /api/generate [post]
+func GenerateEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.OllamaGenerateRequest)
+		if err := c.BodyParser(input); err != nil {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+
+		cfg, err := loadConfig(c, cl, ml, appConfig, input.Model)
+		if err != nil {
+			return sendError(c, fiber.StatusBadRequest, err.Error())
+		}
+
+		// an empty prompt only loads the model
+		if input.Prompt == "" {
+			return c.JSON(schema.OllamaGenerateResponse{Model: input.Model, CreatedAt: time.Now().UTC(), Done: true, DoneReason: "load"})
+		}
+
+		if err := updateRequestConfig(cfg, input.Options, input.Format); err != nil {
+			return sendError(c, fiber.StatusBadRequest, err.Error())
+		}
+
+		predInput := input.Prompt
+		if !input.Raw {
+			systemPrompt := cfg.SystemPrompt
+			if input.System != "" {
+				systemPrompt = input.System
+			}
+			templatedInput, err := evaluator.EvaluateTemplateForPrompt(templates.CompletionPromptTemplate, *cfg, templates.PromptTemplateData{
+				SystemPrompt: systemPrompt,
+				Input:        predInput,
+			})
+			if err == nil {
+				predInput = templatedInput
+				log.Debug().Msgf("Template found, input modified to: %s", predInput)
+			}
+		}
+
+		messages := []schema.Message{{Role: "user", Content: input.Prompt, StringContent: input.Prompt, StringImages: input.Images}}
+
+		chunk := func(content string) interface{} {
+			return schema.OllamaGenerateResponse{Model: input.Model, CreatedAt: time.Now().UTC(), Response: content}
+		}
+		final := func(content, doneReason string, metrics schema.OllamaMetrics) interface{} {
+			return schema.OllamaGenerateResponse{
+				Model:         input.Model,
+				CreatedAt:     time.Now().UTC(),
+				Response:      content,
+				Done:          true,
+				DoneReason:    doneReason,
+				OllamaMetrics: metrics,
+			}
+		}
+		return respond(c, input.Stream, predInput, messages, cfg, ml, appConfig, chunk, final)
+	}
+}
+
+// respond runs the prediction and replies either with a single response or, by default, with a stream of
+// newline delimited JSON chunks. The final response carries the metrics of the generation.
+func respond(c *fiber.Ctx, stream *bool, predInput string, messages []schema.Message, cfg *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig,
+	chunk func(string) interface{}, final func(string, string, schema.OllamaMetrics) interface{}) error {
+	ctx, cancel := context.WithCancel(appConfig.Context)
+	ctx = model.WithPriority(ctx, fiberContext.PriorityFromContext(c, appConfig))
+	start := time.Now()
+
+	images := []string{}
+	for _, m := range messages {
+		images = append(images, m.StringImages...)
+	}
+
+	predict := func(tokenCallback func(string, backend.TokenUsage, []schema.LogprobContent) bool) (interface{}, error) {
+		predFunc, err := backend.ModelInference(ctx, predInput, messages, images, nil, nil, ml, *cfg, appConfig, tokenCallback)
+		if err != nil {
+			return nil, err
+		}
+		prediction, err := predFunc()
+		if err != nil {
+			return nil, err
+		}
+
+		content := ""
+		if tokenCallback == nil {
+			content = backend.Finetune(*cfg, predInput, prediction.Response)
+		}
+		doneReason := "stop"
+		if cfg.Maxtokens != nil && *cfg.Maxtokens > 0 && prediction.Usage.Completion >= *cfg.Maxtokens {
+			doneReason = "length"
+		}
+		return final(content, doneReason, schema.OllamaMetrics{
+			TotalDuration:      time.Since(start).Nanoseconds(),
+			PromptEvalCount:    prediction.Usage.Prompt,
+			PromptEvalDuration: int64(prediction.Usage.TimingPromptProcessing * float64(time.Millisecond)),
+			EvalCount:          prediction.Usage.Completion,
+			EvalDuration:       int64(prediction.Usage.TimingTokenGeneration * float64(time.Millisecond)),
+		}), nil
+	}
+
+	if stream != nil && !*stream {
+		defer cancel()
+		resp, err := predict(nil)
+		if err != nil {
+			return sendModelError(c, err)
+		}
+		return c.JSON(resp)
+	}
+
+	// the status of the response can't change once the stream starts
+	if err := ml.Available(backend.ModelID(*cfg)); err != nil {
+		cancel()
+		return sendModelError(c, err)
+	}
+
+	c.Context().SetContentType("application/x-ndjson")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Transfer-Encoding", "chunked")
+
+	chunks := make(chan interface{})
+	go func() {
+		defer close(chunks)
+		resp, err := predict(func(token string, _ backend.TokenUsage, _ []schema.LogprobContent) bool {
+			if token != "" {
+				chunks <- chunk(token)
+			}
+			return true
+		})
+		if err != nil {
+			log.Error().Err(err).Msg("ollama: prediction failed")
+			resp = schema.OllamaErrorResponse{Error: err.Error()}
+		}
+		chunks <- resp
+	}()
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		for ch := range chunks {
+			data, _ := json.Marshal(ch)
+			if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+				log.Debug().Msgf("Sending chunk failed: %v", err)
+				cancel()
+			}
+			w.Flush()
+		}
+	}))
+	return nil
+}
+
+// loadConfig returns the configuration of the requested model, Ollama clients may add the default tag to the model name
+func loadConfig(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, modelName string) (*config.BackendConfig, error) {
+	if _, exists := cl.GetBackendConfig(modelName); !exists {
+		modelName = strings.TrimSuffix(modelName, ":latest")
+	}
+
+	modelFile, err := fiberContext.ModelFromContext(c, cl, ml, modelName, true)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := cl.LoadBackendConfigFileByName(modelFile, appConfig.ModelPath,
+		config.LoadOptionDebug(appConfig.Debug),
+		config.LoadOptionThreads(appConfig.Threads),
+		config.LoadOptionContextSize(appConfig.ContextSize),
+		config.LoadOptionF16(appConfig.F16),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Validate() {
+		return nil, fmt.Errorf("failed to validate config")
+	}
+	return cfg, nil
+}
+
+// updateRequestConfig applies the options and the output format of the request to the model configuration
+func updateRequestConfig(cfg *config.BackendConfig, options *schema.OllamaOptions, format interface{}) error {
+	if options != nil {
+		if options.Temperature != nil {
+			cfg.Temperature = options.Temperature
+		}
+		if options.TopP != nil {
+			cfg.TopP = options.TopP
+		}
+		if options.TopK != nil {
+			cfg.TopK = options.TopK
+		}
+		if options.NumPredict != nil && *options.NumPredict > 0 {
+			cfg.Maxtokens = options.NumPredict
+		}
+		if options.Seed != nil {
+			cfg.Seed = options.Seed
+		}
+		if options.RepeatPenalty != 0 {
+			cfg.RepeatPenalty = options.RepeatPenalty
+		}
+		cfg.StopWords = append(cfg.StopWords, options.Stop...)
+	}
+
+	switch f := format.(type) {
+	case nil:
+	case string:
+		switch f {
+		case "":
+		case "json":
+			cfg.Grammar = functions.JSONBNF
+		default:
+			return fmt.Errorf("unsupported format %q", f)
+		}
+	case map[string]interface{}:
+		dat, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		item := functions.Item{}
+		if err := json.Unmarshal(dat, &item); err != nil {
+			return err
+		}
+		fs := &functions.JSONFunctionStructure{AnyOf: []functions.Item{item}}
+		g, err := fs.Grammar(cfg.FunctionsConfig.GrammarOptions()...)
+		if err != nil {
+			return fmt.Errorf("invalid format: %w", err)
+		}
+		cfg.Grammar = g
+	default:
+		return fmt.Errorf("unsupported format %v", f)
+	}
+	return nil
+}
+
+func sendError(c *fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(schema.OllamaErrorResponse{Error: message})
+}
+
+// sendModelError sends an error of the model, with the status matching it, e.g. 429 when its queue is full.
+// The error is returned, so the model router can fall back on another model when it fails loading.
+func sendModelError(c *fiber.Ctx, err error) error {
+	if sendErr := sendError(c, fiberContext.ErrorStatus(err), err.Error()); sendErr != nil {
+		return sendErr
+	}
+	return &fiberContext.ResponseError{Err: err}
+}
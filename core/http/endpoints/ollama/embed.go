@@ -0,0 +1,89 @@
+package ollama
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// EmbedEndpoint is the Ollama embeddings endpoint https://github.com/ollama/ollama/blob/main/docs/api.md#generate-embeddings
+// @Summary Generate embeddings from a model.
+// @Param request body schema.OllamaEmbedRequest true "query params"
+// @Success 200 {object} schema.OllamaEmbedResponse "Response"
+// @Router /api/embed [post]
+func EmbedEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.OllamaEmbedRequest)
+		if err := c.BodyParser(input); err != nil {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+
+		inputs := []string{}
+		switch i := input.Input.(type) {
+		case string:
+			inputs = append(inputs, i)
+		case []interface{}:
+			for _, s := range i {
+				str, ok := s.(string)
+				if !ok {
+					return sendError(c, fiber.StatusBadRequest, "input must be a string or a list of strings")
+				}
+				inputs = append(inputs, str)
+			}
+		default:
+			return sendError(c, fiber.StatusBadRequest, "input must be a string or a list of strings")
+		}
+
+		cfg, err := loadConfig(c, cl, ml, appConfig, input.Model)
+		if err != nil {
+			return sendError(c, fiber.StatusBadRequest, err.Error())
+		}
+
+		resp := schema.OllamaEmbedResponse{Model: input.Model, Embeddings: [][]float32{}}
+		for _, s := range inputs {
+			embedding, err := embed(s, cfg, ml, appConfig)
+			if err != nil {
+				return sendModelError(c, err)
+			}
+			resp.Embeddings = append(resp.Embeddings, embedding)
+		}
+		return c.JSON(resp)
+	}
+}
+
+// EmbeddingsEndpoint is the legacy Ollama embeddings endpoint, superseded by /api/embed
+// @Summary Generate the embedding of a prompt.
+// @Param request body schema.OllamaEmbedRequest true "query params"
+// @Success 200 {object} schema.OllamaEmbeddingsResponse "Response"
+// @Router /api/embeddings [post]
+func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.OllamaEmbedRequest)
+		if err := c.BodyParser(input); err != nil {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+
+		cfg, err := loadConfig(c, cl, ml, appConfig, input.Model)
+		if err != nil {
+			return sendError(c, fiber.StatusBadRequest, err.Error())
+		}
+
+		embedding, err := embed(input.Prompt, cfg, ml, appConfig)
+		if err != nil {
+			return sendModelError(c, err)
+		}
+		return c.JSON(schema.OllamaEmbeddingsResponse{Embedding: embedding})
+	}
+}
+
+func embed(s string, cfg *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig) ([]float32, error) {
+	embedFn, err := backend.ModelEmbedding(s, []int{}, ml, *cfg, appConfig)
+	if err != nil {
+		return nil, err
+	}
+	return embedFn()
+}
@@ -0,0 +1,159 @@
+package ollama
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/internal"
+	"github.com/mudler/LocalAI/pkg/model"
+)
+
+// TagsEndpoint lists the models like the Ollama API https://github.com/ollama/ollama/blob/main/docs/api.md#list-local-models
+// @Summary List the available models.
+// @Success 200 {object} schema.OllamaTagsResponse "Response"
+// @Router /api/tags [get]
+func TagsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		modelNames, err := services.ListModels(cl, ml, config.NoFilterFn, services.SKIP_IF_CONFIGURED)
+		if err != nil {
+			return sendError(c, fiber.StatusInternalServerError, err.Error())
+		}
+
+		resp := schema.OllamaTagsResponse{Models: []schema.OllamaModel{}}
+		for _, name := range modelNames {
+			m := schema.OllamaModel{Name: name, Model: name}
+
+			fileName := name
+			cfg, exists := cl.GetBackendConfig(name)
+			if exists {
+				fileName = cfg.ModelFileName()
+			}
+			if info, err := os.Stat(filepath.Join(ml.ModelPath, fileName)); err == nil && !info.IsDir() {
+				m.Size = info.Size()
+				m.ModifiedAt = info.ModTime()
+			}
+			m.Details = modelDetails(fileName, cfg)
+			resp.Models = append(resp.Models, m)
+		}
+		return c.JSON(resp)
+	}
+}
+
+// ShowEndpoint returns the configuration of a model like the Ollama API https://github.com/ollama/ollama/blob/main/docs/api.md#show-model-information
+// @Summary Show the configuration of a model.
+// @Param request body schema.OllamaShowRequest true "query params"
+// @Success 200 {object} schema.OllamaShowResponse "Response"
+// @Router /api/show [post]
+func ShowEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.OllamaShowRequest)
+		if err := c.BodyParser(input); err != nil {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+		name := input.Model
+		if name == "" {
+			name = input.Name
+		}
+
+		cfg, exists := cl.GetBackendConfig(name)
+		if !exists {
+			cfg, exists = cl.GetBackendConfig(strings.TrimSuffix(name, ":latest"))
+		}
+		if !exists {
+			return sendError(c, fiber.StatusNotFound, fmt.Sprintf("model '%s' not found", name))
+		}
+
+		return c.JSON(showResponse(cfg))
+	}
+}
+
+// VersionEndpoint returns the version like the Ollama API, clients use it to detect the server
+// @Summary Returns the version of LocalAI.
+// @Router /api/version [get]
+func VersionEndpoint() func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"version": internal.PrintableVersion()})
+	}
+}
+
+func showResponse(cfg config.BackendConfig) schema.OllamaShowResponse {
+	parameters := []string{}
+	addParameter := func(name string, value interface{}) {
+		parameters = append(parameters, fmt.Sprintf("%-30s %v", name, value))
+	}
+	if cfg.Temperature != nil {
+		addParameter("temperature", *cfg.Temperature)
+	}
+	if cfg.TopP != nil {
+		addParameter("top_p", *cfg.TopP)
+	}
+	if cfg.TopK != nil {
+		addParameter("top_k", *cfg.TopK)
+	}
+	if cfg.ContextSize != nil {
+		addParameter("num_ctx", *cfg.ContextSize)
+	}
+	if cfg.Maxtokens != nil {
+		addParameter("num_predict", *cfg.Maxtokens)
+	}
+	if cfg.RepeatPenalty != 0 {
+		addParameter("repeat_penalty", cfg.RepeatPenalty)
+	}
+	for _, stop := range cfg.StopWords {
+		addParameter("stop", fmt.Sprintf("%q", stop))
+	}
+
+	template := cfg.TemplateConfig.Chat
+	if template == "" {
+		template = cfg.TemplateConfig.ChatMessage
+	}
+
+	modelfile := fmt.Sprintf("# Modelfile generated from the LocalAI configuration of %s\nFROM %s\n", cfg.Name, cfg.Model)
+	if template != "" {
+		modelfile += fmt.Sprintf("TEMPLATE \"\"\"%s\"\"\"\n", template)
+	}
+	if cfg.SystemPrompt != "" {
+		modelfile += fmt.Sprintf("SYSTEM \"\"\"%s\"\"\"\n", cfg.SystemPrompt)
+	}
+	for _, p := range parameters {
+		modelfile += "PARAMETER " + p + "\n"
+	}
+
+	capabilities := []string{}
+	if cfg.HasUsecases(config.FLAG_COMPLETION) {
+		capabilities = append(capabilities, "completion")
+	}
+	if cfg.HasUsecases(config.FLAG_EMBEDDINGS) {
+		capabilities = append(capabilities, "embedding")
+	}
+	if cfg.MMProj != "" {
+		capabilities = append(capabilities, "vision")
+	}
+
+	return schema.OllamaShowResponse{
+		Modelfile:    modelfile,
+		Parameters:   strings.Join(parameters, "\n"),
+		Template:     template,
+		System:       cfg.SystemPrompt,
+		Details:      modelDetails(cfg.ModelFileName(), cfg),
+		ModelInfo:    map[string]interface{}{"general.architecture": cfg.Backend},
+		Capabilities: capabilities,
+	}
+}
+
+func modelDetails(fileName string, cfg config.BackendConfig) schema.OllamaModelDetails {
+	details := schema.OllamaModelDetails{
+		Format: strings.TrimPrefix(filepath.Ext(fileName), "."),
+		Family: cfg.Backend,
+	}
+	if cfg.Backend != "" {
+		details.Families = []string{cfg.Backend}
+	}
+	return details
+}
@@ -0,0 +1,121 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateRequestConfigOptions(t *testing.T) {
+	temperature, numPredict, zero := 0.2, 64, 0
+	cfg := &config.BackendConfig{}
+	cfg.StopWords = []string{"</s>"}
+
+	err := updateRequestConfig(cfg, &schema.OllamaOptions{
+		Temperature: &temperature,
+		NumPredict:  &numPredict,
+		Stop:        []string{"\n\n"},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.2, *cfg.Temperature)
+	assert.Equal(t, 64, *cfg.Maxtokens)
+	assert.Equal(t, []string{"</s>", "\n\n"}, cfg.StopWords)
+	assert.Empty(t, cfg.Grammar)
+
+	// a non positive num_predict means no limit
+	assert.NoError(t, updateRequestConfig(cfg, &schema.OllamaOptions{NumPredict: &zero}, nil))
+	assert.Equal(t, 64, *cfg.Maxtokens)
+}
+
+func TestUpdateRequestConfigFormat(t *testing.T) {
+	cfg := &config.BackendConfig{}
+	assert.NoError(t, updateRequestConfig(cfg, nil, "json"))
+	assert.Equal(t, functions.JSONBNF, cfg.Grammar)
+
+	cfg = &config.BackendConfig{}
+	assert.NoError(t, updateRequestConfig(cfg, nil, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+		},
+	}))
+	assert.Contains(t, cfg.Grammar, "name")
+
+	cfg = &config.BackendConfig{}
+	assert.Error(t, updateRequestConfig(cfg, nil, "xml"))
+}
+
+func TestShowResponse(t *testing.T) {
+	temperature := 0.7
+	cfg := config.BackendConfig{
+		Name:    "phi",
+		Backend: "llama-cpp",
+	}
+	cfg.Model = "phi-2.Q4_K_M.gguf"
+	cfg.Temperature = &temperature
+	cfg.SystemPrompt = "You are helpful"
+	cfg.TemplateConfig.Chat = "{{.Input}}"
+
+	resp := showResponse(cfg)
+	assert.Contains(t, resp.Modelfile, "FROM phi-2.Q4_K_M.gguf")
+	assert.Contains(t, resp.Modelfile, "SYSTEM \"\"\"You are helpful\"\"\"")
+	assert.Contains(t, resp.Parameters, "temperature")
+	assert.Equal(t, "{{.Input}}", resp.Template)
+	assert.Equal(t, "gguf", resp.Details.Format)
+	assert.Equal(t, []string{"llama-cpp"}, resp.Details.Families)
+}
+
+func TestShowUnknownModel(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/show", ShowEndpoint(config.NewBackendConfigLoader(t.TempDir()), model.NewModelLoader(t.TempDir())))
+
+	req := httptest.NewRequest("POST", "/api/show", bytes.NewBufferString(`{"model":"missing:latest"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+}
+
+func TestChatBadRequest(t *testing.T) {
+	app := fiber.New()
+	app.Post("/api/chat", ChatEndpoint(&config.BackendConfigLoader{}, model.NewModelLoader(t.TempDir()), nil, &config.ApplicationConfig{}))
+
+	req := httptest.NewRequest("POST", "/api/chat", strings.NewReader(`{"model":`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSendModelError(t *testing.T) {
+	app := fiber.New()
+	var handlerErr error
+	app.Post("/", func(c *fiber.Ctx) error {
+		handlerErr = sendModelError(c, &model.LoadError{ModelID: "test", Err: model.ErrQueueFull})
+		return nil
+	})
+
+	resp, err := app.Test(httptest.NewRequest("POST", "/", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusTooManyRequests, resp.StatusCode)
+	var loadErr *model.LoadError
+	assert.ErrorAs(t, handlerErr, &loadErr)
+}
+
+func TestWaitPullCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := waitPull(ctx, services.NewGalleryService(&config.ApplicationConfig{}), "missing", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,139 @@
+package ollama
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/gallery"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/downloader"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// pullStatusInterval is how often the progress of a pull is checked
+var pullStatusInterval = 500 * time.Millisecond
+
+// PullEndpoint installs a model like the Ollama API https://github.com/ollama/ollama/blob/main/docs/api.md#pull-a-model
+// Models of the galleries are installed from the gallery, the others are pulled from the Ollama registry.
+// @Summary Install a model.
+// @Param request body schema.OllamaPullRequest true "query params"
+// @Success 200 {object} schema.OllamaProgressResponse "Response"
+// @Router /api/pull [post]
+func PullEndpoint(galleryService *services.GalleryService, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		input := new(schema.OllamaPullRequest)
+		if err := c.BodyParser(input); err != nil {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("failed parsing request body: %s", err.Error()))
+		}
+		name := input.Model
+		if name == "" {
+			name = input.Name
+		}
+		if name == "" {
+			return sendError(c, fiber.StatusBadRequest, "model is required")
+		}
+
+		op := gallery.GalleryOp{
+			Id:        uuid.New().String(),
+			Galleries: appConfig.Galleries,
+		}
+		if isGalleryModel(name, appConfig) {
+			op.GalleryModelName = name
+		} else {
+			op.ConfigURL = downloader.OllamaPrefix + strings.TrimPrefix(name, downloader.OllamaPrefix)
+		}
+		galleryService.C <- op
+
+		if input.Stream != nil && !*input.Stream {
+			status, err := waitPull(c.Context(), galleryService, op.Id, nil)
+			if err != nil {
+				return err
+			}
+			if status.Error != nil {
+				return sendError(c, fiber.StatusInternalServerError, status.Error.Error())
+			}
+			return c.JSON(schema.OllamaProgressResponse{Status: "success"})
+		}
+
+		c.Context().SetContentType("application/x-ndjson")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Transfer-Encoding", "chunked")
+
+		ctx, cancel := context.WithCancel(c.Context())
+		c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+			defer cancel()
+			send := func(v interface{}) {
+				data, _ := json.Marshal(v)
+				if _, err := fmt.Fprintf(w, "%s\n", data); err != nil {
+					log.Debug().Msgf("Sending pull progress failed: %v", err)
+					cancel()
+				}
+				if err := w.Flush(); err != nil {
+					cancel()
+				}
+			}
+
+			send(schema.OllamaProgressResponse{Status: "pulling manifest"})
+			status, err := waitPull(ctx, galleryService, op.Id, func(status *gallery.GalleryOpStatus) {
+				if status.FileName == "" {
+					return
+				}
+				send(schema.OllamaProgressResponse{
+					Status:    "pulling " + status.FileName,
+					Digest:    status.FileName,
+					Total:     100,
+					Completed: int64(status.Progress),
+				})
+			})
+			if err != nil {
+				log.Debug().Err(err).Str("model", name).Msg("ollama: stopped waiting for the pull")
+				return
+			}
+			if status.Error != nil {
+				send(schema.OllamaErrorResponse{Error: status.Error.Error()})
+				return
+			}
+			send(schema.OllamaProgressResponse{Status: "success"})
+		}))
+		return nil
+	}
+}
+
+// waitPull waits for the gallery operation to be processed, passing its progress to the callback.
+// It stops waiting when the context is canceled, e.g. when the client goes away, while the operation keeps running.
+func waitPull(ctx context.Context, galleryService *services.GalleryService, id string, progress func(*gallery.GalleryOpStatus)) (*gallery.GalleryOpStatus, error) {
+	ticker := time.NewTicker(pullStatusInterval)
+	defer ticker.Stop()
+	for {
+		status := galleryService.GetStatus(id)
+		if status != nil && status.Processed {
+			return status, nil
+		}
+		if status != nil && progress != nil {
+			progress(status)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func isGalleryModel(name string, appConfig *config.ApplicationConfig) bool {
+	models, err := gallery.AvailableGalleryModels(appConfig.Galleries, appConfig.ModelPath)
+	if err != nil {
+		log.Debug().Err(err).Msg("ollama: failed listing the gallery models")
+		return false
+	}
+	return gallery.FindModel(models, name, appConfig.ModelPath) != nil
+}
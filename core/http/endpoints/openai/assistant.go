@@ -14,6 +14,7 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/functions"
 	model "github.com/mudler/LocalAI/pkg/model"
 	"github.com/mudler/LocalAI/pkg/utils"
 	"github.com/rs/zerolog/log"
@@ -37,7 +38,8 @@ const (
 )
 
 type Tool struct {
-	Type ToolType `json:"type"`
+	Type     ToolType            `json:"type"`
+	Function *functions.Function `json:"function,omitempty"` // The function definition, for tools of type function.
 }
 
 // Assistant represents the structure of an assistant object from the OpenAI API.
@@ -323,19 +325,17 @@ func CreateAssistantFileEndpoint(cl *config.BackendConfigLoader, ml *model.Model
 					return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("Max files %d for assistant %s reached.", MaxFileIdSize, assistant.Name))
 				}
 
-				for _, file := range UploadedFiles {
-					if file.ID == request.FileID {
-						assistant.FileIDs = append(assistant.FileIDs, request.FileID)
-						assistantFile := AssistantFile{
-							ID:          file.ID,
-							Object:      "assistant.file",
-							CreatedAt:   time.Now().Unix(),
-							AssistantID: assistant.ID,
-						}
-						AssistantFiles = append(AssistantFiles, assistantFile)
-						utils.SaveConfig(appConfig.ConfigsDir, AssistantsFileConfigFile, AssistantFiles)
-						return c.Status(fiber.StatusOK).JSON(assistantFile)
+				if file, exists := findUploadedFile(request.FileID); exists {
+					assistant.FileIDs = append(assistant.FileIDs, request.FileID)
+					assistantFile := AssistantFile{
+						ID:          file.ID,
+						Object:      "assistant.file",
+						CreatedAt:   time.Now().Unix(),
+						AssistantID: assistant.ID,
 					}
+					AssistantFiles = append(AssistantFiles, assistantFile)
+					utils.SaveConfig(appConfig.ConfigsDir, AssistantsFileConfigFile, AssistantFiles)
+					return c.Status(fiber.StatusOK).JSON(assistantFile)
 				}
 
 				return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find file_id: %s", request.FileID)))
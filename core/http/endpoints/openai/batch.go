@@ -0,0 +1,605 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// BatchStatus is the status of a batch
+type BatchStatus string
+
+const (
+	BatchStatusValidating BatchStatus = "validating"
+	BatchStatusFailed     BatchStatus = "failed"
+	BatchStatusInProgress BatchStatus = "in_progress"
+	BatchStatusFinalizing BatchStatus = "finalizing"
+	BatchStatusCompleted  BatchStatus = "completed"
+	BatchStatusExpired    BatchStatus = "expired"
+	BatchStatusCancelling BatchStatus = "cancelling"
+	BatchStatusCancelled  BatchStatus = "cancelled"
+
+	// MaxBatchRequests is the maximum number of requests in a batch input file
+	MaxBatchRequests = 50000
+)
+
+// batchEndpoints are the endpoints which can be used in a batch
+var batchEndpoints = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings"}
+
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type BatchError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param,omitempty"`
+	Line    *int   `json:"line,omitempty"`
+}
+
+type BatchErrors struct {
+	Object string       `json:"object"`
+	Data   []BatchError `json:"data"`
+}
+
+// Batch represents the structure of a batch object from the OpenAI API.
+type Batch struct {
+	ID               string             `json:"id"`                       // The unique identifier of the batch.
+	Object           string             `json:"object"`                   // Object type, which is "batch".
+	Endpoint         string             `json:"endpoint"`                 // The endpoint used by the batch.
+	Errors           *BatchErrors       `json:"errors"`                   // Validation errors of the input file.
+	InputFileID      string             `json:"input_file_id"`            // The ID of the input file for the batch.
+	CompletionWindow string             `json:"completion_window"`        // The time frame within which the batch should be processed.
+	Status           BatchStatus        `json:"status"`                   // The current status of the batch.
+	OutputFileID     string             `json:"output_file_id"`           // The ID of the file containing the outputs of successfully executed requests.
+	ErrorFileID      string             `json:"error_file_id"`            // The ID of the file containing the outputs of requests with errors.
+	CreatedAt        int64              `json:"created_at"`               // The time at which the batch was created.
+	InProgressAt     int64              `json:"in_progress_at,omitempty"` // The time at which the batch started processing.
+	ExpiresAt        int64              `json:"expires_at,omitempty"`     // The time at which the batch will expire.
+	FinalizingAt     int64              `json:"finalizing_at,omitempty"`  // The time at which the batch started finalizing.
+	CompletedAt      int64              `json:"completed_at,omitempty"`   // The time at which the batch was completed.
+	FailedAt         int64              `json:"failed_at,omitempty"`      // The time at which the batch failed.
+	ExpiredAt        int64              `json:"expired_at,omitempty"`     // The time at which the batch expired.
+	CancellingAt     int64              `json:"cancelling_at,omitempty"`  // The time at which the batch started cancelling.
+	CancelledAt      int64              `json:"cancelled_at,omitempty"`   // The time at which the batch was cancelled.
+	RequestCounts    BatchRequestCounts `json:"request_counts"`           // The request counts for different statuses within the batch.
+	Metadata         map[string]string  `json:"metadata,omitempty"`       // Set of key-value pairs attached to the batch.
+}
+
+type BatchRequest struct {
+	InputFileID      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// BatchRequestInput is a single line of the input file of a batch
+type BatchRequestInput struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type BatchResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// BatchRequestOutput is a single line of the output or error file of a batch
+type BatchRequestOutput struct {
+	ID       string         `json:"id"`
+	CustomID string         `json:"custom_id"`
+	Response *BatchResponse `json:"response"`
+	Error    *BatchError    `json:"error"`
+}
+
+var (
+	Batches           = []Batch{}
+	BatchesConfigFile = "batches.json"
+
+	// batchesMu guards Batches, which is updated by the batches processed in the background
+	batchesMu    sync.Mutex
+	batchCancels = map[string]context.CancelFunc{}
+)
+
+// batchPriority is the priority of the batch requests in the model queues
+const batchPriority = -1
+
+func saveBatches(appConfig *config.ApplicationConfig) {
+	utils.SaveConfig(appConfig.ConfigsDir, BatchesConfigFile, Batches)
+}
+
+// findBatch returns the batch with the given ID, or nil. It must be called with batchesMu held.
+func findBatch(batchID string) *Batch {
+	for i := range Batches {
+		if Batches[i].ID == batchID {
+			return &Batches[i]
+		}
+	}
+	return nil
+}
+
+// RecoverBatches stops the batches which were processed when LocalAI was stopped, as their progress is lost:
+// they are marked as cancelled if they were being cancelled, as expired if their completion window is over,
+// and as failed otherwise.
+func RecoverBatches(appConfig *config.ApplicationConfig) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+
+	now := time.Now().Unix()
+	recovered := 0
+	for i := range Batches {
+		b := &Batches[i]
+		switch b.Status {
+		case BatchStatusCancelling:
+			b.Status = BatchStatusCancelled
+			b.CancelledAt = now
+		case BatchStatusValidating, BatchStatusInProgress, BatchStatusFinalizing:
+			if b.ExpiresAt != 0 && b.ExpiresAt <= now {
+				b.Status = BatchStatusExpired
+				b.ExpiredAt = now
+				break
+			}
+			b.Status = BatchStatusFailed
+			b.FailedAt = now
+			b.Errors = &BatchErrors{Object: "list", Data: []BatchError{{Code: "server_error", Message: "LocalAI was restarted while the batch was processed"}}}
+		default:
+			continue
+		}
+		recovered++
+	}
+	if recovered == 0 {
+		return
+	}
+
+	log.Warn().Int("batches", recovered).Msg("stopped the batches interrupted by a restart")
+	saveBatches(appConfig)
+}
+
+// batchProcessor executes the requests of batches against the local models, one at a time.
+// Requests are dispatched in-process to the same handlers that serve the API, without the
+// HTTP middlewares (authentication was already performed when the batch was created).
+type batchProcessor struct {
+	router    *fiber.App
+	appConfig *config.ApplicationConfig
+}
+
+func newBatchProcessor(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) *batchProcessor {
+	router := fiber.New(fiber.Config{
+		BodyLimit:             appConfig.UploadLimitMB * 1024 * 1024,
+		DisableStartupMessage: true,
+	})
+	// requests for virtual models are routed to their targets, like the ones served by the API
+	modelRouter := middleware.NewModelRouter(cl, ml, appConfig)
+	router.Post("/v1/chat/completions", modelRouter.Route(ChatEndpoint(cl, ml, evaluator, appConfig)))
+	router.Post("/v1/completions", modelRouter.Route(CompletionEndpoint(cl, ml, evaluator, appConfig)))
+	router.Post("/v1/embeddings", modelRouter.Route(EmbeddingsEndpoint(cl, ml, appConfig)))
+
+	return &batchProcessor{router: router, appConfig: appConfig}
+}
+
+// readBatchInput reads and validates the JSONL input file of a batch
+func readBatchInput(path, endpoint string) ([]BatchRequestInput, []BatchError, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	inputs := []BatchRequestInput{}
+	errs := []BatchError{}
+	customIDs := map[string]bool{}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		l := line
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var input BatchRequestInput
+		if err := json.Unmarshal(scanner.Bytes(), &input); err != nil {
+			errs = append(errs, BatchError{Code: "invalid_json_line", Message: err.Error(), Line: &l})
+			continue
+		}
+
+		switch {
+		case input.CustomID == "":
+			errs = append(errs, BatchError{Code: "missing_required_parameter", Message: "custom_id is required", Param: "custom_id", Line: &l})
+		case customIDs[input.CustomID]:
+			errs = append(errs, BatchError{Code: "duplicate_custom_id", Message: fmt.Sprintf("custom_id %q is not unique", input.CustomID), Param: "custom_id", Line: &l})
+		case input.Method != http.MethodPost:
+			errs = append(errs, BatchError{Code: "invalid_method", Message: "only POST is supported", Param: "method", Line: &l})
+		case input.URL != endpoint:
+			errs = append(errs, BatchError{Code: "mismatched_endpoint", Message: fmt.Sprintf("url %q does not match the batch endpoint %q", input.URL, endpoint), Param: "url", Line: &l})
+		default:
+			customIDs[input.CustomID] = true
+			inputs = append(inputs, input)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(inputs)+len(errs) > MaxBatchRequests {
+		errs = append(errs, BatchError{Code: "too_many_requests", Message: fmt.Sprintf("the input file can contain at most %d requests", MaxBatchRequests)})
+	}
+	if len(inputs) == 0 && len(errs) == 0 {
+		errs = append(errs, BatchError{Code: "empty_file", Message: "the input file does not contain any request"})
+	}
+
+	return inputs, errs, nil
+}
+
+// do executes a single request of a batch
+func (p *batchProcessor) do(input BatchRequestInput) BatchRequestOutput {
+	output := BatchRequestOutput{
+		ID:       newObjectID("batch_req"),
+		CustomID: input.CustomID,
+	}
+
+	// Streaming makes no sense in a batch
+	body := map[string]interface{}{}
+	if err := json.Unmarshal(input.Body, &body); err != nil {
+		output.Error = &BatchError{Code: "invalid_request", Message: fmt.Sprintf("invalid body: %s", err.Error())}
+		return output
+	}
+	body["stream"] = false
+	data, _ := json.Marshal(body)
+
+	req := httptest.NewRequest(http.MethodPost, input.URL, bytes.NewReader(data))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set("X-Correlation-ID", output.ID)
+	// batch requests give way to the interactive ones in the model queues
+	req.Header.Set(fiberContext.PriorityHeader, strconv.Itoa(batchPriority))
+
+	resp, err := p.router.Test(req, -1)
+	if err != nil {
+		output.Error = &BatchError{Code: "server_error", Message: err.Error()}
+		return output
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		output.Error = &BatchError{Code: "server_error", Message: err.Error()}
+		return output
+	}
+	if !json.Valid(respBody) {
+		// Errors not returned as JSON (e.g. with opaque errors) are wrapped so the output stays valid JSONL
+		respBody, _ = json.Marshal(schema.ErrorResponse{Error: &schema.APIError{Message: string(respBody), Code: resp.StatusCode}})
+	}
+
+	output.Response = &BatchResponse{
+		StatusCode: resp.StatusCode,
+		RequestID:  output.ID,
+		Body:       respBody,
+	}
+	return output
+}
+
+// writeBatchFile stores the outputs as a new file, returning its ID
+func (p *batchProcessor) writeBatchFile(batchID, kind string, outputs []BatchRequestOutput) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, o := range outputs {
+		if err := enc.Encode(o); err != nil {
+			return "", err
+		}
+	}
+
+	// the batch IDs are unique across restarts, and so are the file names
+	filename := fmt.Sprintf("%s_%s.jsonl", batchID, kind)
+	if err := os.WriteFile(filepath.Join(p.appConfig.UploadDir, filename), buf.Bytes(), 0600); err != nil {
+		return "", err
+	}
+
+	f := schema.File{
+		ID:        "file-" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Object:    "file",
+		Bytes:     buf.Len(),
+		CreatedAt: time.Now(),
+		Filename:  filename,
+		Purpose:   "batch_output",
+	}
+
+	uploadedFilesMu.Lock()
+	UploadedFiles = append(UploadedFiles, f)
+	utils.SaveConfig(p.appConfig.UploadDir, UploadedFilesFile, UploadedFiles)
+	uploadedFilesMu.Unlock()
+
+	return f.ID, nil
+}
+
+// updateBatch applies fn to the batch and persists the change
+func (p *batchProcessor) updateBatch(batchID string, fn func(*Batch)) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	if b := findBatch(batchID); b != nil {
+		fn(b)
+		saveBatches(p.appConfig)
+	}
+}
+
+// process runs a batch until all its requests are executed, it is cancelled or it expires
+func (p *batchProcessor) process(ctx context.Context, batchID, inputPath, endpoint string) {
+	inputs, errs, err := readBatchInput(inputPath, endpoint)
+	if err == nil && len(errs) > 0 {
+		err = fmt.Errorf("%d lines of the input file are invalid", len(errs))
+	}
+	if err != nil {
+		log.Error().Err(err).Str("batch", batchID).Msg("batch validation failed")
+		if len(errs) == 0 {
+			errs = []BatchError{{Code: "invalid_file", Message: err.Error()}}
+		}
+		p.updateBatch(batchID, func(b *Batch) {
+			b.Status = BatchStatusFailed
+			b.FailedAt = time.Now().Unix()
+			b.Errors = &BatchErrors{Object: "list", Data: errs}
+		})
+		return
+	}
+
+	p.updateBatch(batchID, func(b *Batch) {
+		b.Status = BatchStatusInProgress
+		b.InProgressAt = time.Now().Unix()
+		b.RequestCounts.Total = len(inputs)
+	})
+
+	outputs := []BatchRequestOutput{}
+	failures := []BatchRequestOutput{}
+	for _, input := range inputs {
+		if ctx.Err() != nil {
+			break
+		}
+
+		output := p.do(input)
+		failed := output.Error != nil || output.Response.StatusCode >= 400
+		if failed {
+			failures = append(failures, output)
+		} else {
+			outputs = append(outputs, output)
+		}
+
+		p.updateBatch(batchID, func(b *Batch) {
+			if failed {
+				b.RequestCounts.Failed++
+			} else {
+				b.RequestCounts.Completed++
+			}
+		})
+	}
+
+	p.updateBatch(batchID, func(b *Batch) {
+		if b.Status == BatchStatusInProgress {
+			b.Status = BatchStatusFinalizing
+			b.FinalizingAt = time.Now().Unix()
+		}
+	})
+
+	// Results of the requests executed so far are kept also when the batch is cancelled or expired
+	outputFileID, errorFileID := "", ""
+	if len(outputs) > 0 {
+		if outputFileID, err = p.writeBatchFile(batchID, "output", outputs); err != nil {
+			log.Error().Err(err).Str("batch", batchID).Msg("failed writing batch output file")
+		}
+	}
+	if len(failures) > 0 {
+		if errorFileID, err = p.writeBatchFile(batchID, "error", failures); err != nil {
+			log.Error().Err(err).Str("batch", batchID).Msg("failed writing batch error file")
+		}
+	}
+
+	p.updateBatch(batchID, func(b *Batch) {
+		b.OutputFileID = outputFileID
+		b.ErrorFileID = errorFileID
+		now := time.Now().Unix()
+		switch {
+		case b.Status == BatchStatusCancelling:
+			b.Status = BatchStatusCancelled
+			b.CancelledAt = now
+		case ctx.Err() != nil:
+			b.Status = BatchStatusExpired
+			b.ExpiredAt = now
+		default:
+			b.Status = BatchStatusCompleted
+			b.CompletedAt = now
+		}
+	})
+}
+
+// CreateBatchEndpoint is the OpenAI Batch API endpoint https://platform.openai.com/docs/api-reference/batch/create
+// @Summary Create a batch of requests from an uploaded JSONL file.
+// @Param request body BatchRequest true "query params"
+// @Success 200 {object} Batch "Response"
+// @Router /v1/batches [post]
+func CreateBatchEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	processor := newBatchProcessor(cl, ml, evaluator, appConfig)
+
+	return func(c *fiber.Ctx) error {
+		request := new(BatchRequest)
+		if err := c.BodyParser(request); err != nil {
+			log.Warn().AnErr("Unable to parse BatchRequest", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		supported := false
+		for _, e := range batchEndpoints {
+			if e == request.Endpoint {
+				supported = true
+			}
+		}
+		if !supported {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unsupported endpoint %q, must be one of %v", request.Endpoint, batchEndpoints)))
+		}
+
+		if request.CompletionWindow == "" {
+			request.CompletionWindow = "24h"
+		}
+		window, err := time.ParseDuration(request.CompletionWindow)
+		if err != nil || window <= 0 {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Invalid completion_window %q", request.CompletionWindow)))
+		}
+
+		inputFile, exists := findUploadedFile(request.InputFileID)
+		if !exists {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find file_id: %s", request.InputFileID)))
+		}
+		if inputFile.Purpose != "batch" {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("File %s must be uploaded with purpose \"batch\"", request.InputFileID)))
+		}
+
+		if request.Metadata == nil {
+			request.Metadata = make(map[string]string)
+		}
+
+		now := time.Now()
+		batch := Batch{
+			ID:               newObjectID("batch"),
+			Object:           "batch",
+			Endpoint:         request.Endpoint,
+			InputFileID:      request.InputFileID,
+			CompletionWindow: request.CompletionWindow,
+			Status:           BatchStatusValidating,
+			CreatedAt:        now.Unix(),
+			ExpiresAt:        now.Add(window).Unix(),
+			Metadata:         request.Metadata,
+		}
+
+		ctx, cancel := context.WithDeadline(appConfig.Context, now.Add(window))
+
+		batchesMu.Lock()
+		Batches = append(Batches, batch)
+		batchCancels[batch.ID] = cancel
+		saveBatches(appConfig)
+		batchesMu.Unlock()
+
+		go func() {
+			processor.process(ctx, batch.ID, filepath.Join(appConfig.UploadDir, inputFile.Filename), batch.Endpoint)
+
+			batchesMu.Lock()
+			delete(batchCancels, batch.ID)
+			batchesMu.Unlock()
+			cancel()
+		}()
+
+		return c.Status(fiber.StatusOK).JSON(batch)
+	}
+}
+
+// GetBatchEndpoint is the OpenAI Batch API endpoint to retrieve a batch https://platform.openai.com/docs/api-reference/batch/retrieve
+// @Summary Retrieves a batch.
+// @Success 200 {object} Batch "Response"
+// @Router /v1/batches/{batch_id} [get]
+func GetBatchEndpoint(cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		batchID := c.Params("batch_id")
+		if batchID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter batch_id is required")
+		}
+
+		batchesMu.Lock()
+		defer batchesMu.Unlock()
+
+		batch := findBatch(batchID)
+		if batch == nil {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find batch with id: %s", batchID)))
+		}
+		return c.Status(fiber.StatusOK).JSON(batch)
+	}
+}
+
+// ListBatchesEndpoint is the OpenAI Batch API endpoint to list batches https://platform.openai.com/docs/api-reference/batch/list
+// @Summary List batches.
+// @Param limit query int false "Limit the number of batches returned"
+// @Param after query string false "Return batches after the given ID"
+// @Success 200 {object} ListResponse "Response"
+// @Router /v1/batches [get]
+func ListBatchesEndpoint(cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		batchesMu.Lock()
+		batches := make([]Batch, len(Batches))
+		copy(batches, Batches)
+		batchesMu.Unlock()
+
+		response, err := paginate(c, batches,
+			func(b Batch) string { return b.ID },
+			func(b Batch) int64 { return b.CreatedAt },
+		)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// CancelBatchEndpoint is the OpenAI Batch API endpoint to cancel a batch https://platform.openai.com/docs/api-reference/batch/cancel
+// @Summary Cancels an in-progress batch.
+// @Success 200 {object} Batch "Response"
+// @Router /v1/batches/{batch_id}/cancel [post]
+func CancelBatchEndpoint(cl *config.BackendConfigLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		batchID := c.Params("batch_id")
+		if batchID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter batch_id is required")
+		}
+
+		batchesMu.Lock()
+		defer batchesMu.Unlock()
+
+		batch := findBatch(batchID)
+		if batch == nil {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find batch with id: %s", batchID)))
+		}
+
+		switch batch.Status {
+		case BatchStatusValidating, BatchStatusInProgress:
+		default:
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Cannot cancel batch with status: %s", batch.Status)))
+		}
+
+		// The batch is marked as cancelled once the request in progress completes
+		batch.Status = BatchStatusCancelling
+		batch.CancellingAt = time.Now().Unix()
+		if cancel, ok := batchCancels[batchID]; ok {
+			cancel()
+		} else {
+			// Nothing is processing the batch (e.g. LocalAI was restarted)
+			batch.Status = BatchStatusCancelled
+			batch.CancelledAt = time.Now().Unix()
+		}
+		saveBatches(appConfig)
+
+		return c.Status(fiber.StatusOK).JSON(batch)
+	}
+}
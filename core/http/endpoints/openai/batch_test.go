@@ -0,0 +1,171 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func tearDownBatches() func() {
+	return func() {
+		batchesMu.Lock()
+		Batches = []Batch{}
+		batchesMu.Unlock()
+		UploadedFiles = []schema.File{}
+	}
+}
+
+func TestBatchEndpoints(t *testing.T) {
+	cl := &config.BackendConfigLoader{}
+	modelPath := "/tmp/localai/model"
+	ml := model.NewModelLoader(modelPath)
+	evaluator := templates.NewEvaluator(modelPath)
+
+	appConfig := &config.ApplicationConfig{
+		Context:       context.Background(),
+		ConfigsDir:    configsDir,
+		UploadDir:     t.TempDir(),
+		ModelPath:     modelPath,
+		UploadLimitMB: 10,
+	}
+	_ = os.MkdirAll(appConfig.ConfigsDir, 0750)
+
+	app := fiber.New()
+	app.Post("/batches", CreateBatchEndpoint(cl, ml, evaluator, appConfig))
+	app.Get("/batches", ListBatchesEndpoint(cl, appConfig))
+	app.Get("/batches/:batch_id", GetBatchEndpoint(cl, appConfig))
+	app.Post("/batches/:batch_id/cancel", CancelBatchEndpoint(cl, appConfig))
+
+	uploadFile := func(t *testing.T, id, purpose string, lines ...string) {
+		filename := id + ".jsonl"
+		err := os.WriteFile(filepath.Join(appConfig.UploadDir, filename), []byte(strings.Join(lines, "\n")), 0600)
+		assert.NoError(t, err)
+		UploadedFiles = append(UploadedFiles, schema.File{ID: id, Object: "file", Filename: filename, Purpose: purpose})
+	}
+
+	t.Run("CreateBatchValidation", func(t *testing.T) {
+		t.Cleanup(tearDownBatches())
+		uploadFile(t, "file-1", "assistants", `{"custom_id":"1","method":"POST","url":"/v1/chat/completions","body":{}}`)
+
+		resp := doJSON(t, app, http.MethodPost, "/batches", BatchRequest{InputFileID: "file-1", Endpoint: "/v1/images/generations"}, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		resp = doJSON(t, app, http.MethodPost, "/batches", BatchRequest{InputFileID: "file-1", Endpoint: "/v1/chat/completions", CompletionWindow: "forever"}, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		resp = doJSON(t, app, http.MethodPost, "/batches", BatchRequest{InputFileID: "file-missing", Endpoint: "/v1/chat/completions"}, nil)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+		resp = doJSON(t, app, http.MethodPost, "/batches", BatchRequest{InputFileID: "file-1", Endpoint: "/v1/chat/completions"}, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, Batches)
+	})
+
+	t.Run("InvalidInputFile", func(t *testing.T) {
+		t.Cleanup(tearDownBatches())
+		uploadFile(t, "file-2", "batch",
+			`{"custom_id":"1","method":"POST","url":"/v1/chat/completions","body":{}}`,
+			`{"custom_id":"1","method":"POST","url":"/v1/chat/completions","body":{}}`,
+			`{"custom_id":"2","method":"GET","url":"/v1/chat/completions","body":{}}`,
+			`{"custom_id":"3","method":"POST","url":"/v1/embeddings","body":{}}`,
+			`not json`,
+		)
+
+		var batch Batch
+		resp := doJSON(t, app, http.MethodPost, "/batches", BatchRequest{InputFileID: "file-2", Endpoint: "/v1/chat/completions"}, &batch)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "batch", batch.Object)
+		assert.Equal(t, "24h", batch.CompletionWindow)
+
+		assert.Eventually(t, func() bool {
+			doJSON(t, app, http.MethodGet, fmt.Sprintf("/batches/%s", batch.ID), nil, &batch)
+			return batch.Status == BatchStatusFailed
+		}, 5*time.Second, 10*time.Millisecond)
+
+		codes := []string{}
+		for _, e := range batch.Errors.Data {
+			codes = append(codes, e.Code)
+		}
+		assert.Equal(t, []string{"duplicate_custom_id", "invalid_method", "mismatched_endpoint", "invalid_json_line"}, codes)
+		assert.Equal(t, 2, *batch.Errors.Data[0].Line)
+	})
+
+	t.Run("ListAndCancel", func(t *testing.T) {
+		t.Cleanup(tearDownBatches())
+
+		// Simulate batches left over from a previous execution
+		Batches = append(Batches,
+			Batch{ID: "batch_1", Object: "batch", Status: BatchStatusCompleted, CreatedAt: 1},
+			Batch{ID: "batch_2", Object: "batch", Status: BatchStatusInProgress, CreatedAt: 2},
+		)
+
+		var list ListResponse
+		resp := doJSON(t, app, http.MethodGet, "/batches?limit=1", nil, &list)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.True(t, list.HasMore)
+		assert.Equal(t, "batch_2", list.FirstID)
+
+		resp = doJSON(t, app, http.MethodPost, "/batches/batch_1/cancel", nil, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		var batch Batch
+		resp = doJSON(t, app, http.MethodPost, "/batches/batch_2/cancel", nil, &batch)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, BatchStatusCancelled, batch.Status)
+
+		resp = doJSON(t, app, http.MethodGet, "/batches/batch_missing", nil, nil)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestRecoverBatches(t *testing.T) {
+	t.Cleanup(tearDownBatches())
+	appConfig := &config.ApplicationConfig{ConfigsDir: configsDir}
+	_ = os.MkdirAll(appConfig.ConfigsDir, 0750)
+
+	future := time.Now().Add(time.Hour).Unix()
+	Batches = []Batch{
+		{ID: "batch_1", Status: BatchStatusCompleted, ExpiresAt: future},
+		{ID: "batch_2", Status: BatchStatusInProgress, ExpiresAt: future},
+		{ID: "batch_3", Status: BatchStatusFinalizing, ExpiresAt: 1},
+		{ID: "batch_4", Status: BatchStatusCancelling, ExpiresAt: future},
+	}
+
+	RecoverBatches(appConfig)
+
+	assert.Equal(t, BatchStatusCompleted, Batches[0].Status)
+	assert.Equal(t, BatchStatusFailed, Batches[1].Status)
+	assert.Equal(t, "server_error", Batches[1].Errors.Data[0].Code)
+	assert.Equal(t, BatchStatusExpired, Batches[2].Status)
+	assert.Equal(t, BatchStatusCancelled, Batches[3].Status)
+}
+
+func TestReadBatchInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	err := os.WriteFile(path, []byte(`{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"input":"hello"}}
+
+{"custom_id":"b","method":"POST","url":"/v1/embeddings","body":{"input":"world"}}
+`), 0600)
+	assert.NoError(t, err)
+
+	inputs, errs, err := readBatchInput(path, "/v1/embeddings")
+	assert.NoError(t, err)
+	assert.Empty(t, errs)
+	assert.Len(t, inputs, 2)
+	assert.Equal(t, "b", inputs[1].CustomID)
+
+	_, _, err = readBatchInput(filepath.Join(t.TempDir(), "missing.jsonl"), "/v1/embeddings")
+	assert.Error(t, err)
+}
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,6 +16,7 @@ import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
 	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/mudler/LocalAI/pkg/functions/grammars"
 	"github.com/mudler/LocalAI/pkg/templates"
 
 	model "github.com/mudler/LocalAI/pkg/model"
@@ -30,17 +33,37 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 	var id, textContentToReturn string
 	var created int
 
-	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) {
-		initialMessage := schema.OpenAIResponse{
-			ID:      id,
-			Created: created,
-			Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-			Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn}}},
-			Object:  "chat.completion.chunk",
+	// process streams the choices, and validates their outputs at the end if validateOutput is set
+	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool, validateOutput bool, outputSchema interface{}) error {
+		for i := 0; i < max(req.N, 1); i++ {
+			initialMessage := schema.OpenAIResponse{
+				ID:      id,
+				Created: created,
+				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+				Choices: []schema.Choice{{Delta: &schema.Message{Role: "assistant", Content: &textContentToReturn}, Index: i}},
+				Object:  "chat.completion.chunk",
+			}
+			responses <- initialMessage
 		}
-		responses <- initialMessage
 
-		ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, tokenUsage backend.TokenUsage) bool {
+		// the choices may be generated concurrently, each chunk carries the usage of all of them
+		var usageMu sync.Mutex
+		choicesUsage := make([]backend.TokenUsage, max(req.N, 1))
+		outputs := make([]strings.Builder, max(req.N, 1))
+
+		_, _, err := ComputeChoices(req, s, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(index int, s string, choiceUsage backend.TokenUsage, logprobs []schema.LogprobContent) bool {
+			usageMu.Lock()
+			choicesUsage[index] = choiceUsage
+			outputs[index].WriteString(s)
+			tokenUsage := backend.TokenUsage{}
+			for _, u := range choicesUsage {
+				tokenUsage.Prompt = max(tokenUsage.Prompt, u.Prompt)
+				tokenUsage.Completion += u.Completion
+				tokenUsage.TimingPromptProcessing += u.TimingPromptProcessing
+				tokenUsage.TimingTokenGeneration += u.TimingTokenGeneration
+			}
+			usageMu.Unlock()
+
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
 				CompletionTokens: tokenUsage.Completion,
@@ -51,11 +74,16 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
 			}
 
+			choice := schema.Choice{Delta: &schema.Message{Content: &s}, Index: index}
+			if config.Logprobs && len(logprobs) > 0 {
+				choice.Logprobs = &schema.Logprobs{Content: logprobs}
+			}
+
 			resp := schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{{Delta: &schema.Message{Content: &s}, Index: 0}},
+				Choices: []schema.Choice{choice},
 				Object:  "chat.completion.chunk",
 				Usage:   usage,
 			}
@@ -63,22 +91,65 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			responses <- resp
 			return true
 		})
-		close(responses)
+		if err != nil || !validateOutput {
+			return err
+		}
+		for i := range outputs {
+			if err := validateStreamedOutput(outputs[i].String(), choicesUsage[i], outputSchema, config); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// toolCallChunk returns the chunk streaming the name or a fragment of the arguments of a tool call
+	toolCallChunk := func(req *schema.OpenAIRequest, index int, name, args string, content *string) schema.OpenAIResponse {
+		return schema.OpenAIResponse{
+			ID:      id,
+			Created: created,
+			Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
+			Choices: []schema.Choice{{
+				Delta: &schema.Message{
+					Role:    "assistant",
+					Content: content,
+					ToolCalls: []schema.ToolCall{
+						{
+							Index: index,
+							ID:    id,
+							Type:  "function",
+							FunctionCall: schema.FunctionCall{
+								Name:      name,
+								Arguments: args,
+							},
+						},
+					},
+				}}},
+			Object: "chat.completion.chunk",
+		}
 	}
-	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) {
+	processTools := func(noAction string, prompt string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) (backend.TokenUsage, error) {
 		result := ""
-		_, tokenUsage, _ := ComputeChoices(req, prompt, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(s string, usage backend.TokenUsage) bool {
+		streamer := newToolCallStreamer(noAction, req, config)
+		_, tokenUsage, _ := ComputeChoices(req, prompt, config, startupOptions, loader, func(s string, c *[]schema.Choice) {}, func(index int, s string, usage backend.TokenUsage, _ []schema.LogprobContent) bool {
+			// tool calls are streamed only for the first choice
+			if index != 0 {
+				return true
+			}
 			result += s
-			// TODO: Change generated BNF grammar to be compliant with the schema so we can
-			// stream the result token by token here.
+			for _, delta := range streamer.update(result) {
+				responses <- toolCallChunk(req, delta.Index, delta.FunctionCall.Name, delta.FunctionCall.Arguments, nil)
+			}
 			return true
 		})
+		streamed := len(streamer.streamed)
 
 		textContentToReturn = functions.ParseTextContent(result, config.FunctionsConfig)
 		result = functions.CleanupLLMResult(result, config.FunctionsConfig)
-		functionResults := functions.ParseFunctionCall(result, config.FunctionsConfig)
+		functionResults, err := toolCallResults(functions.ParseFunctionCall(result, config.FunctionsConfig), noAction, req, config)
+		if err != nil {
+			return tokenUsage, err
+		}
 		log.Debug().Msgf("Text content to return: %s", textContentToReturn)
-		noActionToRun := len(functionResults) > 0 && functionResults[0].Name == noAction || len(functionResults) == 0
+		noActionToRun := streamed == 0 && (len(functionResults) > 0 && functionResults[0].Name == noAction || len(functionResults) == 0)
 
 		switch {
 		case noActionToRun:
@@ -94,7 +165,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			result, err := handleQuestion(config, req, ml, startupOptions, functionResults, result, prompt)
 			if err != nil {
 				log.Error().Err(err).Msg("error handling question")
-				return
+				return tokenUsage, nil
 			}
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
@@ -118,56 +189,17 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			responses <- resp
 
 		default:
+			// send the calls which were not streamed while being generated
 			for i, ss := range functionResults {
-				name, args := ss.Name, ss.Arguments
-
-				initialMessage := schema.OpenAIResponse{
-					ID:      id,
-					Created: created,
-					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-					Choices: []schema.Choice{{
-						Delta: &schema.Message{
-							Role: "assistant",
-							ToolCalls: []schema.ToolCall{
-								{
-									Index: i,
-									ID:    id,
-									Type:  "function",
-									FunctionCall: schema.FunctionCall{
-										Name: name,
-									},
-								},
-							},
-						}}},
-					Object: "chat.completion.chunk",
-				}
-				responses <- initialMessage
-
-				responses <- schema.OpenAIResponse{
-					ID:      id,
-					Created: created,
-					Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-					Choices: []schema.Choice{{
-						Delta: &schema.Message{
-							Role:    "assistant",
-							Content: &textContentToReturn,
-							ToolCalls: []schema.ToolCall{
-								{
-									Index: i,
-									ID:    id,
-									Type:  "function",
-									FunctionCall: schema.FunctionCall{
-										Arguments: args,
-									},
-								},
-							},
-						}}},
-					Object: "chat.completion.chunk",
+				if i < streamed {
+					continue
 				}
+				responses <- toolCallChunk(req, i, ss.Name, "", nil)
+				responses <- toolCallChunk(req, i, "", ss.Arguments, &textContentToReturn)
 			}
 		}
 
-		close(responses)
+		return tokenUsage, nil
 	}
 
 	return func(c *fiber.Ctx) error {
@@ -188,6 +220,8 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
+		// the usage requested with the stream options carries the timings too
+		extraUsage = extraUsage || includeUsage(input)
 
 		config, input, err := mergeRequestWithConfig(modelFile, input, cl, ml, startupOptions.Debug, startupOptions.Threads, startupOptions.ContextSize, startupOptions.F16)
 		if err != nil {
@@ -218,6 +252,10 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			noActionDescription = config.FunctionsConfig.NoActionDescriptionName
 		}
 
+		// outputs of strict json_schema requests, or of models requiring it, are validated
+		validateOutput := false
+		var outputSchema interface{}
+
 		if config.ResponseFormatMap != nil {
 			d := schema.ChatCompletionResponseFormat{}
 			dat, err := json.Marshal(config.ResponseFormatMap)
@@ -230,6 +268,7 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			}
 			if d.Type == "json_object" {
 				input.Grammar = functions.JSONBNF
+				validateOutput = config.StructuredOutput.Validate
 			} else if d.Type == "json_schema" {
 				d := schema.JsonSchemaRequest{}
 				dat, err := json.Marshal(config.ResponseFormatMap)
@@ -247,6 +286,8 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 				if err == nil {
 					input.Grammar = g
 				}
+				validateOutput = d.JsonSchema.Strict || config.StructuredOutput.Validate
+				outputSchema = responseFormatSchema(config.ResponseFormatMap)
 			}
 		}
 
@@ -270,8 +311,8 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 				},
 			}
 
-			// Append the no action function
-			if !config.FunctionsConfig.DisableNoAction {
+			// Append the no action function, unless the request requires calling a function
+			if !config.FunctionsConfig.DisableNoAction && !config.ShouldCallAnyFunction() {
 				funcs = append(funcs, noActionGrammar)
 			}
 
@@ -281,8 +322,12 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 			}
 
 			// Update input grammar
+			grammarOpts := config.FunctionsConfig.GrammarOptions()
+			if config.ShouldCallAnyFunction() || config.FunctionToCall() != "" {
+				grammarOpts = append(grammarOpts, grammars.RequireFunctionCall)
+			}
 			jsStruct := funcs.ToJSONStructure(config.FunctionsConfig.FunctionNameKey, config.FunctionsConfig.FunctionNameKey)
-			g, err := jsStruct.Grammar(config.FunctionsConfig.GrammarOptions()...)
+			g, err := jsStruct.Grammar(grammarOpts...)
 			if err == nil {
 				config.Grammar = g
 			}
@@ -320,6 +365,10 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 
 		switch {
 		case toStream:
+			// the status of the response can't change once the stream starts
+			if err := ml.Available(backend.ModelID(*config)); err != nil {
+				return err
+			}
 
 			log.Debug().Msgf("Stream request received")
 			c.Context().SetContentType("text/event-stream")
@@ -332,10 +381,20 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 
 			responses := make(chan schema.OpenAIResponse)
 
+			// the result of the tools processing is set before closing the responses
+			var streamErr error
+			var toolsUsage *backend.TokenUsage
 			if !shouldUseFn {
-				go process(predInput, input, config, ml, responses, extraUsage)
+				go func() {
+					defer close(responses)
+					streamErr = process(predInput, input, config, ml, responses, extraUsage, validateOutput, outputSchema)
+				}()
 			} else {
-				go processTools(noActionName, predInput, input, config, ml, responses, extraUsage)
+				go func() {
+					defer close(responses)
+					tokenUsage, err := processTools(noActionName, predInput, input, config, ml, responses, extraUsage)
+					toolsUsage, streamErr = &tokenUsage, err
+				}()
 			}
 
 			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
@@ -358,6 +417,24 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 					w.Flush()
 				}
 
+				if streamErr != nil {
+					sendStreamError(w, streamErr)
+					return
+				}
+
+				// the chunks of the tool calls don't carry the usage
+				if toolsUsage != nil {
+					usage = &schema.OpenAIUsage{
+						PromptTokens:     toolsUsage.Prompt,
+						CompletionTokens: toolsUsage.Completion,
+						TotalTokens:      toolsUsage.Prompt + toolsUsage.Completion,
+					}
+					if extraUsage {
+						usage.TimingTokenGeneration = toolsUsage.TimingTokenGeneration
+						usage.TimingPromptProcessing = toolsUsage.TimingPromptProcessing
+					}
+				}
+
 				finishReason := "stop"
 				if toolsCalled {
 					finishReason = "tool_calls"
@@ -365,22 +442,30 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 					finishReason = "function_call"
 				}
 
+				// tool calls are returned only for the first choice
+				choices := []schema.Choice{}
+				for i := 0; i < max(input.N, 1) && (i == 0 || !shouldUseFn); i++ {
+					choices = append(choices, schema.Choice{
+						FinishReason: finishReason,
+						Index:        i,
+						Delta:        &schema.Message{Content: &textContentToReturn},
+					})
+				}
+
 				resp := &schema.OpenAIResponse{
 					ID:      id,
 					Created: created,
 					Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-					Choices: []schema.Choice{
-						{
-							FinishReason: finishReason,
-							Index:        0,
-							Delta:        &schema.Message{Content: &textContentToReturn},
-						}},
-					Object: "chat.completion.chunk",
-					Usage:  *usage,
+					Choices: choices,
+					Object:  "chat.completion.chunk",
+					Usage:   *usage,
 				}
 				respData, _ := json.Marshal(resp)
 
 				w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
+				if includeUsage(input) {
+					w.WriteString(fmt.Sprintf("data: %s\n\n", usageChunk(resp)))
+				}
 				w.WriteString("data: [DONE]\n\n")
 				w.Flush()
 			}))
@@ -388,7 +473,8 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 
 		// no streaming mode
 		default:
-			result, tokenUsage, err := ComputeChoices(input, predInput, config, startupOptions, ml, func(s string, c *[]schema.Choice) {
+			var toolCallErr error
+			choices := func(s string, c *[]schema.Choice) {
 				if !shouldUseFn {
 					// no function is called, just reply and use stop as finish reason
 					*c = append(*c, schema.Choice{FinishReason: "stop", Index: 0, Message: &schema.Message{Role: "assistant", Content: &s}})
@@ -397,7 +483,11 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 
 				textContentToReturn = functions.ParseTextContent(s, config.FunctionsConfig)
 				s = functions.CleanupLLMResult(s, config.FunctionsConfig)
-				results := functions.ParseFunctionCall(s, config.FunctionsConfig)
+				results, err := toolCallResults(functions.ParseFunctionCall(s, config.FunctionsConfig), noActionName, input, config)
+				if err != nil {
+					toolCallErr = err
+					return
+				}
 				log.Debug().Msgf("Text content to return: %s", textContentToReturn)
 				noActionsToRun := len(results) > 0 && results[0].Name == noActionName || len(results) == 0
 
@@ -459,10 +549,23 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 					}
 				}
 
-			}, nil)
+			}
+			result, tokenUsage, err := ComputeChoices(input, predInput, config, startupOptions, ml, choices, nil)
 			if err != nil {
 				return err
 			}
+			if toolCallErr != nil {
+				return toolCallErr
+			}
+			if validateOutput && !shouldUseFn {
+				result, tokenUsage, err = validateStructuredOutputs(input, predInput, outputSchema, result, tokenUsage, config, startupOptions, ml, evaluator, choices)
+				if errors.Is(err, ErrInvalidStructuredOutput) {
+					return sendInvalidOutput(c, err)
+				}
+				if err != nil {
+					return err
+				}
+			}
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
 				CompletionTokens: tokenUsage.Completion,
@@ -490,6 +593,75 @@ func ChatEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluat
 	}
 }
 
+// toolCallStreamer extracts the tool calls from the output of the LLM while it is generated, so they can be
+// streamed incrementally: the name of each call first, then the fragments of its arguments.
+type toolCallStreamer struct {
+	noAction string
+	req      *schema.OpenAIRequest
+	config   *config.BackendConfig
+	disabled bool
+	// streamed holds the length of the arguments already sent for each call
+	streamed []int
+}
+
+func newToolCallStreamer(noAction string, req *schema.OpenAIRequest, config *config.BackendConfig) *toolCallStreamer {
+	return &toolCallStreamer{
+		noAction: noAction,
+		req:      req,
+		config:   config,
+		disabled: !config.FunctionsConfig.SupportsPartialParsing(),
+	}
+}
+
+// update returns the tool call deltas to send for the output generated so far
+func (t *toolCallStreamer) update(result string) []schema.ToolCall {
+	if t.disabled {
+		return nil
+	}
+
+	deltas := []schema.ToolCall{}
+	for i, call := range functions.ParsePartialFunctionCall(result, t.config.FunctionsConfig) {
+		if i == len(t.streamed) {
+			// the no action reply and the calls not allowed by the request are handled at the end of the generation
+			if call.Name == t.noAction || (t.config.FunctionToCall() != "" && call.Name != t.config.FunctionToCall()) ||
+				(i > 0 && t.req.ParallelToolCalls != nil && !*t.req.ParallelToolCalls) {
+				t.disabled = true
+				break
+			}
+			deltas = append(deltas, schema.ToolCall{Index: i, FunctionCall: schema.FunctionCall{Name: call.Name}})
+			t.streamed = append(t.streamed, 0)
+		}
+		if len(call.Arguments) > t.streamed[i] {
+			deltas = append(deltas, schema.ToolCall{Index: i, FunctionCall: schema.FunctionCall{Arguments: call.Arguments[t.streamed[i]:]}})
+			t.streamed[i] = len(call.Arguments)
+		}
+	}
+	return deltas
+}
+
+// toolCallResults applies the tool choice of the request to the function calls parsed from the LLM output.
+// Grammars already constrain the output, this enforces the same rules for the models parsed without grammars.
+func toolCallResults(results []functions.FuncCallResults, noActionName string, input *schema.OpenAIRequest, config *config.BackendConfig) ([]functions.FuncCallResults, error) {
+	if config.ShouldCallAnyFunction() || config.FunctionToCall() != "" {
+		calls := []functions.FuncCallResults{}
+		for _, r := range results {
+			if r.Name == noActionName || (config.FunctionToCall() != "" && r.Name != config.FunctionToCall()) {
+				continue
+			}
+			calls = append(calls, r)
+		}
+		if len(calls) == 0 {
+			return nil, fmt.Errorf("the model did not call any of the tools required by tool_choice")
+		}
+		results = calls
+	}
+
+	if input.ParallelToolCalls != nil && !*input.ParallelToolCalls && len(results) > 1 {
+		results = results[:1]
+	}
+	return results, nil
+}
+
 func handleQuestion(config *config.BackendConfig, input *schema.OpenAIRequest, ml *model.ModelLoader, o *config.ApplicationConfig, funcResults []functions.FuncCallResults, result, prompt string) (string, error) {
 
 	if len(funcResults) == 0 && result != "" {
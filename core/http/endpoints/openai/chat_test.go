@@ -0,0 +1,121 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolChoiceRequest(t *testing.T) {
+	for _, tc := range []struct {
+		body           string
+		shouldUseFn    bool
+		callAny        bool
+		functionToCall string
+		parallelCalls  bool
+	}{
+		{body: `{}`, shouldUseFn: true},
+		{body: `{"tool_choice": "auto"}`, shouldUseFn: true},
+		{body: `{"tool_choice": "none"}`},
+		{body: `{"tool_choice": "required"}`, shouldUseFn: true, callAny: true},
+		{body: `{"tool_choice": {"type": "function", "function": {"name": "search"}}}`, shouldUseFn: true, functionToCall: "search"},
+		{body: `{"tool_choice": "required", "parallel_tool_calls": true}`, shouldUseFn: true, callAny: true, parallelCalls: true},
+	} {
+		input := &schema.OpenAIRequest{}
+		assert.NoError(t, json.Unmarshal([]byte(tc.body), input))
+
+		cfg := &config.BackendConfig{}
+		updateRequestConfig(cfg, input)
+		assert.Equal(t, tc.shouldUseFn, cfg.ShouldUseFunctions(), tc.body)
+		assert.Equal(t, tc.callAny, cfg.ShouldCallAnyFunction(), tc.body)
+		assert.Equal(t, tc.functionToCall, cfg.FunctionToCall(), tc.body)
+		assert.Equal(t, tc.parallelCalls, cfg.FunctionsConfig.GrammarConfig.ParallelCalls, tc.body)
+	}
+}
+
+func TestToolCallResults(t *testing.T) {
+	search := functions.FuncCallResults{Name: "search", Arguments: `{"query": "weather"}`}
+	lookup := functions.FuncCallResults{Name: "lookup", Arguments: `{"id": 1}`}
+	answer := functions.FuncCallResults{Name: "answer", Arguments: `{"message": "hi"}`}
+	noParallel := false
+
+	request := func(body string) (*schema.OpenAIRequest, *config.BackendConfig) {
+		input := &schema.OpenAIRequest{}
+		assert.NoError(t, json.Unmarshal([]byte(body), input))
+		cfg := &config.BackendConfig{}
+		updateRequestConfig(cfg, input)
+		return input, cfg
+	}
+
+	// without a tool choice, the results are returned as they are
+	input, cfg := request(`{}`)
+	results, err := toolCallResults([]functions.FuncCallResults{answer}, "answer", input, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []functions.FuncCallResults{answer}, results)
+
+	input, cfg = request(`{"tool_choice": "required"}`)
+	results, err = toolCallResults([]functions.FuncCallResults{answer, search}, "answer", input, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []functions.FuncCallResults{search}, results)
+	_, err = toolCallResults([]functions.FuncCallResults{answer}, "answer", input, cfg)
+	assert.Error(t, err)
+	_, err = toolCallResults(nil, "answer", input, cfg)
+	assert.Error(t, err)
+
+	input, cfg = request(`{"tool_choice": {"type": "function", "function": {"name": "lookup"}}}`)
+	results, err = toolCallResults([]functions.FuncCallResults{search, lookup}, "answer", input, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []functions.FuncCallResults{lookup}, results)
+	_, err = toolCallResults([]functions.FuncCallResults{search}, "answer", input, cfg)
+	assert.Error(t, err)
+
+	input, cfg = request(`{}`)
+	input.ParallelToolCalls = &noParallel
+	results, err = toolCallResults([]functions.FuncCallResults{search, lookup}, "answer", input, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []functions.FuncCallResults{search}, results)
+}
+
+func TestToolCallStreamer(t *testing.T) {
+	output := `[{"name": "search", "arguments": {"query": "weather in Rome"}}, {"name": "lookup", "arguments": {"id": 1}}]`
+
+	stream := func(streamer *toolCallStreamer) map[int]*schema.FunctionCall {
+		calls := map[int]*schema.FunctionCall{}
+		for i := range output {
+			for _, delta := range streamer.update(output[:i+1]) {
+				if delta.FunctionCall.Name != "" {
+					assert.NotContains(t, calls, delta.Index)
+					calls[delta.Index] = &schema.FunctionCall{Name: delta.FunctionCall.Name}
+				}
+				calls[delta.Index].Arguments += delta.FunctionCall.Arguments
+			}
+		}
+		return calls
+	}
+
+	calls := stream(newToolCallStreamer("answer", &schema.OpenAIRequest{}, &config.BackendConfig{}))
+	assert.Len(t, calls, 2)
+	assert.Equal(t, "search", calls[0].Name)
+	assert.JSONEq(t, `{"query": "weather in Rome"}`, calls[0].Arguments)
+	assert.Equal(t, "lookup", calls[1].Name)
+	assert.JSONEq(t, `{"id": 1}`, calls[1].Arguments)
+
+	// the calls after the first one are left to the end of the generation without parallel calls
+	noParallel := false
+	calls = stream(newToolCallStreamer("answer", &schema.OpenAIRequest{ParallelToolCalls: &noParallel}, &config.BackendConfig{}))
+	assert.Len(t, calls, 1)
+
+	// the no action reply is not streamed as a tool call
+	streamer := newToolCallStreamer("search", &schema.OpenAIRequest{}, &config.BackendConfig{})
+	assert.Empty(t, stream(streamer))
+	assert.Empty(t, streamer.streamed)
+
+	// regexes need the whole output
+	cfg := &config.BackendConfig{}
+	cfg.FunctionsConfig.ResponseRegex = []string{`(?P<name>\w+)\s*\((?P<arguments>.*)\)`}
+	assert.Empty(t, stream(newToolCallStreamer("answer", &schema.OpenAIRequest{}, cfg)))
+}
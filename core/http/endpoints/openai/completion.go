@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mudler/LocalAI/core/backend"
@@ -30,8 +32,27 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 	id := uuid.New().String()
 	created := int(time.Now().Unix())
 
-	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool) {
-		ComputeChoices(req, s, config, appConfig, loader, func(s string, c *[]schema.Choice) {}, func(s string, tokenUsage backend.TokenUsage) bool {
+	// process streams the choices, and validates their outputs at the end if validateOutput is set
+	process := func(s string, req *schema.OpenAIRequest, config *config.BackendConfig, loader *model.ModelLoader, responses chan schema.OpenAIResponse, extraUsage bool, validateOutput bool, outputSchema interface{}) error {
+		// the choices may be generated concurrently, each chunk carries the usage of all of them
+		var usageMu sync.Mutex
+		choicesUsage := make([]backend.TokenUsage, max(req.N, 1))
+		textOffsets := make([]int, max(req.N, 1))
+		outputs := make([]strings.Builder, max(req.N, 1))
+
+		_, _, err := ComputeChoices(req, s, config, appConfig, loader, func(s string, c *[]schema.Choice) {}, func(index int, s string, choiceUsage backend.TokenUsage, logprobs []schema.LogprobContent) bool {
+			usageMu.Lock()
+			choicesUsage[index] = choiceUsage
+			outputs[index].WriteString(s)
+			tokenUsage := backend.TokenUsage{}
+			for _, u := range choicesUsage {
+				tokenUsage.Prompt = max(tokenUsage.Prompt, u.Prompt)
+				tokenUsage.Completion += u.Completion
+				tokenUsage.TimingPromptProcessing += u.TimingPromptProcessing
+				tokenUsage.TimingTokenGeneration += u.TimingTokenGeneration
+			}
+			usageMu.Unlock()
+
 			usage := schema.OpenAIUsage{
 				PromptTokens:     tokenUsage.Prompt,
 				CompletionTokens: tokenUsage.Completion,
@@ -41,25 +62,38 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 				usage.TimingTokenGeneration = tokenUsage.TimingTokenGeneration
 				usage.TimingPromptProcessing = tokenUsage.TimingPromptProcessing
 			}
+			choice := schema.Choice{
+				Index: index,
+				Text:  s,
+			}
+			if config.Logprobs && len(logprobs) > 0 {
+				choice.Logprobs = completionLogprobs(logprobs, textOffsets[index])
+				for _, l := range logprobs {
+					textOffsets[index] += len(l.Token)
+				}
+			}
 			resp := schema.OpenAIResponse{
 				ID:      id,
 				Created: created,
 				Model:   req.Model, // we have to return what the user sent here, due to OpenAI spec.
-				Choices: []schema.Choice{
-					{
-						Index: 0,
-						Text:  s,
-					},
-				},
-				Object: "text_completion",
-				Usage:  usage,
+				Choices: []schema.Choice{choice},
+				Object:  "text_completion",
+				Usage:   usage,
 			}
 			log.Debug().Msgf("Sending goroutine: %s", s)
 
 			responses <- resp
 			return true
 		})
-		close(responses)
+		if err != nil || !validateOutput {
+			return err
+		}
+		for i := range outputs {
+			if err := validateStreamedOutput(outputs[i].String(), choicesUsage[i], outputSchema, config); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	return func(c *fiber.Ctx) error {
@@ -73,6 +107,8 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 		if err != nil {
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
+		// the usage requested with the stream options carries the timings too
+		extraUsage = extraUsage || includeUsage(input)
 
 		log.Debug().Msgf("`input`: %+v", input)
 
@@ -81,20 +117,45 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
 
+		// outputs of strict json_schema requests, or of models requiring it, are validated
+		validateOutput := false
+		var outputSchema interface{}
+
 		if config.ResponseFormatMap != nil {
 			d := schema.ChatCompletionResponseFormat{}
 			dat, _ := json.Marshal(config.ResponseFormatMap)
 			_ = json.Unmarshal(dat, &d)
 			if d.Type == "json_object" {
 				input.Grammar = functions.JSONBNF
+				validateOutput = config.StructuredOutput.Validate
+			} else if d.Type == "json_schema" {
+				d := schema.JsonSchemaRequest{}
+				_ = json.Unmarshal(dat, &d)
+				fs := &functions.JSONFunctionStructure{
+					AnyOf: []functions.Item{d.JsonSchema.Schema},
+				}
+				if g, err := fs.Grammar(config.FunctionsConfig.GrammarOptions()...); err == nil {
+					input.Grammar = g
+				}
+				validateOutput = d.JsonSchema.Strict || config.StructuredOutput.Validate
+				outputSchema = responseFormatSchema(config.ResponseFormatMap)
 			}
 		}
 
 		config.Grammar = input.Grammar
 
+		if input.Suffix != "" && config.TemplateConfig.FIM == "" {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("model %q has no fill-in-the-middle template, suffix is not supported", input.Model))
+		}
+
 		log.Debug().Msgf("Parameter Config: %+v", config)
 
 		if input.Stream {
+			// the status of the response can't change once the stream starts
+			if err := ml.Available(backend.ModelID(*config)); err != nil {
+				return err
+			}
+
 			log.Debug().Msgf("Stream request received")
 			c.Context().SetContentType("text/event-stream")
 			//c.Response().Header.SetContentType(fiber.MIMETextHTMLCharsetUTF8)
@@ -109,24 +170,23 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 				return errors.New("cannot handle more than 1 `PromptStrings` when Streaming")
 			}
 
-			predInput := config.PromptStrings[0]
-
-			templatedInput, err := evaluator.EvaluateTemplateForPrompt(templates.CompletionPromptTemplate, *config, templates.PromptTemplateData{
-				Input:        predInput,
-				SystemPrompt: config.SystemPrompt,
-			})
-			if err == nil {
-				predInput = templatedInput
-				log.Debug().Msgf("Template found, input modified to: %s", predInput)
+			predInput, err := templateCompletion(evaluator, config, config.PromptStrings[0], input.Suffix)
+			if err != nil {
+				return err
 			}
 
 			responses := make(chan schema.OpenAIResponse)
 
-			go process(predInput, input, config, ml, responses, extraUsage)
+			var streamErr error
+			go func() {
+				defer close(responses)
+				streamErr = process(predInput, input, config, ml, responses, extraUsage, validateOutput, outputSchema)
+			}()
 
 			c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
-
+				usage := schema.OpenAIUsage{}
 				for ev := range responses {
+					usage = ev.Usage
 					var buf bytes.Buffer
 					enc := json.NewEncoder(&buf)
 					enc.Encode(ev)
@@ -136,21 +196,33 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 					w.Flush()
 				}
 
+				if streamErr != nil {
+					sendStreamError(w, streamErr)
+					return
+				}
+
+				choices := []schema.Choice{}
+				for i := 0; i < max(input.N, 1); i++ {
+					choices = append(choices, schema.Choice{
+						Index:        i,
+						FinishReason: "stop",
+					})
+				}
+
 				resp := &schema.OpenAIResponse{
 					ID:      id,
 					Created: created,
 					Model:   input.Model, // we have to return what the user sent here, due to OpenAI spec.
-					Choices: []schema.Choice{
-						{
-							Index:        0,
-							FinishReason: "stop",
-						},
-					},
-					Object: "text_completion",
+					Choices: choices,
+					Object:  "text_completion",
+					Usage:   usage,
 				}
 				respData, _ := json.Marshal(resp)
 
 				w.WriteString(fmt.Sprintf("data: %s\n\n", respData))
+				if includeUsage(input) {
+					w.WriteString(fmt.Sprintf("data: %s\n\n", usageChunk(resp)))
+				}
 				w.WriteString("data: [DONE]\n\n")
 				w.Flush()
 			}))
@@ -161,24 +233,39 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 
 		totalTokenUsage := backend.TokenUsage{}
 
-		for k, i := range config.PromptStrings {
-			templatedInput, err := evaluator.EvaluateTemplateForPrompt(templates.CompletionPromptTemplate, *config, templates.PromptTemplateData{
-				SystemPrompt: config.SystemPrompt,
-				Input:        i,
-			})
-			if err == nil {
-				i = templatedInput
-				log.Debug().Msgf("Template found, input modified to: %s", i)
+		for _, i := range config.PromptStrings {
+			i, err := templateCompletion(evaluator, config, i, input.Suffix)
+			if err != nil {
+				return err
 			}
 
-			r, tokenUsage, err := ComputeChoices(
-				input, i, config, appConfig, ml, func(s string, c *[]schema.Choice) {
-					*c = append(*c, schema.Choice{Text: s, FinishReason: "stop", Index: k})
-				}, nil)
+			choices := func(s string, c *[]schema.Choice) {
+				*c = append(*c, schema.Choice{Text: s, FinishReason: "stop"})
+			}
+			r, tokenUsage, err := ComputeChoices(input, i, config, appConfig, ml, choices, nil)
 			if err != nil {
 				return err
 			}
+			if validateOutput {
+				r, tokenUsage, err = validateStructuredOutputs(input, i, outputSchema, r, tokenUsage, config, appConfig, ml, evaluator, choices)
+				if errors.Is(err, ErrInvalidStructuredOutput) {
+					return sendInvalidOutput(c, err)
+				}
+				if err != nil {
+					return err
+				}
+			}
 
+			// the choices of each prompt follow the ones of the previous prompts
+			for j := range r {
+				r[j].Index += len(result)
+				if r[j].Logprobs != nil {
+					r[j].Logprobs = completionLogprobs(r[j].Logprobs.Content, 0)
+				}
+			}
+
+			totalTokenUsage.Prompt += tokenUsage.Prompt
+			totalTokenUsage.Completion += tokenUsage.Completion
 			totalTokenUsage.TimingTokenGeneration += tokenUsage.TimingTokenGeneration
 			totalTokenUsage.TimingPromptProcessing += tokenUsage.TimingPromptProcessing
 
@@ -210,3 +297,78 @@ func CompletionEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, e
 		return c.JSON(resp)
 	}
 }
+
+// templateCompletion applies the completion template to the prompt. When a suffix is given, the
+// fill-in-the-middle template of the model builds the prompt from the prefix and the suffix instead
+func templateCompletion(evaluator *templates.Evaluator, config *config.BackendConfig, prompt, suffix string) (string, error) {
+	if suffix != "" {
+		templatedInput, err := evaluator.EvaluateTemplateForPrompt(templates.FIMPromptTemplate, *config, templates.PromptTemplateData{
+			SystemPrompt: config.SystemPrompt,
+			Input:        prompt,
+			Suffix:       suffix,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed evaluating the fill-in-the-middle template: %w", err)
+		}
+		log.Debug().Msgf("FIM template found, input modified to: %s", templatedInput)
+		return templatedInput, nil
+	}
+
+	templatedInput, err := evaluator.EvaluateTemplateForPrompt(templates.CompletionPromptTemplate, *config, templates.PromptTemplateData{
+		SystemPrompt: config.SystemPrompt,
+		Input:        prompt,
+	})
+	if err == nil {
+		prompt = templatedInput
+		log.Debug().Msgf("Template found, input modified to: %s", prompt)
+	}
+	return prompt, nil
+}
+
+// includeUsage returns true if the usage of the whole request is requested with stream_options.include_usage
+func includeUsage(input *schema.OpenAIRequest) bool {
+	return input.Stream && input.StreamOptions != nil && input.StreamOptions.IncludeUsage
+}
+
+// usageChunk returns the last chunk of the streams requesting the usage with stream_options.include_usage:
+// it carries the usage of the whole request and an empty list of choices
+func usageChunk(last *schema.OpenAIResponse) []byte {
+	data, _ := json.Marshal(struct {
+		schema.OpenAIResponse
+		Choices []schema.Choice `json:"choices"`
+	}{
+		OpenAIResponse: schema.OpenAIResponse{
+			ID:      last.ID,
+			Created: last.Created,
+			Model:   last.Model,
+			Object:  last.Object,
+			Usage:   last.Usage,
+		},
+		Choices: []schema.Choice{},
+	})
+	return data
+}
+
+// completionLogprobs converts the logprobs to the legacy format of the completion API.
+// offset is the position of the first token in the generated text
+func completionLogprobs(content []schema.LogprobContent, offset int) *schema.Logprobs {
+	logprobs := &schema.Logprobs{
+		Tokens:        []string{},
+		TokenLogprobs: []float64{},
+		TopLogprobs:   []map[string]float64{},
+		TextOffset:    []int{},
+	}
+	for _, c := range content {
+		top := map[string]float64{}
+		for _, t := range c.TopLogprobs {
+			top[t.Token] = t.Logprob
+		}
+
+		logprobs.Tokens = append(logprobs.Tokens, c.Token)
+		logprobs.TokenLogprobs = append(logprobs.TokenLogprobs, c.Logprob)
+		logprobs.TopLogprobs = append(logprobs.TopLogprobs, top)
+		logprobs.TextOffset = append(logprobs.TextOffset, offset)
+		offset += len(c.Token)
+	}
+	return logprobs
+}
@@ -0,0 +1,93 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogprobsRequest(t *testing.T) {
+	for _, tc := range []struct {
+		body        string
+		logprobs    bool
+		topLogprobs int
+	}{
+		{body: `{}`},
+		{body: `{"logprobs": false, "top_logprobs": 2}`, topLogprobs: 2},
+		{body: `{"logprobs": true, "top_logprobs": 3}`, logprobs: true, topLogprobs: 3},
+		{body: `{"logprobs": 5}`, logprobs: true, topLogprobs: 5},
+		{body: `{"logprobs": 0}`, logprobs: true},
+	} {
+		input := &schema.OpenAIRequest{}
+		assert.NoError(t, json.Unmarshal([]byte(tc.body), input))
+
+		cfg := &config.BackendConfig{}
+		updateRequestConfig(cfg, input)
+		assert.Equal(t, tc.logprobs, cfg.Logprobs, tc.body)
+		assert.Equal(t, tc.topLogprobs, cfg.TopLogprobs, tc.body)
+	}
+}
+
+func TestCompletionLogprobs(t *testing.T) {
+	logprobs := completionLogprobs([]schema.LogprobContent{
+		{Token: "Hello", Logprob: -0.1, TopLogprobs: []schema.TopLogprob{{Token: "Hello", Logprob: -0.1}, {Token: "Hi", Logprob: -2.5}}},
+		{Token: " world", Logprob: -0.5, TopLogprobs: []schema.TopLogprob{{Token: " world", Logprob: -0.5}}},
+	}, 3)
+
+	assert.Equal(t, []string{"Hello", " world"}, logprobs.Tokens)
+	assert.Equal(t, []float64{-0.1, -0.5}, logprobs.TokenLogprobs)
+	assert.Equal(t, []int{3, 8}, logprobs.TextOffset)
+	assert.Equal(t, map[string]float64{"Hello": -0.1, "Hi": -2.5}, logprobs.TopLogprobs[0])
+	assert.Empty(t, logprobs.Content)
+}
+
+func TestTemplateCompletionSuffix(t *testing.T) {
+	evaluator := templates.NewEvaluator(t.TempDir())
+	cfg := &config.BackendConfig{}
+	cfg.TemplateConfig.Completion = "Complete: {{.Input}}"
+	cfg.TemplateConfig.FIM = "<PRE> {{.Input}} <SUF>{{.Suffix}} <MID>"
+
+	prompt, err := templateCompletion(evaluator, cfg, "def add(a, b):", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Complete: def add(a, b):", prompt)
+
+	prompt, err = templateCompletion(evaluator, cfg, "def add(a, b):", "    return c")
+	assert.NoError(t, err)
+	assert.Equal(t, "<PRE> def add(a, b): <SUF>    return c <MID>", prompt)
+}
+
+func TestUsageChunk(t *testing.T) {
+	chunk := usageChunk(&schema.OpenAIResponse{
+		ID:      "id",
+		Created: 1,
+		Model:   "model",
+		Object:  "chat.completion.chunk",
+		Choices: []schema.Choice{{Index: 0, FinishReason: "stop"}},
+		Usage:   schema.OpenAIUsage{PromptTokens: 3, CompletionTokens: 5, TotalTokens: 8, TimingTokenGeneration: 1.5},
+	})
+
+	assert.JSONEq(t, `{
+		"id": "id",
+		"created": 1,
+		"model": "model",
+		"object": "chat.completion.chunk",
+		"choices": [],
+		"usage": {"prompt_tokens": 3, "completion_tokens": 5, "total_tokens": 8, "timing_token_generation": 1.5}
+	}`, string(chunk))
+}
+
+func TestStreamOptionsRequest(t *testing.T) {
+	input := &schema.OpenAIRequest{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"stream": true, "stream_options": {"include_usage": true}}`), input))
+	assert.True(t, input.StreamOptions.IncludeUsage)
+}
+
+func TestIncludeUsage(t *testing.T) {
+	assert.False(t, includeUsage(&schema.OpenAIRequest{Stream: true}))
+	assert.False(t, includeUsage(&schema.OpenAIRequest{StreamOptions: &schema.StreamOptions{IncludeUsage: true}}))
+	assert.True(t, includeUsage(&schema.OpenAIRequest{Stream: true, StreamOptions: &schema.StreamOptions{IncludeUsage: true}}))
+}
@@ -1,8 +1,11 @@
 package openai
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/mudler/LocalAI/core/backend"
@@ -33,6 +36,15 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			return fmt.Errorf("failed reading parameters from request:%w", err)
 		}
 
+		switch config.EncodingFormat {
+		case "", "float", "base64":
+		default:
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("unsupported encoding_format %q", config.EncodingFormat))
+		}
+		if config.Dimensions < 0 {
+			return c.Status(fiber.StatusBadRequest).SendString("dimensions must be a positive integer")
+		}
+
 		log.Debug().Msgf("Parameter Config: %+v", config)
 		items := []schema.Item{}
 
@@ -47,7 +59,11 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			if err != nil {
 				return err
 			}
-			items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
+			embedding, err := encodeEmbedding(embeddings, config.Dimensions, config.EncodingFormat)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			items = append(items, schema.Item{Embedding: embedding, Index: i, Object: "embedding"})
 		}
 
 		for i, s := range config.InputStrings {
@@ -61,7 +77,11 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 			if err != nil {
 				return err
 			}
-			items = append(items, schema.Item{Embedding: embeddings, Index: i, Object: "embedding"})
+			embedding, err := encodeEmbedding(embeddings, config.Dimensions, config.EncodingFormat)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+			}
+			items = append(items, schema.Item{Embedding: embedding, Index: i, Object: "embedding"})
 		}
 
 		id := uuid.New().String()
@@ -81,3 +101,43 @@ func EmbeddingsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, a
 		return c.JSON(resp)
 	}
 }
+
+// encodeEmbedding truncates the embedding to the requested dimensions, re-normalizing it,
+// and encodes it as requested: base64 is the packed little-endian float32 representation expected by the OpenAI clients
+func encodeEmbedding(embedding []float32, dimensions int, encodingFormat string) (interface{}, error) {
+	if dimensions > 0 && dimensions != len(embedding) {
+		if dimensions > len(embedding) {
+			return nil, fmt.Errorf("dimensions (%d) exceeds the model embedding size (%d)", dimensions, len(embedding))
+		}
+		embedding = normalizeEmbedding(embedding[:dimensions])
+	}
+
+	if encodingFormat != "base64" {
+		return embedding, nil
+	}
+
+	buf := make([]byte, 4*len(embedding))
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// normalizeEmbedding returns a copy of the embedding scaled to unit length
+func normalizeEmbedding(embedding []float32) []float32 {
+	var norm float64
+	for _, v := range embedding {
+		norm += float64(v) * float64(v)
+	}
+	norm = math.Sqrt(norm)
+
+	result := make([]float32, len(embedding))
+	for i, v := range embedding {
+		if norm == 0 {
+			result[i] = v
+			continue
+		}
+		result[i] = float32(float64(v) / norm)
+	}
+	return result
+}
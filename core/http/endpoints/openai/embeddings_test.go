@@ -0,0 +1,34 @@
+package openai
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeEmbedding(t *testing.T) {
+	embedding := []float32{3, 4, 12}
+
+	result, err := encodeEmbedding(embedding, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, embedding, result)
+
+	result, err = encodeEmbedding(embedding, 2, "float")
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float32{0.6, 0.8}, result, 1e-6)
+
+	_, err = encodeEmbedding(embedding, 4, "")
+	assert.Error(t, err)
+
+	result, err = encodeEmbedding(embedding, 0, "base64")
+	assert.NoError(t, err)
+	data, err := base64.StdEncoding.DecodeString(result.(string))
+	assert.NoError(t, err)
+	assert.Len(t, data, 12)
+	for i, v := range embedding {
+		assert.Equal(t, v, math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,6 +20,9 @@ import (
 
 var UploadedFiles []schema.File
 
+// uploadedFilesMu guards UploadedFiles, which are also updated by background batches
+var uploadedFilesMu sync.RWMutex
+
 const UploadedFilesFile = "uploadedFiles.json"
 
 // UploadFilesEndpoint https://platform.openai.com/docs/api-reference/files/create
@@ -62,8 +67,10 @@ func UploadFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Appli
 			Purpose:   purpose,
 		}
 
+		uploadedFilesMu.Lock()
 		UploadedFiles = append(UploadedFiles, f)
 		utils.SaveConfig(appConfig.UploadDir, UploadedFilesFile, UploadedFiles)
+		uploadedFilesMu.Unlock()
 		return c.Status(fiber.StatusOK).JSON(f)
 	}
 }
@@ -85,8 +92,9 @@ func ListFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Applica
 		var listFiles schema.ListFiles
 
 		purpose := c.Query("purpose")
+		uploadedFilesMu.RLock()
 		if purpose == "" {
-			listFiles.Data = UploadedFiles
+			listFiles.Data = slices.Clone(UploadedFiles)
 		} else {
 			for _, f := range UploadedFiles {
 				if purpose == f.Purpose {
@@ -94,6 +102,7 @@ func ListFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Applica
 				}
 			}
 		}
+		uploadedFilesMu.RUnlock()
 		listFiles.Object = "list"
 		return c.Status(fiber.StatusOK).JSON(listFiles)
 	}
@@ -105,13 +114,24 @@ func getFileFromRequest(c *fiber.Ctx) (*schema.File, error) {
 		return nil, fmt.Errorf("file_id parameter is required")
 	}
 
+	if f, exists := findUploadedFile(id); exists {
+		return &f, nil
+	}
+
+	return nil, fmt.Errorf("unable to find file id %s", id)
+}
+
+// findUploadedFile returns the uploaded file with the given ID
+func findUploadedFile(id string) (schema.File, bool) {
+	uploadedFilesMu.RLock()
+	defer uploadedFilesMu.RUnlock()
+
 	for _, f := range UploadedFiles {
 		if id == f.ID {
-			return &f, nil
+			return f, true
 		}
 	}
-
-	return nil, fmt.Errorf("unable to find file id %s", id)
+	return schema.File{}, false
 }
 
 // GetFilesEndpoint is the OpenAI API endpoint to get files https://platform.openai.com/docs/api-reference/files/retrieve
@@ -156,6 +176,7 @@ func DeleteFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Appli
 		}
 
 		// Remove upload from list
+		uploadedFilesMu.Lock()
 		for i, f := range UploadedFiles {
 			if f.ID == file.ID {
 				UploadedFiles = append(UploadedFiles[:i], UploadedFiles[i+1:]...)
@@ -164,6 +185,7 @@ func DeleteFilesEndpoint(cm *config.BackendConfigLoader, appConfig *config.Appli
 		}
 
 		utils.SaveConfig(appConfig.UploadDir, UploadedFilesFile, UploadedFiles)
+		uploadedFilesMu.Unlock()
 		return c.JSON(DeleteStatus{
 			Id:      file.ID,
 			Object:  "file",
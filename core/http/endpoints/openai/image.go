@@ -127,119 +127,131 @@ func ImageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appCon
 
 		log.Debug().Msgf("Parameter Config: %+v", config)
 
-		switch config.Backend {
-		case "stablediffusion":
-			config.Backend = model.StableDiffusionBackend
-		case "":
-			config.Backend = model.StableDiffusionBackend
-		}
+		return generateImages(c, config.PromptStrings, src, "", input, config, ml, appConfig)
+	}
+}
 
-		if !strings.Contains(input.Size, "x") {
-			input.Size = "512x512"
-			log.Warn().Msgf("Invalid size, using default 512x512")
-		}
+// generateImages generates n images for each prompt and returns them in the OpenAI response format.
+// src is the image to start from (img2img), if a mask is given only its transparent areas are modified.
+func generateImages(c *fiber.Ctx, promptStrings []string, src, mask string, input *schema.OpenAIRequest, config *config.BackendConfig, ml *model.ModelLoader, appConfig *config.ApplicationConfig) error {
+	switch config.Backend {
+	case "stablediffusion":
+		config.Backend = model.StableDiffusionBackend
+	case "":
+		config.Backend = model.StableDiffusionBackend
+	}
 
-		sizeParts := strings.Split(input.Size, "x")
-		if len(sizeParts) != 2 {
-			return fmt.Errorf("invalid value for 'size'")
-		}
-		width, err := strconv.Atoi(sizeParts[0])
-		if err != nil {
-			return fmt.Errorf("invalid value for 'size'")
-		}
-		height, err := strconv.Atoi(sizeParts[1])
-		if err != nil {
-			return fmt.Errorf("invalid value for 'size'")
+	if !strings.Contains(input.Size, "x") {
+		input.Size = "512x512"
+		log.Warn().Msgf("Invalid size, using default 512x512")
+	}
+
+	sizeParts := strings.Split(input.Size, "x")
+	if len(sizeParts) != 2 {
+		return fmt.Errorf("invalid value for 'size'")
+	}
+	width, err := strconv.Atoi(sizeParts[0])
+	if err != nil {
+		return fmt.Errorf("invalid value for 'size'")
+	}
+	height, err := strconv.Atoi(sizeParts[1])
+	if err != nil {
+		return fmt.Errorf("invalid value for 'size'")
+	}
+
+	b64JSON := config.ResponseFormat == "b64_json"
+
+	// src and clip_skip
+	var result []schema.Item
+	for _, i := range promptStrings {
+		n := input.N
+		if input.N == 0 {
+			n = 1
 		}
+		for j := 0; j < n; j++ {
+			prompts := strings.Split(i, "|")
+			positive_prompt := prompts[0]
+			negative_prompt := ""
+			if len(prompts) > 1 {
+				negative_prompt = prompts[1]
+			}
 
-		b64JSON := config.ResponseFormat == "b64_json"
+			mode := 0
+			step := config.Step
+			if step == 0 {
+				step = 15
+			}
 
-		// src and clip_skip
-		var result []schema.Item
-		for _, i := range config.PromptStrings {
-			n := input.N
-			if input.N == 0 {
-				n = 1
+			if input.Mode != 0 {
+				mode = input.Mode
 			}
-			for j := 0; j < n; j++ {
-				prompts := strings.Split(i, "|")
-				positive_prompt := prompts[0]
-				negative_prompt := ""
-				if len(prompts) > 1 {
-					negative_prompt = prompts[1]
-				}
 
-				mode := 0
-				step := config.Step
-				if step == 0 {
-					step = 15
-				}
+			if input.Step != 0 {
+				step = input.Step
+			}
 
-				if input.Mode != 0 {
-					mode = input.Mode
-				}
+			tempDir := ""
+			if !b64JSON {
+				tempDir = appConfig.ImageDir
+			}
+			// Create a temporary file
+			outputFile, err := os.CreateTemp(tempDir, "b64")
+			if err != nil {
+				return err
+			}
+			outputFile.Close()
+			output := outputFile.Name() + ".png"
+			// Rename the temporary file
+			err = os.Rename(outputFile.Name(), output)
+			if err != nil {
+				return err
+			}
 
-				if input.Step != 0 {
-					step = input.Step
-				}
+			baseURL := c.BaseURL()
 
-				tempDir := ""
-				if !b64JSON {
-					tempDir = appConfig.ImageDir
-				}
-				// Create a temporary file
-				outputFile, err := os.CreateTemp(tempDir, "b64")
-				if err != nil {
-					return err
-				}
-				outputFile.Close()
-				output := outputFile.Name() + ".png"
-				// Rename the temporary file
-				err = os.Rename(outputFile.Name(), output)
-				if err != nil {
-					return err
+			fn, err := backend.ImageGeneration(height, width, mode, step, *config.Seed, positive_prompt, negative_prompt, src, output, ml, *config, appConfig)
+			if err != nil {
+				return err
+			}
+			if err := fn(); err != nil {
+				return err
+			}
+
+			if mask != "" {
+				if err := applyImageMask(src, mask, output); err != nil {
+					return fmt.Errorf("failed applying mask: %w", err)
 				}
+			}
 
-				baseURL := c.BaseURL()
+			item := &schema.Item{}
 
-				fn, err := backend.ImageGeneration(height, width, mode, step, *config.Seed, positive_prompt, negative_prompt, src, output, ml, *config, appConfig)
+			if b64JSON {
+				defer os.RemoveAll(output)
+				data, err := os.ReadFile(output)
 				if err != nil {
 					return err
 				}
-				if err := fn(); err != nil {
-					return err
-				}
-
-				item := &schema.Item{}
-
-				if b64JSON {
-					defer os.RemoveAll(output)
-					data, err := os.ReadFile(output)
-					if err != nil {
-						return err
-					}
-					item.B64JSON = base64.StdEncoding.EncodeToString(data)
-				} else {
-					base := filepath.Base(output)
-					item.URL = baseURL + "/generated-images/" + base
-				}
-
-				result = append(result, *item)
+				item.B64JSON = base64.StdEncoding.EncodeToString(data)
+			} else {
+				base := filepath.Base(output)
+				item.URL = baseURL + "/generated-images/" + base
 			}
-		}
 
-		id := uuid.New().String()
-		created := int(time.Now().Unix())
-		resp := &schema.OpenAIResponse{
-			ID:      id,
-			Created: created,
-			Data:    result,
+			result = append(result, *item)
 		}
+	}
 
-		jsonResult, _ := json.Marshal(resp)
-		log.Debug().Msgf("Response: %s", jsonResult)
-
-		// Return the prediction in the response body
-		return c.JSON(resp)
+	id := uuid.New().String()
+	created := int(time.Now().Unix())
+	resp := &schema.OpenAIResponse{
+		ID:      id,
+		Created: created,
+		Data:    result,
 	}
+
+	jsonResult, _ := json.Marshal(resp)
+	log.Debug().Msgf("Response: %s", jsonResult)
+
+	// Return the prediction in the response body
+	return c.JSON(resp)
 }
@@ -0,0 +1,183 @@
+package openai
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// ImageEditEndpoint is the OpenAI Image edit API endpoint https://platform.openai.com/docs/api-reference/images/createEdit
+// @Summary Creates an edited or extended image given an original image and a prompt.
+// @accept multipart/form-data
+// @Param image formData file true "the image to edit"
+// @Param mask formData file false "an image whose fully transparent areas indicate where the image should be edited"
+// @Param prompt formData string true "a description of the desired image"
+// @Param model formData string false "model"
+// @Param n formData int false "number of images to generate"
+// @Param size formData string false "size of the generated images"
+// @Param response_format formData string false "url or b64_json"
+// @Success 200 {object} schema.OpenAIResponse "Response"
+// @Router /v1/images/edits [post]
+func ImageEditEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		prompt := c.FormValue("prompt")
+		if prompt == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("prompt is required")
+		}
+		return imageToImage(c, cl, ml, appConfig, prompt, true)
+	}
+}
+
+// ImageVariationEndpoint is the OpenAI Image variation API endpoint https://platform.openai.com/docs/api-reference/images/createVariation
+// @Summary Creates a variation of a given image.
+// @accept multipart/form-data
+// @Param image formData file true "the image to use as the basis for the variations"
+// @Param model formData string false "model"
+// @Param n formData int false "number of images to generate"
+// @Param size formData string false "size of the generated images"
+// @Param response_format formData string false "url or b64_json"
+// @Success 200 {object} schema.OpenAIResponse "Response"
+// @Router /v1/images/variations [post]
+func ImageVariationEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return imageToImage(c, cl, ml, appConfig, "", false)
+	}
+}
+
+// imageToImage generates images starting from the uploaded image (img2img)
+func imageToImage(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, prompt string, withMask bool) error {
+	m, input, err := readRequest(c, cl, ml, appConfig, false)
+	if err != nil {
+		return fmt.Errorf("failed reading parameters from request:%w", err)
+	}
+
+	if m == "" {
+		m = model.StableDiffusionBackend
+	}
+	log.Debug().Msgf("Loading model: %+v", m)
+
+	config, input, err := mergeRequestWithConfig(m, input, cl, ml, appConfig.Debug, 0, 0, false)
+	if err != nil {
+		return fmt.Errorf("failed reading parameters from request:%w", err)
+	}
+
+	// multipart forms are not decoded into the interface fields of the request
+	if responseFormat := c.FormValue("response_format"); responseFormat != "" {
+		config.ResponseFormat = responseFormat
+	}
+
+	src, err := saveFormImage(c, "image", appConfig.ImageDir)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("invalid image: %s", err.Error()))
+	}
+	defer os.RemoveAll(src)
+
+	mask := ""
+	if _, err := c.FormFile("mask"); withMask && err == nil {
+		mask, err = saveFormImage(c, "mask", appConfig.ImageDir)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(fmt.Sprintf("invalid mask: %s", err.Error()))
+		}
+		defer os.RemoveAll(mask)
+	}
+
+	log.Debug().Msgf("Parameter Config: %+v", config)
+
+	return generateImages(c, []string{prompt}, src, mask, input, config, ml, appConfig)
+}
+
+// saveFormImage stores the image uploaded in the given form field to a temporary file
+func saveFormImage(c *fiber.Ctx, field, dir string) (string, error) {
+	file, err := c.FormFile(field)
+	if err != nil {
+		return "", err
+	}
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	out, err := os.CreateTemp(dir, "b64*"+filepath.Ext(filepath.Base(file.Filename)))
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, f); err != nil {
+		os.RemoveAll(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// applyImageMask restores the original image in the generated one where the mask is not transparent,
+// so that only the transparent areas of the mask are edited. Original and mask are scaled to the generated image.
+func applyImageMask(original, mask, generated string) error {
+	originalImg, err := decodeImage(original)
+	if err != nil {
+		return err
+	}
+	maskImg, err := decodeImage(mask)
+	if err != nil {
+		return err
+	}
+	generatedImg, err := decodeImage(generated)
+	if err != nil {
+		return err
+	}
+
+	bounds := generatedImg.Bounds()
+	result := image.NewRGBA(bounds)
+	draw.Draw(result, bounds, generatedImg, bounds.Min, draw.Src)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			_, _, _, alpha := scaledAt(maskImg, x-bounds.Min.X, y-bounds.Min.Y, bounds).RGBA()
+			if alpha == 0 {
+				continue
+			}
+			or, og, ob, _ := scaledAt(originalImg, x-bounds.Min.X, y-bounds.Min.Y, bounds).RGBA()
+			gr, gg, gb, ga := generatedImg.At(x, y).RGBA()
+			blend := func(o, g uint32) uint8 {
+				return uint8((o*alpha + g*(0xffff-alpha)) / 0xffff >> 8)
+			}
+			result.Set(x, y, color.RGBA{R: blend(or, gr), G: blend(og, gg), B: blend(ob, gb), A: uint8(ga >> 8)})
+		}
+	}
+
+	out, err := os.Create(generated)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return png.Encode(out, result)
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// scaledAt returns the color of img at the position (x, y) of an image with the given bounds (nearest neighbor)
+func scaledAt(img image.Image, x, y int, bounds image.Rectangle) color.Color {
+	b := img.Bounds()
+	return img.At(b.Min.X+x*b.Dx()/bounds.Dx(), b.Min.Y+y*b.Dy()/bounds.Dy())
+}
@@ -0,0 +1,80 @@
+package openai
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestImage(t *testing.T, path string, size int, c color.Color) {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	f, err := os.Create(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, png.Encode(f, img))
+}
+
+func TestApplyImageMask(t *testing.T) {
+	dir := t.TempDir()
+	original := filepath.Join(dir, "original.png")
+	mask := filepath.Join(dir, "mask.png")
+	generated := filepath.Join(dir, "generated.png")
+
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	writeTestImage(t, original, 2, red)
+	writeTestImage(t, generated, 4, blue)
+
+	// The left half of the mask is transparent, so only that part of the image is edited
+	maskImg := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	maskImg.Set(1, 0, color.RGBA{A: 255})
+	maskImg.Set(1, 1, color.RGBA{A: 255})
+	f, err := os.Create(mask)
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(f, maskImg))
+	f.Close()
+
+	assert.NoError(t, applyImageMask(original, mask, generated))
+
+	result, err := decodeImage(generated)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Bounds().Dx())
+	assert.Equal(t, color.RGBAModel.Convert(blue), color.RGBAModel.Convert(result.At(0, 0)))
+	assert.Equal(t, color.RGBAModel.Convert(blue), color.RGBAModel.Convert(result.At(1, 3)))
+	assert.Equal(t, color.RGBAModel.Convert(red), color.RGBAModel.Convert(result.At(2, 0)))
+	assert.Equal(t, color.RGBAModel.Convert(red), color.RGBAModel.Convert(result.At(3, 3)))
+}
+
+func TestImageEditRequiresPrompt(t *testing.T) {
+	app := fiber.New()
+	app.Post("/images/edits", ImageEditEndpoint(&config.BackendConfigLoader{}, model.NewModelLoader("/tmp/localai/model"), &config.ApplicationConfig{}))
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("image", "image.png")
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(part, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/images/edits", &body)
+	req.Header.Set(fiber.HeaderContentType, w.FormDataContentType())
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
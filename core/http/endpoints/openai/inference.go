@@ -1,6 +1,9 @@
 package openai
 
 import (
+	"context"
+	"sync"
+
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
 
@@ -8,6 +11,9 @@ import (
 	model "github.com/mudler/LocalAI/pkg/model"
 )
 
+// ComputeChoices runs the n predictions requested and collects the resulting choices.
+// The predictions run concurrently when parallel backend requests are enabled, the
+// tokenCallback receives the index of the choice the token belongs to.
 func ComputeChoices(
 	req *schema.OpenAIRequest,
 	predInput string,
@@ -15,7 +21,7 @@ func ComputeChoices(
 	o *config.ApplicationConfig,
 	loader *model.ModelLoader,
 	cb func(string, *[]schema.Choice),
-	tokenCallback func(string, backend.TokenUsage) bool) ([]schema.Choice, backend.TokenUsage, error) {
+	tokenCallback func(int, string, backend.TokenUsage, []schema.LogprobContent) bool) ([]schema.Choice, backend.TokenUsage, error) {
 	n := req.N // number of completions to return
 	result := []schema.Choice{}
 
@@ -36,30 +42,89 @@ func ComputeChoices(
 		audios = append(audios, m.StringAudios...)
 	}
 
-	// get the model function to call for the result
-	predFunc, err := backend.ModelInference(req.Context, predInput, req.Messages, images, videos, audios, loader, *config, o, tokenCallback)
-	if err != nil {
-		return result, backend.TokenUsage{}, err
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	// a failing choice stops the others
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	tokenUsage := backend.TokenUsage{}
-
+	// get the model function to call for each choice
+	predFuncs := make([]func() (backend.LLMResponse, error), n)
 	for i := 0; i < n; i++ {
-		prediction, err := predFunc()
+		var choiceCallback func(string, backend.TokenUsage, []schema.LogprobContent) bool
+		if tokenCallback != nil {
+			index := i
+			choiceCallback = func(s string, usage backend.TokenUsage, logprobs []schema.LogprobContent) bool {
+				return tokenCallback(index, s, usage, logprobs)
+			}
+		}
+
+		predFunc, err := backend.ModelInference(ctx, predInput, req.Messages, images, videos, audios, loader, *config, o, choiceCallback)
 		if err != nil {
 			return result, backend.TokenUsage{}, err
 		}
+		predFuncs[i] = predFunc
+	}
 
-		tokenUsage.Prompt += prediction.Usage.Prompt
+	predictions := make([]backend.LLMResponse, n)
+	var err error
+	if o.ParallelBackendRequests && n > 1 {
+		var wg sync.WaitGroup
+		var once sync.Once
+		for i := range predFuncs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				prediction, pErr := predFuncs[i]()
+				if pErr != nil {
+					// keep the error which caused the cancellation of the other choices
+					once.Do(func() {
+						err = pErr
+						cancel()
+					})
+					return
+				}
+				predictions[i] = prediction
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range predFuncs {
+			predictions[i], err = predFuncs[i]()
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return result, backend.TokenUsage{}, err
+	}
+
+	tokenUsage := backend.TokenUsage{}
+
+	for i, prediction := range predictions {
+		// all the choices share the same prompt
+		tokenUsage.Prompt = max(tokenUsage.Prompt, prediction.Usage.Prompt)
 		tokenUsage.Completion += prediction.Usage.Completion
 		tokenUsage.TimingPromptProcessing += prediction.Usage.TimingPromptProcessing
 		tokenUsage.TimingTokenGeneration += prediction.Usage.TimingTokenGeneration
 
 		finetunedResponse := backend.Finetune(*config, predInput, prediction.Response)
+		choices := len(result)
 		cb(finetunedResponse, &result)
 
-		//result = append(result, Choice{Text: prediction})
-
+		for j := choices; j < len(result); j++ {
+			result[j].Index = i
+			// the prediction was truncated by the token limit
+			if result[j].FinishReason == "stop" && config.Maxtokens != nil && *config.Maxtokens > 0 && prediction.Usage.Completion >= *config.Maxtokens {
+				result[j].FinishReason = "length"
+			}
+			if config.Logprobs {
+				result[j].Logprobs = &schema.Logprobs{Content: prediction.Logprobs}
+			}
+		}
 	}
-	return result, tokenUsage, err
+	return result, tokenUsage, nil
 }
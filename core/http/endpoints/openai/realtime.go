@@ -0,0 +1,679 @@
+package openai
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/sound"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// realtimeSampleRate is the sample rate of the pcm16 audio exchanged with the clients
+	realtimeSampleRate = 24000
+	// vadSampleRate is the sample rate expected by the VAD and transcription models
+	vadSampleRate = 16000
+	// realtimeAudioChunk is the number of samples sent in each audio delta (200ms)
+	realtimeAudioChunk = realtimeSampleRate / 5
+
+	vadInterval              = 300 * time.Millisecond
+	defaultPrefixPaddingMs   = 300
+	defaultSilenceDurationMs = 500
+)
+
+// RealtimeEndpoint is the OpenAI Realtime API endpoint https://platform.openai.com/docs/api-reference/realtime
+// The model must define a pipeline: the input audio is segmented in turns with the VAD model, transcribed,
+// answered by the LLM and the reply is synthesized with the TTS model.
+// @Summary Realtime voice conversation over a WebSocket.
+// @Param model query string true "model defining the realtime pipeline"
+// @Router /v1/realtime [get]
+func RealtimeEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	modelRouter := middleware.NewModelRouter(cl, ml, appConfig)
+
+	return websocket.New(func(c *websocket.Conn) {
+		s := &realtimeSession{
+			conn:      c,
+			ml:        ml,
+			evaluator: evaluator,
+			appConfig: appConfig,
+		}
+
+		if err := s.loadPipeline(c.Query("model"), cl, modelRouter); err != nil {
+			log.Error().Err(err).Msg("realtime: failed loading the pipeline")
+			s.sendError("invalid_request_error", err.Error(), "")
+			return
+		}
+
+		s.run()
+	})
+}
+
+// realtimeSession is the state of a realtime WebSocket connection
+type realtimeSession struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	ml        *model.ModelLoader
+	evaluator *templates.Evaluator
+	appConfig *config.ApplicationConfig
+
+	vadConfig, transcriptionConfig, llmConfig, ttsConfig *config.BackendConfig
+
+	ctx context.Context
+	// wg tracks the goroutines writing to the connection, which must end before the handler returns
+	wg sync.WaitGroup
+
+	mu           sync.Mutex
+	session      schema.RealtimeSession
+	conversation []schema.RealtimeItem
+	// audio is the input audio buffer (pcm16, 24kHz, mono)
+	audio []byte
+	// inputItemID is the id of the item of the user speech detected in the audio buffer, if any
+	inputItemID    string
+	cancelResponse context.CancelFunc
+	responseDone   chan struct{}
+}
+
+// loadPipeline loads the configurations of the models defined in the pipeline of the model.
+// The virtual models of the pipeline are resolved to the models serving them for the whole session.
+func (s *realtimeSession) loadPipeline(modelName string, cl *config.BackendConfigLoader, modelRouter *middleware.ModelRouter) error {
+	if modelName == "" {
+		return fmt.Errorf("the model query parameter is required")
+	}
+
+	load := func(name string) (*config.BackendConfig, error) {
+		if name == "" {
+			return nil, nil
+		}
+		return cl.LoadBackendConfigFileByName(name, s.ml.ModelPath,
+			config.LoadOptionDebug(s.appConfig.Debug),
+			config.LoadOptionThreads(s.appConfig.Threads),
+			config.LoadOptionContextSize(s.appConfig.ContextSize),
+			config.LoadOptionF16(s.appConfig.F16),
+		)
+	}
+
+	cfg, err := load(modelName)
+	if err != nil {
+		return err
+	}
+	if cfg.Pipeline.IsEmpty() {
+		return fmt.Errorf("model %s does not define a pipeline", modelName)
+	}
+	if cfg.Pipeline.LLM == "" {
+		return fmt.Errorf("the pipeline of model %s does not define an LLM", modelName)
+	}
+
+	for _, m := range []struct {
+		name   string
+		target **config.BackendConfig
+	}{
+		{cfg.Pipeline.VAD, &s.vadConfig},
+		{cfg.Pipeline.Transcription, &s.transcriptionConfig},
+		{cfg.Pipeline.LLM, &s.llmConfig},
+		{cfg.Pipeline.TTS, &s.ttsConfig},
+	} {
+		if m.name == "" {
+			continue
+		}
+		name, err := modelRouter.Resolve(m.name)
+		if err != nil {
+			return fmt.Errorf("failed loading %s: %w", m.name, err)
+		}
+		if *m.target, err = load(name); err != nil {
+			return fmt.Errorf("failed loading %s: %w", m.name, err)
+		}
+	}
+
+	s.session = schema.RealtimeSession{
+		ID:                "sess_" + uuid.New().String(),
+		Object:            "realtime.session",
+		Model:             modelName,
+		Modalities:        []string{"text"},
+		Instructions:      s.llmConfig.SystemPrompt,
+		InputAudioFormat:  "pcm16",
+		OutputAudioFormat: "pcm16",
+	}
+	if s.ttsConfig != nil {
+		s.session.Modalities = append(s.session.Modalities, "audio")
+		s.session.Voice = s.ttsConfig.Voice
+	}
+	if s.transcriptionConfig != nil {
+		s.session.InputAudioTranscription = &schema.RealtimeInputAudioTranscription{Model: cfg.Pipeline.Transcription}
+	}
+	if s.vadConfig != nil {
+		s.session.TurnDetection = &schema.RealtimeTurnDetection{
+			Type:              "server_vad",
+			PrefixPaddingMs:   defaultPrefixPaddingMs,
+			SilenceDurationMs: defaultSilenceDurationMs,
+		}
+	}
+	return nil
+}
+
+// run reads the client events until the connection is closed
+func (s *realtimeSession) run() {
+	ctx := s.appConfig.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	s.ctx = ctx
+	defer func() {
+		cancel()
+		s.wg.Wait()
+	}()
+
+	session := s.currentSession()
+	s.send(schema.RealtimeEvent{Type: "session.created", Session: &session})
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.detectTurns(ctx)
+	}()
+
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Error().Err(err).Msg("realtime: failed reading from the connection")
+			}
+			return
+		}
+		s.handleEvent(msg)
+	}
+}
+
+func (s *realtimeSession) handleEvent(msg []byte) {
+	event := schema.RealtimeEvent{}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		s.sendError("invalid_request_error", fmt.Sprintf("invalid event: %s", err.Error()), "")
+		return
+	}
+
+	switch event.Type {
+	case "session.update":
+		raw := struct {
+			Session json.RawMessage `json:"session"`
+		}{}
+		if err := json.Unmarshal(msg, &raw); err != nil || raw.Session == nil {
+			s.sendError("invalid_request_error", "session is required", event.EventID)
+			return
+		}
+		s.mu.Lock()
+		session, err := updateRealtimeSession(s.session, raw.Session)
+		if err == nil && session.TurnDetection != nil && s.vadConfig == nil {
+			err = fmt.Errorf("server_vad requires a VAD model in the pipeline")
+		}
+		if err == nil {
+			s.session = session
+		}
+		s.mu.Unlock()
+		if err != nil {
+			s.sendError("invalid_request_error", err.Error(), event.EventID)
+			return
+		}
+		s.send(schema.RealtimeEvent{Type: "session.updated", Session: &session})
+	case "input_audio_buffer.append":
+		audio, err := base64.StdEncoding.DecodeString(event.Audio)
+		if err != nil {
+			s.sendError("invalid_request_error", fmt.Sprintf("invalid audio: %s", err.Error()), event.EventID)
+			return
+		}
+		s.mu.Lock()
+		s.audio = append(s.audio, audio...)
+		s.mu.Unlock()
+	case "input_audio_buffer.commit":
+		audio, itemID := s.takeAudio()
+		if len(audio) == 0 {
+			s.sendError("invalid_request_error", "the input audio buffer is empty", event.EventID)
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.commitAudio(audio, itemID, false)
+		}()
+	case "input_audio_buffer.clear":
+		s.takeAudio()
+		s.send(schema.RealtimeEvent{Type: "input_audio_buffer.cleared"})
+	case "conversation.item.create":
+		if event.Item == nil {
+			s.sendError("invalid_request_error", "item is required", event.EventID)
+			return
+		}
+		item := *event.Item
+		if item.ID == "" {
+			item.ID = "item_" + uuid.New().String()
+		}
+		item.Object = "realtime.item"
+		item.Status = "completed"
+		s.addItem(item)
+	case "conversation.item.delete":
+		s.mu.Lock()
+		n := len(s.conversation)
+		s.conversation = slices.DeleteFunc(s.conversation, func(i schema.RealtimeItem) bool { return i.ID == event.ItemID })
+		deleted := n != len(s.conversation)
+		s.mu.Unlock()
+		if !deleted {
+			s.sendError("invalid_request_error", fmt.Sprintf("item %s not found", event.ItemID), event.EventID)
+			return
+		}
+		s.send(schema.RealtimeEvent{Type: "conversation.item.deleted", ItemID: event.ItemID})
+	case "response.create":
+		s.startResponse()
+	case "response.cancel":
+		s.cancelCurrentResponse()
+	default:
+		s.sendError("invalid_request_error", fmt.Sprintf("unsupported event type %q", event.Type), event.EventID)
+	}
+}
+
+// updateRealtimeSession applies the fields sent by the client to the session,
+// turn_detection can be set to null to disable the server VAD
+func updateRealtimeSession(session schema.RealtimeSession, update json.RawMessage) (schema.RealtimeSession, error) {
+	updated := session
+	if err := json.Unmarshal(update, &updated); err != nil {
+		return session, err
+	}
+	updated.ID, updated.Object, updated.Model = session.ID, session.Object, session.Model
+
+	if updated.InputAudioFormat != "pcm16" || updated.OutputAudioFormat != "pcm16" {
+		return session, fmt.Errorf("only the pcm16 audio format is supported")
+	}
+	if updated.TurnDetection != nil && updated.TurnDetection.Type != "server_vad" {
+		return session, fmt.Errorf("unsupported turn detection type %q", updated.TurnDetection.Type)
+	}
+	for _, m := range updated.Modalities {
+		if m != "text" && m != "audio" {
+			return session, fmt.Errorf("unsupported modality %q", m)
+		}
+	}
+	return updated, nil
+}
+
+func (s *realtimeSession) currentSession() schema.RealtimeSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.session
+}
+
+// takeAudio empties the input audio buffer, returning its content and the id of the speech item
+func (s *realtimeSession) takeAudio() ([]byte, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	audio, itemID := s.audio, s.inputItemID
+	s.audio, s.inputItemID = nil, ""
+	if itemID == "" {
+		itemID = "item_" + uuid.New().String()
+	}
+	return audio, itemID
+}
+
+func (s *realtimeSession) addItem(item schema.RealtimeItem) {
+	s.mu.Lock()
+	previousItemID := ""
+	if len(s.conversation) > 0 {
+		previousItemID = s.conversation[len(s.conversation)-1].ID
+	}
+	s.conversation = append(s.conversation, item)
+	s.mu.Unlock()
+
+	s.send(schema.RealtimeEvent{Type: "conversation.item.created", PreviousItemID: previousItemID, Item: &item})
+}
+
+// detectTurns periodically runs the VAD on the input audio buffer, the audio is committed
+// when the speech is followed by enough silence. Speech interrupts the ongoing response.
+func (s *realtimeSession) detectTurns(ctx context.Context) {
+	ticker := time.NewTicker(vadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		turnDetection := s.currentSession().TurnDetection
+		if turnDetection == nil {
+			continue
+		}
+		if err := s.detectTurn(ctx, *turnDetection); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msg("realtime: voice activity detection failed")
+		}
+	}
+}
+
+func (s *realtimeSession) detectTurn(ctx context.Context, turnDetection schema.RealtimeTurnDetection) error {
+	prefixPadding := turnDetection.PrefixPaddingMs
+	if prefixPadding <= 0 {
+		prefixPadding = defaultPrefixPaddingMs
+	}
+	silenceDuration := turnDetection.SilenceDurationMs
+	if silenceDuration <= 0 {
+		silenceDuration = defaultSilenceDurationMs
+	}
+
+	s.mu.Lock()
+	samples := sound.ResampleInt16(sound.BytesToInt16sLE(s.audio), realtimeSampleRate, vadSampleRate)
+	s.mu.Unlock()
+	if len(samples) < vadSampleRate/10 {
+		return nil
+	}
+
+	resp, err := backend.VAD(ctx, &proto.VADRequest{Audio: sound.Int16ToFloat32(samples)}, s.ml, s.appConfig, *s.vadConfig)
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Segments) == 0 {
+		// no speech yet: only keep the padding that precedes the speech
+		keep := 2 * realtimeSampleRate * (prefixPadding + int(vadInterval.Milliseconds())) / 1000
+		s.mu.Lock()
+		if s.inputItemID == "" && len(s.audio) > keep {
+			s.audio = append([]byte(nil), s.audio[len(s.audio)-keep:]...)
+		}
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.mu.Lock()
+	itemID := s.inputItemID
+	started := itemID == ""
+	if started {
+		itemID = "item_" + uuid.New().String()
+		s.inputItemID = itemID
+	}
+	s.mu.Unlock()
+
+	if started {
+		// the user is talking over the assistant
+		s.cancelCurrentResponse()
+		s.send(schema.RealtimeEvent{Type: "input_audio_buffer.speech_started", ItemID: itemID, AudioStartMs: int(resp.Segments[0].Start * 1000)})
+	}
+
+	// the end of a segment is not set while the speech is ongoing
+	end := resp.Segments[len(resp.Segments)-1].End
+	duration := float32(len(samples)) / vadSampleRate
+	if end == 0 || duration-end < float32(silenceDuration)/1000 {
+		return nil
+	}
+
+	audio, itemID := s.takeAudio()
+	s.send(schema.RealtimeEvent{Type: "input_audio_buffer.speech_stopped", ItemID: itemID, AudioEndMs: int(end * 1000)})
+	s.commitAudio(audio, itemID, turnDetection.CreateResponse == nil || *turnDetection.CreateResponse)
+	return nil
+}
+
+// commitAudio transcribes the audio to a user item of the conversation, and replies to it if requested
+func (s *realtimeSession) commitAudio(audio []byte, itemID string, respond bool) {
+	s.send(schema.RealtimeEvent{Type: "input_audio_buffer.committed", ItemID: itemID})
+
+	transcript, err := s.transcribe(audio)
+	if err != nil {
+		log.Error().Err(err).Msg("realtime: transcription failed")
+		s.sendError("server_error", fmt.Sprintf("transcription failed: %s", err.Error()), "")
+		return
+	}
+
+	s.addItem(schema.RealtimeItem{
+		ID:      itemID,
+		Object:  "realtime.item",
+		Type:    "message",
+		Status:  "completed",
+		Role:    "user",
+		Content: []schema.RealtimeContent{{Type: "input_audio", Transcript: transcript}},
+	})
+	s.send(schema.RealtimeEvent{Type: "conversation.item.input_audio_transcription.completed", ItemID: itemID, Transcript: transcript})
+
+	if respond {
+		s.startResponse()
+	}
+}
+
+func (s *realtimeSession) transcribe(audio []byte) (string, error) {
+	if s.transcriptionConfig == nil {
+		return "", fmt.Errorf("the pipeline does not define a transcription model")
+	}
+
+	dir, err := os.MkdirTemp("", "realtime")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, "input.wav")
+	samples := sound.ResampleInt16(sound.BytesToInt16sLE(audio), realtimeSampleRate, vadSampleRate)
+	if err := sound.WriteWavInt16(dst, samples, vadSampleRate); err != nil {
+		return "", err
+	}
+
+	language := ""
+	if t := s.currentSession().InputAudioTranscription; t != nil {
+		language = t.Language
+	}
+
+	tr, err := backend.ModelTranscription(dst, language, false, false, "", 0, s.ml, *s.transcriptionConfig, s.appConfig)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tr.Text), nil
+}
+
+// startResponse interrupts the ongoing response, if any, and generates a new one
+func (s *realtimeSession) startResponse() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	done := make(chan struct{})
+
+	s.mu.Lock()
+	if s.cancelResponse != nil {
+		s.cancelResponse()
+	}
+	previous := s.responseDone
+	s.cancelResponse, s.responseDone = cancel, done
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(done)
+		defer cancel()
+		if previous != nil {
+			<-previous
+		}
+		s.respond(ctx)
+	}()
+}
+
+func (s *realtimeSession) cancelCurrentResponse() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancelResponse != nil {
+		s.cancelResponse()
+	}
+}
+
+// respond generates the reply of the LLM to the conversation, streaming its transcript and its synthesized audio
+func (s *realtimeSession) respond(ctx context.Context) {
+	session := s.currentSession()
+	s.mu.Lock()
+	messages := realtimeMessages(session.Instructions, s.conversation)
+	s.mu.Unlock()
+
+	audioOutput := slices.Contains(session.Modalities, "audio")
+	contentType, deltaType := "text", "response.text.delta"
+	if audioOutput {
+		contentType, deltaType = "audio", "response.audio_transcript.delta"
+	}
+
+	response := schema.RealtimeResponse{
+		ID:     "resp_" + uuid.New().String(),
+		Object: "realtime.response",
+		Status: "in_progress",
+		Output: []schema.RealtimeItem{},
+	}
+	item := schema.RealtimeItem{
+		ID:      "item_" + uuid.New().String(),
+		Object:  "realtime.item",
+		Type:    "message",
+		Status:  "in_progress",
+		Role:    "assistant",
+		Content: []schema.RealtimeContent{},
+	}
+	s.send(schema.RealtimeEvent{Type: "response.created", Response: &response})
+	s.send(schema.RealtimeEvent{Type: "response.output_item.added", ResponseID: response.ID, Item: &item})
+
+	text, usage, err := s.generate(ctx, messages, session, func(token string) {
+		s.send(schema.RealtimeEvent{Type: deltaType, ResponseID: response.ID, ItemID: item.ID, Delta: token})
+	})
+	if err == nil && audioOutput && text != "" {
+		err = s.synthesize(ctx, text, session.Voice, func(audio string) {
+			s.send(schema.RealtimeEvent{Type: "response.audio.delta", ResponseID: response.ID, ItemID: item.ID, Delta: audio})
+		})
+		if err == nil {
+			s.send(schema.RealtimeEvent{Type: "response.audio.done", ResponseID: response.ID, ItemID: item.ID})
+		}
+	}
+
+	content := schema.RealtimeContent{Type: contentType}
+	if audioOutput {
+		content.Transcript = text
+		s.send(schema.RealtimeEvent{Type: "response.audio_transcript.done", ResponseID: response.ID, ItemID: item.ID, Transcript: text})
+	} else {
+		content.Text = text
+		s.send(schema.RealtimeEvent{Type: "response.text.done", ResponseID: response.ID, ItemID: item.ID, Text: text})
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		// the partial reply is kept in the conversation, as it was heard by the user
+		response.Status, item.Status = "cancelled", "incomplete"
+	case err != nil:
+		log.Error().Err(err).Msg("realtime: response failed")
+		s.sendError("server_error", err.Error(), "")
+		response.Status, item.Status = "failed", "incomplete"
+	default:
+		response.Status, item.Status = "completed", "completed"
+	}
+	item.Content = []schema.RealtimeContent{content}
+	if text != "" {
+		s.addItem(item)
+	}
+	s.send(schema.RealtimeEvent{Type: "response.output_item.done", ResponseID: response.ID, Item: &item})
+
+	response.Output = []schema.RealtimeItem{item}
+	response.Usage = &schema.RealtimeUsage{
+		InputTokens:  usage.Prompt,
+		OutputTokens: usage.Completion,
+		TotalTokens:  usage.Prompt + usage.Completion,
+	}
+	s.send(schema.RealtimeEvent{Type: "response.done", Response: &response})
+}
+
+// generate runs the LLM of the pipeline on the conversation, the tokens are passed to the callback as they are generated
+func (s *realtimeSession) generate(ctx context.Context, messages []schema.Message, session schema.RealtimeSession, cb func(string)) (string, backend.TokenUsage, error) {
+	cfg := *s.llmConfig
+	if session.Temperature != nil {
+		cfg.Temperature = session.Temperature
+	}
+
+	predInput := ""
+	if !cfg.TemplateConfig.UseTokenizerTemplate {
+		predInput = s.evaluator.TemplateMessages(messages, &cfg, nil, false)
+		log.Debug().Msgf("Prompt (after templating): %s", predInput)
+	}
+
+	predFunc, err := backend.ModelInference(ctx, predInput, messages, nil, nil, nil, s.ml, cfg, s.appConfig, func(token string, _ backend.TokenUsage, _ []schema.LogprobContent) bool {
+		if token != "" && ctx.Err() == nil {
+			cb(token)
+		}
+		return ctx.Err() == nil
+	})
+	if err != nil {
+		return "", backend.TokenUsage{}, err
+	}
+
+	prediction, err := predFunc()
+	return backend.Finetune(cfg, predInput, prediction.Response), prediction.Usage, err
+}
+
+// synthesize generates the speech of the text, the audio is passed to the callback in base64 encoded pcm16 chunks
+func (s *realtimeSession) synthesize(ctx context.Context, text, voice string, cb func(string)) error {
+	if s.ttsConfig == nil {
+		return fmt.Errorf("the pipeline does not define a TTS model")
+	}
+	if voice == "" {
+		voice = s.ttsConfig.Voice
+	}
+
+	audioPath, _, err := backend.ModelTTS(s.ttsConfig.Backend, text, s.ttsConfig.Model, voice, s.ttsConfig.Language, s.ml, s.appConfig, *s.ttsConfig)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(audioPath)
+
+	samples, sampleRate, err := sound.ReadWavInt16(audioPath)
+	if err != nil {
+		return err
+	}
+	samples = sound.ResampleInt16(samples, sampleRate, realtimeSampleRate)
+
+	for i := 0; i < len(samples); i += realtimeAudioChunk {
+		if ctx.Err() != nil {
+			return nil
+		}
+		chunk := samples[i:min(i+realtimeAudioChunk, len(samples))]
+		cb(base64.StdEncoding.EncodeToString(sound.Int16toBytesLE(chunk)))
+	}
+	return nil
+}
+
+// realtimeMessages converts the conversation to the messages for the LLM
+func realtimeMessages(instructions string, conversation []schema.RealtimeItem) []schema.Message {
+	messages := []schema.Message{}
+	if instructions != "" {
+		messages = append(messages, schema.Message{Role: "system", Content: instructions, StringContent: instructions})
+	}
+	for _, item := range conversation {
+		text := item.Text()
+		if item.Type != "message" || text == "" {
+			continue
+		}
+		messages = append(messages, schema.Message{Role: item.Role, Content: text, StringContent: text})
+	}
+	return messages
+}
+
+func (s *realtimeSession) send(event schema.RealtimeEvent) {
+	event.EventID = "event_" + uuid.New().String()
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.conn.WriteJSON(event); err != nil {
+		log.Debug().Err(err).Str("type", event.Type).Msg("realtime: failed sending event")
+	}
+}
+
+func (s *realtimeSession) sendError(errorType, message, eventID string) {
+	s.send(schema.RealtimeEvent{Type: "error", Error: &schema.RealtimeError{Type: errorType, Message: message, EventID: eventID}})
+}
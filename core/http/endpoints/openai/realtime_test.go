@@ -0,0 +1,69 @@
+package openai
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateRealtimeSession(t *testing.T) {
+	session := schema.RealtimeSession{
+		ID:                "sess_1",
+		Model:             "realtime",
+		Modalities:        []string{"text", "audio"},
+		InputAudioFormat:  "pcm16",
+		OutputAudioFormat: "pcm16",
+		TurnDetection:     &schema.RealtimeTurnDetection{Type: "server_vad", SilenceDurationMs: 500},
+	}
+
+	updated, err := updateRealtimeSession(session, []byte(`{"id": "other", "instructions": "Be brief", "turn_detection": {"type": "server_vad", "silence_duration_ms": 800}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "sess_1", updated.ID)
+	assert.Equal(t, "Be brief", updated.Instructions)
+	assert.Equal(t, []string{"text", "audio"}, updated.Modalities)
+	assert.Equal(t, 800, updated.TurnDetection.SilenceDurationMs)
+
+	updated, err = updateRealtimeSession(session, []byte(`{"modalities": ["text"], "turn_detection": null}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"text"}, updated.Modalities)
+	assert.Nil(t, updated.TurnDetection)
+	assert.NotNil(t, session.TurnDetection)
+
+	for _, update := range []string{
+		`{"input_audio_format": "g711_ulaw"}`,
+		`{"turn_detection": {"type": "semantic"}}`,
+		`{"modalities": ["video"]}`,
+	} {
+		_, err = updateRealtimeSession(session, []byte(update))
+		assert.Error(t, err, update)
+	}
+}
+
+func TestRealtimeMessages(t *testing.T) {
+	messages := realtimeMessages("Be brief", []schema.RealtimeItem{
+		{Type: "message", Role: "user", Content: []schema.RealtimeContent{{Type: "input_audio", Transcript: "Hello"}}},
+		{Type: "message", Role: "assistant", Content: []schema.RealtimeContent{{Type: "audio"}}},
+		{Type: "message", Role: "assistant", Content: []schema.RealtimeContent{{Type: "text", Text: "Hi!"}}},
+	})
+
+	assert.Len(t, messages, 3)
+	assert.Equal(t, "system", messages[0].Role)
+	assert.Equal(t, "Be brief", messages[0].StringContent)
+	assert.Equal(t, "Hello", messages[1].StringContent)
+	assert.Equal(t, "Hi!", messages[2].StringContent)
+}
+
+func TestRealtimeRequiresWebSocket(t *testing.T) {
+	app := fiber.New()
+	app.Get("/v1/realtime", RealtimeEndpoint(&config.BackendConfigLoader{}, model.NewModelLoader("/tmp/localai/model"), templates.NewEvaluator("/tmp/localai/model"), &config.ApplicationConfig{}))
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/v1/realtime?model=realtime", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, fiber.StatusUpgradeRequired, resp.StatusCode)
+}
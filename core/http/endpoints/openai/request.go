@@ -35,6 +35,7 @@ func readRequest(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLo
 	correlationID := c.Get("X-Correlation-ID", uuid.New().String())
 
 	ctx, cancel := context.WithCancel(o.Context)
+	ctx = model.WithPriority(ctx, fiberContext.PriorityFromContext(c, o))
 	// Add the correlation ID to the new context
 	ctxWithCorrelationID := context.WithValue(ctx, CorrelationIDKey, correlationID)
 
@@ -95,6 +96,14 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 		config.Grammar = input.Grammar
 	}
 
+	if input.EncodingFormat != "" {
+		config.EncodingFormat = input.EncodingFormat
+	}
+
+	if input.Dimensions != 0 {
+		config.Dimensions = input.Dimensions
+	}
+
 	if input.Temperature != nil {
 		config.Temperature = input.Temperature
 	}
@@ -112,6 +121,19 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 		}
 	}
 
+	switch logprobs := input.Logprobs.(type) {
+	case bool:
+		config.Logprobs = logprobs
+	case float64:
+		// completions request the number of most likely tokens instead
+		config.Logprobs = true
+		config.TopLogprobs = int(logprobs)
+	}
+
+	if input.TopLogprobs != nil {
+		config.TopLogprobs = *input.TopLogprobs
+	}
+
 	switch stop := input.Stop.(type) {
 	case string:
 		if stop != "" {
@@ -136,16 +158,25 @@ func updateRequestConfig(config *config.BackendConfig, input *schema.OpenAIReque
 
 		switch content := input.ToolsChoice.(type) {
 		case string:
-			_ = json.Unmarshal([]byte(content), &toolChoice)
+			// "none", "auto" and "required" select the calling mode
+			if err := json.Unmarshal([]byte(content), &toolChoice); err != nil {
+				input.FunctionCall = content
+			}
 		case map[string]interface{}:
 			dat, _ := json.Marshal(content)
 			_ = json.Unmarshal(dat, &toolChoice)
 		}
-		input.FunctionCall = map[string]interface{}{
-			"name": toolChoice.Function.Name,
+		if toolChoice.Function.Name != "" {
+			input.FunctionCall = map[string]interface{}{
+				"name": toolChoice.Function.Name,
+			}
 		}
 	}
 
+	if input.ParallelToolCalls != nil {
+		config.FunctionsConfig.GrammarConfig.ParallelCalls = *input.ParallelToolCalls
+	}
+
 	// Decode each request's message content
 	imgIndex, vidIndex, audioIndex := 0, 0, 0
 	for i, m := range input.Messages {
@@ -0,0 +1,1100 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+	"github.com/valyala/fasthttp"
+)
+
+// RunStatus is the status of a run or of a run step
+type RunStatus string
+
+const (
+	RunStatusQueued         RunStatus = "queued"
+	RunStatusInProgress     RunStatus = "in_progress"
+	RunStatusRequiresAction RunStatus = "requires_action"
+	RunStatusCancelling     RunStatus = "cancelling"
+	RunStatusCancelled      RunStatus = "cancelled"
+	RunStatusFailed         RunStatus = "failed"
+	RunStatusCompleted      RunStatus = "completed"
+)
+
+type RunToolCallFunction struct {
+	Name      string  `json:"name"`
+	Arguments string  `json:"arguments"`
+	Output    *string `json:"output,omitempty"` // Set once the output has been submitted
+}
+
+// RunToolCall is a function call requested by the model during a run
+type RunToolCall struct {
+	ID       string              `json:"id"`
+	Type     string              `json:"type"`
+	Function RunToolCallFunction `json:"function"`
+}
+
+type SubmitToolOutputsAction struct {
+	ToolCalls []RunToolCall `json:"tool_calls"`
+}
+
+// RequiredAction details the action required to continue a run which is in the requires_action state
+type RequiredAction struct {
+	Type              string                  `json:"type"` // Always "submit_tool_outputs"
+	SubmitToolOutputs SubmitToolOutputsAction `json:"submit_tool_outputs"`
+}
+
+type RunError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Run represents the structure of a run object from the OpenAI API.
+type Run struct {
+	ID             string              `json:"id"`                              // The unique identifier of the run.
+	Object         string              `json:"object"`                          // Object type, which is "thread.run".
+	CreatedAt      int64               `json:"created_at"`                      // The time at which the run was created.
+	ThreadID       string              `json:"thread_id"`                       // The thread that was executed on.
+	AssistantID    string              `json:"assistant_id"`                    // The assistant used for execution of the run.
+	Status         RunStatus           `json:"status"`                          // The status of the run.
+	RequiredAction *RequiredAction     `json:"required_action"`                 // Details on the action required to continue the run.
+	LastError      *RunError           `json:"last_error"`                      // The last error associated with this run.
+	StartedAt      int64               `json:"started_at,omitempty"`            // The time at which the run was started.
+	CancelledAt    int64               `json:"cancelled_at,omitempty"`          // The time at which the run was cancelled.
+	FailedAt       int64               `json:"failed_at,omitempty"`             // The time at which the run failed.
+	CompletedAt    int64               `json:"completed_at,omitempty"`          // The time at which the run was completed.
+	Model          string              `json:"model"`                           // The model that the assistant used for this run.
+	Instructions   string              `json:"instructions"`                    // The instructions that the assistant used for this run.
+	Tools          []Tool              `json:"tools"`                           // The list of tools that the assistant used for this run.
+	Metadata       map[string]string   `json:"metadata,omitempty"`              // Set of key-value pairs attached to the run.
+	Usage          *schema.OpenAIUsage `json:"usage"`                           // Usage statistics, set once the run is in a terminal state.
+	Temperature    *float64            `json:"temperature,omitempty"`           // Sampling temperature used for this run.
+	TopP           *float64            `json:"top_p,omitempty"`                 // Nucleus sampling value used for this run.
+	MaxTokens      *int                `json:"max_completion_tokens,omitempty"` // Maximum number of completion tokens for this run.
+}
+
+type RunStepMessageCreation struct {
+	MessageID string `json:"message_id"`
+}
+
+type RunStepDetails struct {
+	Type            string                  `json:"type"` // Either message_creation or tool_calls
+	MessageCreation *RunStepMessageCreation `json:"message_creation,omitempty"`
+	ToolCalls       []RunToolCall           `json:"tool_calls,omitempty"`
+}
+
+// RunStep represents the structure of a run step object from the OpenAI API.
+type RunStep struct {
+	ID          string              `json:"id"`
+	Object      string              `json:"object"` // Object type, which is "thread.run.step".
+	CreatedAt   int64               `json:"created_at"`
+	AssistantID string              `json:"assistant_id"`
+	ThreadID    string              `json:"thread_id"`
+	RunID       string              `json:"run_id"`
+	Type        string              `json:"type"`
+	Status      RunStatus           `json:"status"`
+	StepDetails RunStepDetails      `json:"step_details"`
+	LastError   *RunError           `json:"last_error"`
+	CompletedAt int64               `json:"completed_at,omitempty"`
+	Usage       *schema.OpenAIUsage `json:"usage"`
+}
+
+type RunRequest struct {
+	AssistantID            string                 `json:"assistant_id"`
+	Model                  string                 `json:"model,omitempty"`
+	Instructions           string                 `json:"instructions,omitempty"`
+	AdditionalInstructions string                 `json:"additional_instructions,omitempty"`
+	AdditionalMessages     []ThreadMessageRequest `json:"additional_messages,omitempty"`
+	Tools                  []Tool                 `json:"tools,omitempty"`
+	Metadata               map[string]string      `json:"metadata,omitempty"`
+	Temperature            *float64               `json:"temperature,omitempty"`
+	TopP                   *float64               `json:"top_p,omitempty"`
+	MaxTokens              *int                   `json:"max_completion_tokens,omitempty"`
+	Stream                 bool                   `json:"stream,omitempty"`
+}
+
+type CreateThreadAndRunRequest struct {
+	RunRequest
+	Thread ThreadRequest `json:"thread"`
+}
+
+type ToolOutput struct {
+	ToolCallID string `json:"tool_call_id"`
+	Output     string `json:"output"`
+}
+
+type SubmitToolOutputsRequest struct {
+	ToolOutputs []ToolOutput `json:"tool_outputs"`
+	Stream      bool         `json:"stream,omitempty"`
+}
+
+var (
+	Runs               = []Run{}
+	RunsConfigFile     = "runs.json"
+	RunSteps           = []RunStep{}
+	RunStepsConfigFile = "runSteps.json"
+
+	// runCancels holds the cancel function of the runs that are executing, guarded by threadsMu
+	runCancels = map[string]context.CancelFunc{}
+)
+
+// runEvent is a server-sent event emitted while a run is executing
+type runEvent struct {
+	Event string
+	Data  interface{}
+}
+
+func saveRuns(appConfig *config.ApplicationConfig) {
+	utils.SaveConfig(appConfig.ConfigsDir, RunsConfigFile, Runs)
+	utils.SaveConfig(appConfig.ConfigsDir, RunStepsConfigFile, RunSteps)
+}
+
+// findRun returns the run with the given ID in the given thread, or nil. It must be called with threadsMu held.
+func findRun(threadID, runID string) *Run {
+	for i := range Runs {
+		if Runs[i].ID == runID && Runs[i].ThreadID == threadID {
+			return &Runs[i]
+		}
+	}
+	return nil
+}
+
+// findRunStep returns the run step with the given ID, or nil. It must be called with threadsMu held.
+func findRunStep(stepID string) *RunStep {
+	for i := range RunSteps {
+		if RunSteps[i].ID == stepID {
+			return &RunSteps[i]
+		}
+	}
+	return nil
+}
+
+// activeRun returns the run of the thread which is not in a terminal state, if any. It must be called with threadsMu held.
+func activeRun(threadID string) *Run {
+	for i := range Runs {
+		if Runs[i].ThreadID != threadID {
+			continue
+		}
+		switch Runs[i].Status {
+		case RunStatusQueued, RunStatusInProgress, RunStatusRequiresAction, RunStatusCancelling:
+			return &Runs[i]
+		}
+	}
+	return nil
+}
+
+// RecoverRuns stops the runs which were executing when LocalAI was stopped, so their threads can be used again:
+// they are marked as failed, or as cancelled if they were being cancelled. The runs requiring an action are kept,
+// they continue once the tool outputs are submitted.
+func RecoverRuns(appConfig *config.ApplicationConfig) {
+	threadsMu.Lock()
+	defer threadsMu.Unlock()
+
+	now := time.Now().Unix()
+	interrupted := map[string]RunStatus{}
+	for i := range Runs {
+		r := &Runs[i]
+		switch r.Status {
+		case RunStatusQueued, RunStatusInProgress:
+			r.Status = RunStatusFailed
+			r.FailedAt = now
+			r.LastError = &RunError{Code: "server_error", Message: "LocalAI was restarted while the run was executing"}
+		case RunStatusCancelling:
+			r.Status = RunStatusCancelled
+			r.CancelledAt = now
+		default:
+			continue
+		}
+		interrupted[r.ID] = r.Status
+	}
+	if len(interrupted) == 0 {
+		return
+	}
+
+	for i := range RunSteps {
+		s := &RunSteps[i]
+		if status, ok := interrupted[s.RunID]; ok && s.Status == RunStatusInProgress {
+			s.Status = status
+			if status == RunStatusFailed {
+				s.LastError = &RunError{Code: "server_error", Message: "LocalAI was restarted while the run was executing"}
+			}
+		}
+	}
+	for i := range ThreadMessages {
+		if _, ok := interrupted[ThreadMessages[i].RunID]; ok && ThreadMessages[i].Status == "in_progress" {
+			ThreadMessages[i].Status = "incomplete"
+		}
+	}
+
+	log.Warn().Int("runs", len(interrupted)).Msg("stopped the runs interrupted by a restart")
+	saveThreads(appConfig)
+	saveRuns(appConfig)
+}
+
+// cancelRun stops the execution of a run if it is in progress. It must be called with threadsMu held.
+func cancelRun(runID string) {
+	if cancel, ok := runCancels[runID]; ok {
+		cancel()
+		delete(runCancels, runID)
+	}
+}
+
+func findAssistant(assistantID string) *Assistant {
+	for _, assistant := range Assistants {
+		if assistant.ID == assistantID {
+			return &assistant
+		}
+	}
+	return nil
+}
+
+// newRun validates a run request and creates a queued run on the thread. It must be called with threadsMu held.
+func newRun(threadID string, request RunRequest) (*Run, error) {
+	assistant := findAssistant(request.AssistantID)
+	if assistant == nil {
+		return nil, fmt.Errorf("unable to find assistant with id: %s", request.AssistantID)
+	}
+
+	if activeRun(threadID) != nil {
+		return nil, fmt.Errorf("thread %s already has an active run", threadID)
+	}
+
+	for _, m := range request.AdditionalMessages {
+		if _, err := newThreadMessage(threadID, m); err != nil {
+			return nil, err
+		}
+	}
+
+	run := Run{
+		ID:           newObjectID("run"),
+		Object:       "thread.run",
+		CreatedAt:    time.Now().Unix(),
+		ThreadID:     threadID,
+		AssistantID:  assistant.ID,
+		Status:       RunStatusQueued,
+		Model:        assistant.Model,
+		Instructions: assistant.Instructions,
+		Tools:        assistant.Tools,
+		Metadata:     request.Metadata,
+		Temperature:  request.Temperature,
+		TopP:         request.TopP,
+		MaxTokens:    request.MaxTokens,
+	}
+
+	if request.Model != "" {
+		run.Model = request.Model
+	}
+	if request.Instructions != "" {
+		run.Instructions = request.Instructions
+	}
+	if request.AdditionalInstructions != "" {
+		run.Instructions += "\n" + request.AdditionalInstructions
+	}
+	if request.Tools != nil {
+		run.Tools = request.Tools
+	}
+	if run.Tools == nil {
+		run.Tools = []Tool{}
+	}
+	if run.Metadata == nil {
+		run.Metadata = make(map[string]string)
+	}
+
+	Runs = append(Runs, run)
+	return &run, nil
+}
+
+// runExecutor executes runs against the assistant model in the background
+type runExecutor struct {
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+	evaluator *templates.Evaluator
+	appConfig *config.ApplicationConfig
+	// router resolves the virtual models to the model serving the run
+	router *middleware.ModelRouter
+}
+
+func newRunExecutor(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) *runExecutor {
+	return &runExecutor{cl: cl, ml: ml, evaluator: evaluator, appConfig: appConfig, router: middleware.NewModelRouter(cl, ml, appConfig)}
+}
+
+// start executes the run in the background. If stream is true, the returned channel receives
+// the run events and is closed when the run stops executing, otherwise nil is returned.
+// It must be called with threadsMu held.
+func (e *runExecutor) start(run *Run, stream bool) chan runEvent {
+	var events chan runEvent
+	if stream {
+		events = make(chan runEvent)
+	}
+
+	// run points into Runs, which might be reallocated while the run executes
+	threadID, runID := run.ThreadID, run.ID
+
+	ctx, cancel := context.WithCancel(e.appConfig.Context)
+	runCancels[runID] = cancel
+
+	go func() {
+		e.execute(ctx, threadID, runID, func(event string, data interface{}) {
+			if events != nil {
+				events <- runEvent{Event: event, Data: data}
+			}
+		})
+
+		threadsMu.Lock()
+		cancelRun(runID)
+		threadsMu.Unlock()
+
+		if events != nil {
+			close(events)
+		}
+	}()
+
+	return events
+}
+
+// conversation returns the chat messages of the thread followed by the tool calls already answered during the run.
+// It must be called with threadsMu held.
+func conversation(run *Run) []schema.Message {
+	messages := []schema.Message{}
+	if run.Instructions != "" {
+		messages = append(messages, schema.Message{Role: "system", Content: run.Instructions})
+	}
+	for _, m := range threadMessagesFor(run.ThreadID) {
+		messages = append(messages, m.toSchemaMessage())
+	}
+
+	for _, step := range RunSteps {
+		if step.RunID != run.ID || step.Type != "tool_calls" || step.Status != RunStatusCompleted {
+			continue
+		}
+		toolCalls := []schema.ToolCall{}
+		for i, tc := range step.StepDetails.ToolCalls {
+			toolCalls = append(toolCalls, schema.ToolCall{
+				Index: i,
+				ID:    tc.ID,
+				Type:  tc.Type,
+				FunctionCall: schema.FunctionCall{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
+		messages = append(messages, schema.Message{Role: "assistant", ToolCalls: toolCalls})
+		for _, tc := range step.StepDetails.ToolCalls {
+			output := ""
+			if tc.Function.Output != nil {
+				output = *tc.Function.Output
+			}
+			messages = append(messages, schema.Message{Role: "tool", Name: tc.Function.Name, Content: output})
+		}
+	}
+	return messages
+}
+
+// updateRun applies fn to the run and persists the change, returning a copy of the updated run for the events
+func (e *runExecutor) updateRun(threadID, runID string, fn func(*Run)) Run {
+	threadsMu.Lock()
+	defer threadsMu.Unlock()
+
+	run := findRun(threadID, runID)
+	if run == nil {
+		// The thread was deleted while the run was executing
+		return Run{ID: runID, ThreadID: threadID}
+	}
+	fn(run)
+	saveRuns(e.appConfig)
+	return *run
+}
+
+func (e *runExecutor) execute(ctx context.Context, threadID, runID string, emit func(string, interface{})) {
+	threadsMu.Lock()
+	run := findRun(threadID, runID)
+	if run == nil {
+		threadsMu.Unlock()
+		return
+	}
+	messages := conversation(run)
+	current := *run
+	threadsMu.Unlock()
+
+	emit("thread.run.queued", current)
+
+	current = e.updateRun(threadID, runID, func(r *Run) {
+		r.Status = RunStatusInProgress
+		r.StartedAt = time.Now().Unix()
+	})
+	emit("thread.run.in_progress", current)
+
+	fail := func(err error) {
+		if ctx.Err() != nil {
+			r := e.updateRun(threadID, runID, func(r *Run) {
+				r.Status = RunStatusCancelled
+				r.CancelledAt = time.Now().Unix()
+			})
+			emit("thread.run.cancelled", r)
+			return
+		}
+		log.Error().Err(err).Str("run", runID).Msg("run failed")
+		r := e.updateRun(threadID, runID, func(r *Run) {
+			r.Status = RunStatusFailed
+			r.FailedAt = time.Now().Unix()
+			r.LastError = &RunError{Code: "server_error", Message: err.Error()}
+		})
+		emit("thread.run.failed", r)
+	}
+
+	tools := []functions.Tool{}
+	for _, t := range current.Tools {
+		if t.Type == Function && t.Function != nil {
+			tools = append(tools, functions.Tool{Type: string(Function), Function: *t.Function})
+		}
+	}
+
+	input := &schema.OpenAIRequest{
+		PredictionOptions: schema.PredictionOptions{
+			Model:       current.Model,
+			Temperature: current.Temperature,
+			TopP:        current.TopP,
+			Maxtokens:   current.MaxTokens,
+		},
+		Context:  ctx,
+		Cancel:   func() {},
+		Messages: messages,
+		Tools:    tools,
+	}
+
+	modelName, err := e.router.Resolve(current.Model)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	cfg, input, err := mergeRequestWithConfig(modelName, input, e.cl, e.ml, e.appConfig.Debug, e.appConfig.Threads, e.appConfig.ContextSize, e.appConfig.F16)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	funcs := input.Functions
+	shouldUseFn := len(funcs) > 0 && cfg.ShouldUseFunctions()
+
+	noActionName := "answer"
+	noActionDescription := "use this action to answer without performing any action"
+	if cfg.FunctionsConfig.NoActionFunctionName != "" {
+		noActionName = cfg.FunctionsConfig.NoActionFunctionName
+	}
+	if cfg.FunctionsConfig.NoActionDescriptionName != "" {
+		noActionDescription = cfg.FunctionsConfig.NoActionDescriptionName
+	}
+
+	if shouldUseFn && !cfg.FunctionsConfig.GrammarConfig.NoGrammar {
+		if !cfg.FunctionsConfig.DisableNoAction {
+			funcs = append(funcs, functions.Function{
+				Name:        noActionName,
+				Description: noActionDescription,
+				Parameters: map[string]interface{}{
+					"properties": map[string]interface{}{
+						"message": map[string]interface{}{
+							"type":        "string",
+							"description": "The message to reply the user with",
+						}},
+				},
+			})
+		}
+
+		jsStruct := funcs.ToJSONStructure(cfg.FunctionsConfig.FunctionNameKey, cfg.FunctionsConfig.FunctionNameKey)
+		g, err := jsStruct.Grammar(cfg.FunctionsConfig.GrammarOptions()...)
+		if err == nil {
+			cfg.Grammar = g
+		}
+	}
+
+	var predInput string
+	if !cfg.TemplateConfig.UseTokenizerTemplate || shouldUseFn {
+		predInput = e.evaluator.TemplateMessages(input.Messages, cfg, funcs, shouldUseFn)
+		log.Debug().Msgf("Run %s prompt (after templating): %s", runID, predInput)
+	}
+
+	// Without functions the reply is streamed token by token in a message which is created upfront
+	var message *ThreadMessage
+	var step *RunStep
+	var tokenCallback func(int, string, backend.TokenUsage, []schema.LogprobContent) bool
+	if !shouldUseFn {
+		message, step = e.createMessage(current, "in_progress", emit)
+		tokenCallback = func(_ int, s string, _ backend.TokenUsage, _ []schema.LogprobContent) bool {
+			emit("thread.message.delta", map[string]interface{}{
+				"id":     message.ID,
+				"object": "thread.message.delta",
+				"delta": map[string]interface{}{
+					"content": []map[string]interface{}{
+						{"index": 0, "type": "text", "text": map[string]interface{}{"value": s}},
+					},
+				},
+			})
+			return true
+		}
+	}
+
+	choices, tokenUsage, err := ComputeChoices(input, predInput, cfg, e.appConfig, e.ml, func(s string, c *[]schema.Choice) {
+		*c = append(*c, schema.Choice{Text: s})
+	}, tokenCallback)
+	if err == nil && len(choices) == 0 {
+		err = errors.New("the model returned no result")
+	}
+	if err != nil {
+		if message != nil {
+			e.completeMessage(threadID, message.ID, step.ID, "incomplete", nil, emit)
+		}
+		fail(err)
+		return
+	}
+
+	usage := &schema.OpenAIUsage{
+		PromptTokens:     tokenUsage.Prompt,
+		CompletionTokens: tokenUsage.Completion,
+		TotalTokens:      tokenUsage.Prompt + tokenUsage.Completion,
+	}
+
+	result := choices[0].Text
+	if shouldUseFn {
+		textContent := functions.ParseTextContent(result, cfg.FunctionsConfig)
+		result = functions.CleanupLLMResult(result, cfg.FunctionsConfig)
+		results := functions.ParseFunctionCall(result, cfg.FunctionsConfig)
+
+		if len(results) > 0 && results[0].Name != noActionName {
+			e.requireAction(threadID, runID, results, usage, emit)
+			return
+		}
+
+		reply, err := handleQuestion(cfg, input, e.ml, e.appConfig, results, result, predInput)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if textContent != "" && reply == "" {
+			reply = textContent
+		}
+		result = reply
+		message, step = e.createMessage(current, "in_progress", emit)
+	}
+
+	e.completeMessage(threadID, message.ID, step.ID, "completed", &result, emit)
+
+	r := e.updateRun(threadID, runID, func(r *Run) {
+		r.Status = RunStatusCompleted
+		r.CompletedAt = time.Now().Unix()
+		r.Usage = usage
+	})
+	emit("thread.run.completed", r)
+}
+
+// createMessage creates the assistant message of the run along with its message_creation step
+func (e *runExecutor) createMessage(run Run, status string, emit func(string, interface{})) (*ThreadMessage, *RunStep) {
+	threadsMu.Lock()
+	message := ThreadMessage{
+		ID:          newObjectID("msg"),
+		Object:      "thread.message",
+		CreatedAt:   time.Now().Unix(),
+		ThreadID:    run.ThreadID,
+		Status:      status,
+		Role:        "assistant",
+		Content:     []MessageContent{},
+		AssistantID: run.AssistantID,
+		RunID:       run.ID,
+		Metadata:    map[string]string{},
+	}
+	ThreadMessages = append(ThreadMessages, message)
+
+	step := RunStep{
+		ID:          newObjectID("step"),
+		Object:      "thread.run.step",
+		CreatedAt:   time.Now().Unix(),
+		AssistantID: run.AssistantID,
+		ThreadID:    run.ThreadID,
+		RunID:       run.ID,
+		Type:        "message_creation",
+		Status:      RunStatusInProgress,
+		StepDetails: RunStepDetails{
+			Type:            "message_creation",
+			MessageCreation: &RunStepMessageCreation{MessageID: message.ID},
+		},
+	}
+	RunSteps = append(RunSteps, step)
+	saveThreads(e.appConfig)
+	saveRuns(e.appConfig)
+	threadsMu.Unlock()
+
+	emit("thread.run.step.created", step)
+	emit("thread.message.created", message)
+	return &message, &step
+}
+
+// completeMessage sets the final content and status of the message created by a run and completes its step
+func (e *runExecutor) completeMessage(threadID, messageID, stepID, status string, text *string, emit func(string, interface{})) {
+	threadsMu.Lock()
+	var message ThreadMessage
+	for i := range ThreadMessages {
+		if ThreadMessages[i].ID == messageID {
+			ThreadMessages[i].Status = status
+			if text != nil {
+				ThreadMessages[i].Content = []MessageContent{{Type: "text", Text: &MessageContentText{Value: *text, Annotations: []interface{}{}}}}
+			}
+			message = ThreadMessages[i]
+		}
+	}
+	var step RunStep
+	if s := findRunStep(stepID); s != nil {
+		s.CompletedAt = time.Now().Unix()
+		s.Status = RunStatusCompleted
+		if status != "completed" {
+			s.Status = RunStatusFailed
+		}
+		step = *s
+	}
+	saveThreads(e.appConfig)
+	saveRuns(e.appConfig)
+	threadsMu.Unlock()
+
+	if status == "completed" {
+		emit("thread.message.completed", message)
+		emit("thread.run.step.completed", step)
+	} else {
+		emit("thread.message.incomplete", message)
+		emit("thread.run.step.failed", step)
+	}
+}
+
+// requireAction records the function calls produced by the model and pauses the run until their outputs are submitted
+func (e *runExecutor) requireAction(threadID, runID string, results []functions.FuncCallResults, usage *schema.OpenAIUsage, emit func(string, interface{})) {
+	toolCalls := []RunToolCall{}
+	for _, r := range results {
+		toolCalls = append(toolCalls, RunToolCall{
+			ID:   newObjectID("call"),
+			Type: string(Function),
+			Function: RunToolCallFunction{
+				Name:      r.Name,
+				Arguments: r.Arguments,
+			},
+		})
+	}
+
+	threadsMu.Lock()
+	run := findRun(threadID, runID)
+	if run == nil {
+		threadsMu.Unlock()
+		return
+	}
+	step := RunStep{
+		ID:          newObjectID("step"),
+		Object:      "thread.run.step",
+		CreatedAt:   time.Now().Unix(),
+		AssistantID: run.AssistantID,
+		ThreadID:    threadID,
+		RunID:       runID,
+		Type:        "tool_calls",
+		Status:      RunStatusInProgress,
+		StepDetails: RunStepDetails{
+			Type:      "tool_calls",
+			ToolCalls: toolCalls,
+		},
+		Usage: usage,
+	}
+	RunSteps = append(RunSteps, step)
+
+	run.Status = RunStatusRequiresAction
+	run.RequiredAction = &RequiredAction{
+		Type:              "submit_tool_outputs",
+		SubmitToolOutputs: SubmitToolOutputsAction{ToolCalls: toolCalls},
+	}
+	current := *run
+	saveRuns(e.appConfig)
+	threadsMu.Unlock()
+
+	emit("thread.run.step.created", step)
+	emit("thread.run.requires_action", current)
+}
+
+// sendRunEvents streams the events of a run as server-sent events, in the format used by the OpenAI Assistants API
+func sendRunEvents(c *fiber.Ctx, events chan runEvent) error {
+	c.Context().SetContentType("text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Transfer-Encoding", "chunked")
+
+	c.Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		disconnected := false
+		for ev := range events {
+			// Keep draining the events if the client went away, so the run can complete
+			if disconnected {
+				continue
+			}
+			data, err := json.Marshal(ev.Data)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to marshal run event")
+				continue
+			}
+			log.Debug().Msgf("Sending run event %s: %s", ev.Event, data)
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Event, data); err != nil {
+				disconnected = true
+				continue
+			}
+			if err := w.Flush(); err != nil {
+				disconnected = true
+			}
+		}
+		if !disconnected {
+			w.WriteString("event: done\ndata: [DONE]\n\n")
+			w.Flush()
+		}
+	}))
+	return nil
+}
+
+// CreateRunEndpoint is the OpenAI Assistant API endpoint to run an assistant on a thread https://platform.openai.com/docs/api-reference/runs/createRun
+// @Summary Create a run.
+// @Param request body RunRequest true "query params"
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/{thread_id}/runs [post]
+func CreateRunEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	executor := newRunExecutor(cl, ml, evaluator, appConfig)
+
+	return func(c *fiber.Ctx) error {
+		request := new(RunRequest)
+		if err := c.BodyParser(request); err != nil {
+			log.Warn().AnErr("Unable to parse RunRequest", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadID := c.Params("thread_id")
+		if threadID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id is required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		if findThread(threadID) == -1 {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find thread with id: %s", threadID)))
+		}
+
+		run, err := newRun(threadID, *request)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+		saveThreads(appConfig)
+		saveRuns(appConfig)
+
+		events := executor.start(run, request.Stream)
+		if request.Stream {
+			return sendRunEvents(c, withCreatedEvent(*run, events))
+		}
+		return c.Status(fiber.StatusOK).JSON(run)
+	}
+}
+
+// CreateThreadAndRunEndpoint is the OpenAI Assistant API endpoint to create a thread and run it in one request https://platform.openai.com/docs/api-reference/runs/createThreadAndRun
+// @Summary Create a thread and run it.
+// @Param request body CreateThreadAndRunRequest true "query params"
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/runs [post]
+func CreateThreadAndRunEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	executor := newRunExecutor(cl, ml, evaluator, appConfig)
+
+	return func(c *fiber.Ctx) error {
+		request := new(CreateThreadAndRunRequest)
+		if err := c.BodyParser(request); err != nil {
+			log.Warn().AnErr("Unable to parse CreateThreadAndRunRequest", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		if findAssistant(request.AssistantID) == nil {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find assistant with id: %s", request.AssistantID)))
+		}
+
+		thread, err := createThread(request.Thread)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+
+		run, err := newRun(thread.ID, request.RunRequest)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+		saveThreads(appConfig)
+		saveRuns(appConfig)
+
+		events := executor.start(run, request.Stream)
+		if request.Stream {
+			return sendRunEvents(c, withCreatedEvent(*run, events))
+		}
+		return c.Status(fiber.StatusOK).JSON(run)
+	}
+}
+
+// withCreatedEvent prepends the thread.run.created event to the events of a new run
+func withCreatedEvent(run Run, events chan runEvent) chan runEvent {
+	out := make(chan runEvent)
+	go func() {
+		out <- runEvent{Event: "thread.run.created", Data: run}
+		for ev := range events {
+			out <- ev
+		}
+		close(out)
+	}()
+	return out
+}
+
+// ListRunsEndpoint is the OpenAI Assistant API endpoint to list the runs of a thread https://platform.openai.com/docs/api-reference/runs/listRuns
+// @Summary List the runs of a thread
+// @Param limit query int false "Limit the number of runs returned"
+// @Param order query string false "Order of runs returned"
+// @Param after query string false "Return runs after the given ID"
+// @Param before query string false "Return runs before the given ID"
+// @Success 200 {object} ListResponse "Response"
+// @Router /v1/threads/{thread_id}/runs [get]
+func ListRunsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		if threadID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id is required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		if findThread(threadID) == -1 {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find thread with id: %s", threadID)))
+		}
+
+		runs := []Run{}
+		for _, r := range Runs {
+			if r.ThreadID == threadID {
+				runs = append(runs, r)
+			}
+		}
+
+		response, err := paginate(c, runs,
+			func(r Run) string { return r.ID },
+			func(r Run) int64 { return r.CreatedAt },
+		)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// GetRunEndpoint is the OpenAI Assistant API endpoint to get a run https://platform.openai.com/docs/api-reference/runs/getRun
+// @Summary Get a run
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/{thread_id}/runs/{run_id} [get]
+func GetRunEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		runID := c.Params("run_id")
+		if threadID == "" || runID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id and run_id are required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		run := findRun(threadID, runID)
+		if run == nil {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find run %s in thread %s", runID, threadID)))
+		}
+		return c.Status(fiber.StatusOK).JSON(run)
+	}
+}
+
+// CancelRunEndpoint is the OpenAI Assistant API endpoint to cancel a run https://platform.openai.com/docs/api-reference/runs/cancelRun
+// @Summary Cancel a run that is in progress
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/{thread_id}/runs/{run_id}/cancel [post]
+func CancelRunEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		runID := c.Params("run_id")
+		if threadID == "" || runID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id and run_id are required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		run := findRun(threadID, runID)
+		if run == nil {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find run %s in thread %s", runID, threadID)))
+		}
+
+		switch run.Status {
+		case RunStatusQueued, RunStatusInProgress:
+			// The executor marks the run as cancelled once the inference stops
+			run.Status = RunStatusCancelling
+			cancelRun(run.ID)
+		case RunStatusRequiresAction:
+			run.Status = RunStatusCancelled
+			run.CancelledAt = time.Now().Unix()
+			run.RequiredAction = nil
+		default:
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Cannot cancel run with status: %s", run.Status)))
+		}
+
+		saveRuns(appConfig)
+		return c.Status(fiber.StatusOK).JSON(run)
+	}
+}
+
+// SubmitToolOutputsEndpoint is the OpenAI Assistant API endpoint to resume a run with the results of its tool calls https://platform.openai.com/docs/api-reference/runs/submitToolOutputs
+// @Summary Submit the outputs of the tool calls of a run
+// @Param request body SubmitToolOutputsRequest true "query params"
+// @Success 200 {object} Run "Response"
+// @Router /v1/threads/{thread_id}/runs/{run_id}/submit_tool_outputs [post]
+func SubmitToolOutputsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, evaluator *templates.Evaluator, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	executor := newRunExecutor(cl, ml, evaluator, appConfig)
+
+	return func(c *fiber.Ctx) error {
+		request := new(SubmitToolOutputsRequest)
+		if err := c.BodyParser(request); err != nil {
+			log.Warn().AnErr("Unable to parse SubmitToolOutputsRequest", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadID := c.Params("thread_id")
+		runID := c.Params("run_id")
+		if threadID == "" || runID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id and run_id are required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		run := findRun(threadID, runID)
+		if run == nil {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find run %s in thread %s", runID, threadID)))
+		}
+		if run.Status != RunStatusRequiresAction || run.RequiredAction == nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Run %s is not waiting for tool outputs", runID)))
+		}
+
+		outputs := map[string]string{}
+		for _, o := range request.ToolOutputs {
+			outputs[o.ToolCallID] = o.Output
+		}
+
+		var step *RunStep
+		for i := range RunSteps {
+			if RunSteps[i].RunID == runID && RunSteps[i].Type == "tool_calls" && RunSteps[i].Status == RunStatusInProgress {
+				step = &RunSteps[i]
+			}
+		}
+		if step == nil {
+			return c.Status(fiber.StatusInternalServerError).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find the tool calls of run %s", runID)))
+		}
+
+		for _, tc := range step.StepDetails.ToolCalls {
+			if _, ok := outputs[tc.ID]; !ok {
+				return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Missing output for tool call %s", tc.ID)))
+			}
+		}
+
+		for i, tc := range step.StepDetails.ToolCalls {
+			output := outputs[tc.ID]
+			step.StepDetails.ToolCalls[i].Function.Output = &output
+		}
+		step.Status = RunStatusCompleted
+		step.CompletedAt = time.Now().Unix()
+
+		run.Status = RunStatusQueued
+		run.RequiredAction = nil
+		saveRuns(appConfig)
+
+		events := executor.start(run, request.Stream)
+		if request.Stream {
+			return sendRunEvents(c, events)
+		}
+		return c.Status(fiber.StatusOK).JSON(run)
+	}
+}
+
+// ListRunStepsEndpoint is the OpenAI Assistant API endpoint to list the steps of a run https://platform.openai.com/docs/api-reference/run-steps/listRunSteps
+// @Summary List the steps of a run
+// @Param limit query int false "Limit the number of steps returned"
+// @Param order query string false "Order of steps returned"
+// @Param after query string false "Return steps after the given ID"
+// @Param before query string false "Return steps before the given ID"
+// @Success 200 {object} ListResponse "Response"
+// @Router /v1/threads/{thread_id}/runs/{run_id}/steps [get]
+func ListRunStepsEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		runID := c.Params("run_id")
+		if threadID == "" || runID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id and run_id are required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		if findRun(threadID, runID) == nil {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find run %s in thread %s", runID, threadID)))
+		}
+
+		steps := []RunStep{}
+		for _, s := range RunSteps {
+			if s.RunID == runID {
+				steps = append(steps, s)
+			}
+		}
+
+		response, err := paginate(c, steps,
+			func(s RunStep) string { return s.ID },
+			func(s RunStep) int64 { return s.CreatedAt },
+		)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// GetRunStepEndpoint is the OpenAI Assistant API endpoint to get a run step https://platform.openai.com/docs/api-reference/run-steps/getRunStep
+// @Summary Get a step of a run
+// @Success 200 {object} RunStep "Response"
+// @Router /v1/threads/{thread_id}/runs/{run_id}/steps/{step_id} [get]
+func GetRunStepEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		runID := c.Params("run_id")
+		stepID := c.Params("step_id")
+		if threadID == "" || runID == "" || stepID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id, run_id and step_id are required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		step := findRunStep(stepID)
+		if step == nil || step.RunID != runID || step.ThreadID != threadID {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find step %s in run %s", stepID, runID)))
+		}
+		return c.Status(fiber.StatusOK).JSON(step)
+	}
+}
@@ -0,0 +1,154 @@
+package openai
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/functions"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInvalidStructuredOutput is returned when an output doesn't match the format requested with the response_format
+var ErrInvalidStructuredOutput = errors.New("the output does not match the requested format")
+
+// invalidOutputErrorType is the type of the errors reporting an output which doesn't match the requested format
+const invalidOutputErrorType = "invalid_structured_output"
+
+// validateStructuredOutputs checks the content of the choices against the JSON schema requested with the response_format.
+// Invalid outputs are generated again as many times as configured by the model, the request fails if they are still
+// invalid. Outputs truncated by the token limit are returned as they are, with the "length" finish reason.
+// The invalid outputs of chat requests can be repaired, by sending them back to the model with the validation error.
+func validateStructuredOutputs(
+	input *schema.OpenAIRequest,
+	predInput string,
+	outputSchema interface{},
+	result []schema.Choice,
+	tokenUsage backend.TokenUsage,
+	config *config.BackendConfig,
+	o *config.ApplicationConfig,
+	loader *model.ModelLoader,
+	evaluator *templates.Evaluator,
+	cb func(string, *[]schema.Choice)) ([]schema.Choice, backend.TokenUsage, error) {
+
+	for i := range result {
+		for attempt := 0; ; attempt++ {
+			if result[i].FinishReason == "length" {
+				break
+			}
+			content := choiceContent(result[i])
+			err := functions.ValidateJSON(content, outputSchema)
+			if err == nil {
+				break
+			}
+			if attempt >= config.StructuredOutput.Retries {
+				return nil, tokenUsage, fmt.Errorf("%w: %w", ErrInvalidStructuredOutput, err)
+			}
+			log.Debug().Err(err).Msgf("Invalid structured output, retrying (%d/%d)", attempt+1, config.StructuredOutput.Retries)
+
+			retryInput, retryPredInput := *input, predInput
+			retryInput.N = 1
+			if config.StructuredOutput.Repair && len(input.Messages) > 0 {
+				retryInput.Messages = repairMessages(input.Messages, content, err)
+				if !config.TemplateConfig.UseTokenizerTemplate {
+					retryPredInput = evaluator.TemplateMessages(retryInput.Messages, config, nil, false)
+				}
+			}
+
+			retried, usage, cErr := ComputeChoices(&retryInput, retryPredInput, config, o, loader, cb, nil)
+			if cErr != nil {
+				return nil, tokenUsage, cErr
+			}
+			tokenUsage.Prompt += usage.Prompt
+			tokenUsage.Completion += usage.Completion
+			tokenUsage.TimingPromptProcessing += usage.TimingPromptProcessing
+			tokenUsage.TimingTokenGeneration += usage.TimingTokenGeneration
+			if len(retried) == 0 {
+				return nil, tokenUsage, fmt.Errorf("%w: no output generated", ErrInvalidStructuredOutput)
+			}
+
+			retried[0].Index = result[i].Index
+			result[i] = retried[0]
+		}
+	}
+	return result, tokenUsage, nil
+}
+
+// validateStreamedOutput checks an output streamed to the client against the JSON schema requested with the
+// response_format. It can't be generated again once streamed, so the stream ends with an error if it is invalid.
+// Outputs truncated by the token limit are not checked, like the ones which are not streamed.
+func validateStreamedOutput(output string, usage backend.TokenUsage, outputSchema interface{}, config *config.BackendConfig) error {
+	if config.Maxtokens != nil && *config.Maxtokens > 0 && usage.Completion >= *config.Maxtokens {
+		return nil
+	}
+	if err := functions.ValidateJSON(output, outputSchema); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidStructuredOutput, err)
+	}
+	return nil
+}
+
+// errorResponse returns the body of an error, reporting the outputs which don't match the requested format
+// with their own type and status
+func errorResponse(err error) schema.ErrorResponse {
+	if errors.Is(err, ErrInvalidStructuredOutput) {
+		return schema.ErrorResponse{Error: &schema.APIError{Message: err.Error(), Code: fiber.StatusUnprocessableEntity, Type: invalidOutputErrorType}}
+	}
+	return schema.ErrorResponse{Error: &schema.APIError{Message: err.Error(), Code: fiberContext.ErrorStatus(err)}}
+}
+
+// sendStreamError ends a stream with an error event
+func sendStreamError(w *bufio.Writer, err error) {
+	errData, _ := json.Marshal(errorResponse(err))
+	w.WriteString(fmt.Sprintf("data: %s\n\n", errData))
+	w.WriteString("data: [DONE]\n\n")
+	w.Flush()
+}
+
+// sendInvalidOutput replies to a request whose output doesn't match the requested format
+func sendInvalidOutput(c *fiber.Ctx, err error) error {
+	if sendErr := c.Status(fiber.StatusUnprocessableEntity).JSON(errorResponse(err)); sendErr != nil {
+		return sendErr
+	}
+	return &fiberContext.ResponseError{Err: err}
+}
+
+// repairMessages adds the invalid output to the conversation, followed by a message asking the model to fix it
+func repairMessages(messages []schema.Message, output string, validationErr error) []schema.Message {
+	repair := fmt.Sprintf("The previous answer is not valid: %s. Reply again only with the corrected JSON.", validationErr.Error())
+	return append(append([]schema.Message{}, messages...),
+		schema.Message{Role: "assistant", Content: output, StringContent: output},
+		schema.Message{Role: "user", Content: repair, StringContent: repair},
+	)
+}
+
+// responseFormatSchema returns the JSON schema of a json_schema response_format
+func responseFormatSchema(responseFormat map[string]interface{}) interface{} {
+	jsonSchema, ok := responseFormat["json_schema"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return jsonSchema["schema"]
+}
+
+func choiceContent(choice schema.Choice) string {
+	if choice.Message == nil {
+		return choice.Text
+	}
+	switch content := choice.Message.Content.(type) {
+	case string:
+		return content
+	case *string:
+		if content != nil {
+			return *content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package openai
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStructuredOutputs(t *testing.T) {
+	outputSchema := responseFormatSchema(map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   "person",
+			"strict": true,
+			"schema": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}},
+				"required":   []interface{}{"name"},
+			},
+		},
+	})
+	assert.NotNil(t, outputSchema)
+
+	choice := func(content, finishReason string) schema.Choice {
+		return schema.Choice{FinishReason: finishReason, Message: &schema.Message{Role: "assistant", Content: &content}}
+	}
+	validate := func(choices ...schema.Choice) error {
+		_, _, err := validateStructuredOutputs(&schema.OpenAIRequest{}, "", outputSchema, choices, backend.TokenUsage{}, &config.BackendConfig{}, &config.ApplicationConfig{}, nil, nil, nil)
+		return err
+	}
+
+	assert.NoError(t, validate(choice(`{"name": "John"}`, "stop")))
+	// truncated outputs are reported with the finish reason
+	assert.NoError(t, validate(choice(`{"name": "Jo`, "length")))
+	assert.Error(t, validate(choice(`{"name": "John"}`, "stop"), choice(`{"age": 30}`, "stop")))
+	assert.Error(t, validate(choice(`not JSON`, "stop")))
+}
+
+func TestRepairMessages(t *testing.T) {
+	messages := []schema.Message{{Role: "user", Content: "Who are you?", StringContent: "Who are you?"}}
+	repaired := repairMessages(messages, `{"name": 1}`, errors.New("name: invalid type"))
+
+	assert.Len(t, messages, 1)
+	assert.Len(t, repaired, 3)
+	assert.Equal(t, "assistant", repaired[1].Role)
+	assert.Equal(t, `{"name": 1}`, repaired[1].StringContent)
+	assert.Equal(t, "user", repaired[2].Role)
+	assert.Contains(t, repaired[2].StringContent, "name: invalid type")
+}
+
+func TestValidateStreamedOutput(t *testing.T) {
+	outputSchema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"name"},
+	}
+	maxTokens := 10
+	cfg := &config.BackendConfig{}
+	cfg.Maxtokens = &maxTokens
+
+	assert.NoError(t, validateStreamedOutput(`{"name": "John"}`, backend.TokenUsage{Completion: 5}, outputSchema, &config.BackendConfig{}))
+	err := validateStreamedOutput(`{"age": 30}`, backend.TokenUsage{Completion: 5}, outputSchema, &config.BackendConfig{})
+	assert.ErrorIs(t, err, ErrInvalidStructuredOutput)
+	// truncated outputs are not checked
+	assert.NoError(t, validateStreamedOutput(`{"name": "Jo`, backend.TokenUsage{Completion: 10}, outputSchema, cfg))
+}
+
+func TestErrorResponse(t *testing.T) {
+	resp := errorResponse(fmt.Errorf("%w: name is required", ErrInvalidStructuredOutput))
+	assert.Equal(t, fiber.StatusUnprocessableEntity, resp.Error.Code)
+	assert.Equal(t, invalidOutputErrorType, resp.Error.Type)
+
+	resp = errorResponse(errors.New("backend error"))
+	assert.Equal(t, fiber.StatusInternalServerError, resp.Error.Code)
+	assert.Empty(t, resp.Error.Type)
+}
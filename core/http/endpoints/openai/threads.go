@@ -0,0 +1,580 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	model "github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// Thread represents the structure of a thread object from the OpenAI API.
+type Thread struct {
+	ID        string            `json:"id"`                 // The unique identifier of the thread.
+	Object    string            `json:"object"`             // Object type, which is "thread".
+	CreatedAt int64             `json:"created_at"`         // The time at which the thread was created.
+	Metadata  map[string]string `json:"metadata,omitempty"` // Set of key-value pairs attached to the thread.
+}
+
+type MessageContentText struct {
+	Value       string        `json:"value"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+type MessageContentImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// MessageContent is a single content part of a thread message (text or image_url).
+type MessageContent struct {
+	Type     string                  `json:"type"`
+	Text     *MessageContentText     `json:"text,omitempty"`
+	ImageURL *MessageContentImageURL `json:"image_url,omitempty"`
+}
+
+// ThreadMessage represents the structure of a message object from the OpenAI API.
+type ThreadMessage struct {
+	ID          string            `json:"id"`                     // The unique identifier of the message.
+	Object      string            `json:"object"`                 // Object type, which is "thread.message".
+	CreatedAt   int64             `json:"created_at"`             // The time at which the message was created.
+	ThreadID    string            `json:"thread_id"`              // The thread the message belongs to.
+	Status      string            `json:"status"`                 // The status of the message: in_progress, incomplete or completed.
+	Role        string            `json:"role"`                   // The entity that produced the message: user or assistant.
+	Content     []MessageContent  `json:"content"`                // The content of the message.
+	AssistantID string            `json:"assistant_id,omitempty"` // The assistant that authored the message, if any.
+	RunID       string            `json:"run_id,omitempty"`       // The run that produced the message, if any.
+	Metadata    map[string]string `json:"metadata,omitempty"`     // Set of key-value pairs attached to the message.
+}
+
+type ThreadMessageRequest struct {
+	Role     string            `json:"role"`
+	Content  interface{}       `json:"content"` // either a string or an array of content parts
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type ThreadRequest struct {
+	Messages []ThreadMessageRequest `json:"messages,omitempty"`
+	Metadata map[string]string      `json:"metadata,omitempty"`
+}
+
+// ListResponse is the cursor-paginated list object returned by the thread, message and run listing endpoints.
+type ListResponse struct {
+	Object  string      `json:"object"`
+	Data    interface{} `json:"data"`
+	FirstID string      `json:"first_id"`
+	LastID  string      `json:"last_id"`
+	HasMore bool        `json:"has_more"`
+}
+
+var (
+	Threads                  = []Thread{}
+	ThreadsConfigFile        = "threads.json"
+	ThreadMessages           = []ThreadMessage{}
+	ThreadMessagesConfigFile = "threadMessages.json"
+
+	// threadsMu guards Threads, ThreadMessages, Runs and RunSteps, which are also updated by runs executing in the background
+	threadsMu sync.Mutex
+)
+
+// newObjectID returns a unique ID for the objects persisted across restarts, e.g. thread_1f0c...
+func newObjectID(prefix string) string {
+	return prefix + "_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// toMessageContent converts the content of a message request, either a plain string or a list of content parts, to message contents
+func toMessageContent(content interface{}) ([]MessageContent, error) {
+	switch c := content.(type) {
+	case string:
+		return []MessageContent{{Type: "text", Text: &MessageContentText{Value: c, Annotations: []interface{}{}}}}, nil
+	case []interface{}:
+		dat, _ := json.Marshal(c)
+		parts := []schema.Content{}
+		if err := json.Unmarshal(dat, &parts); err != nil {
+			return nil, err
+		}
+		result := []MessageContent{}
+		for _, p := range parts {
+			switch p.Type {
+			case "text":
+				result = append(result, MessageContent{Type: "text", Text: &MessageContentText{Value: p.Text, Annotations: []interface{}{}}})
+			case "image_url":
+				result = append(result, MessageContent{Type: "image_url", ImageURL: &MessageContentImageURL{URL: p.ImageURL.URL}})
+			default:
+				return nil, fmt.Errorf("unsupported content type %q", p.Type)
+			}
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unsupported content %T", content)
+}
+
+// toSchemaMessage converts a thread message to a chat message which can be templated and sent to the backend
+func (m ThreadMessage) toSchemaMessage() schema.Message {
+	hasImages := false
+	text := ""
+	for _, c := range m.Content {
+		switch {
+		case c.Text != nil:
+			text += c.Text.Value
+		case c.ImageURL != nil:
+			hasImages = true
+		}
+	}
+
+	if !hasImages {
+		return schema.Message{Role: m.Role, Content: text}
+	}
+
+	// Keep the multimodal parts, they are decoded later on like a chat request
+	parts := []interface{}{}
+	for _, c := range m.Content {
+		switch {
+		case c.Text != nil:
+			parts = append(parts, map[string]interface{}{"type": "text", "text": c.Text.Value})
+		case c.ImageURL != nil:
+			parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": c.ImageURL.URL}})
+		}
+	}
+	return schema.Message{Role: m.Role, Content: parts}
+}
+
+// newThreadMessage validates a message request and appends it to the thread. It must be called with threadsMu held.
+func newThreadMessage(threadID string, request ThreadMessageRequest) (*ThreadMessage, error) {
+	if request.Role != "user" && request.Role != "assistant" {
+		return nil, fmt.Errorf("invalid role %q, must be either user or assistant", request.Role)
+	}
+
+	content, err := toMessageContent(request.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Metadata == nil {
+		request.Metadata = make(map[string]string)
+	}
+
+	message := ThreadMessage{
+		ID:        newObjectID("msg"),
+		Object:    "thread.message",
+		CreatedAt: time.Now().Unix(),
+		ThreadID:  threadID,
+		Status:    "completed",
+		Role:      request.Role,
+		Content:   content,
+		Metadata:  request.Metadata,
+	}
+	ThreadMessages = append(ThreadMessages, message)
+	return &message, nil
+}
+
+// findThread returns the index of the thread with the given ID, or -1. It must be called with threadsMu held.
+func findThread(threadID string) int {
+	for i, thread := range Threads {
+		if thread.ID == threadID {
+			return i
+		}
+	}
+	return -1
+}
+
+// threadMessagesFor returns the messages of a thread in creation order. It must be called with threadsMu held.
+func threadMessagesFor(threadID string) []ThreadMessage {
+	messages := []ThreadMessage{}
+	for _, m := range ThreadMessages {
+		if m.ThreadID == threadID {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+// createThread creates a new thread with the given initial messages. It must be called with threadsMu held.
+func createThread(request ThreadRequest) (*Thread, error) {
+	if request.Metadata == nil {
+		request.Metadata = make(map[string]string)
+	}
+
+	thread := Thread{
+		ID:        newObjectID("thread"),
+		Object:    "thread",
+		CreatedAt: time.Now().Unix(),
+		Metadata:  request.Metadata,
+	}
+
+	messages := ThreadMessages
+	for _, m := range request.Messages {
+		if _, err := newThreadMessage(thread.ID, m); err != nil {
+			// Rollback the messages created so far
+			ThreadMessages = messages
+			return nil, err
+		}
+	}
+
+	Threads = append(Threads, thread)
+	return &thread, nil
+}
+
+func saveThreads(appConfig *config.ApplicationConfig) {
+	utils.SaveConfig(appConfig.ConfigsDir, ThreadsConfigFile, Threads)
+	utils.SaveConfig(appConfig.ConfigsDir, ThreadMessagesConfigFile, ThreadMessages)
+}
+
+// paginate sorts a list of objects, in insertion order, by creation time and applies the limit/order/after/before query parameters
+func paginate[T any](c *fiber.Ctx, items []T, id func(T) string, created func(T) int64) (*ListResponse, error) {
+	limitQuery := c.Query("limit", "20")
+	limit, err := strconv.Atoi(limitQuery)
+	if err != nil || limit < 1 || limit > 100 {
+		return nil, fmt.Errorf("invalid limit query value: %s", limitQuery)
+	}
+	order := c.Query("order", "desc")
+	after := c.Query("after")
+	before := c.Query("before")
+
+	// The items are in insertion order: the ones created in the same second keep it, reversed for the desc order
+	if order != "asc" {
+		slices.Reverse(items)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if order == "asc" {
+			return created(items[i]) < created(items[j])
+		}
+		return created(items[i]) > created(items[j])
+	})
+
+	// After and before cursors refer to the position of the object in the sorted list
+	if after != "" {
+		for i, item := range items {
+			if id(item) == after {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+	if before != "" {
+		for i, item := range items {
+			if id(item) == before {
+				items = items[:i]
+				break
+			}
+		}
+	}
+
+	hasMore := false
+	if len(items) > limit {
+		hasMore = true
+		items = items[:limit]
+	}
+
+	response := &ListResponse{
+		Object:  "list",
+		Data:    items,
+		HasMore: hasMore,
+	}
+	if len(items) > 0 {
+		response.FirstID = id(items[0])
+		response.LastID = id(items[len(items)-1])
+	}
+	return response, nil
+}
+
+// CreateThreadEndpoint is the OpenAI Assistant API endpoint to create threads https://platform.openai.com/docs/api-reference/threads/createThread
+// @Summary Create a thread.
+// @Param request body ThreadRequest true "query params"
+// @Success 200 {object} Thread "Response"
+// @Router /v1/threads [post]
+func CreateThreadEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ThreadRequest)
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(request); err != nil {
+				log.Warn().AnErr("Unable to parse ThreadRequest", err)
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+			}
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		thread, err := createThread(*request)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+
+		saveThreads(appConfig)
+		return c.Status(fiber.StatusOK).JSON(thread)
+	}
+}
+
+// GetThreadEndpoint is the OpenAI Assistant API endpoint to get threads https://platform.openai.com/docs/api-reference/threads/getThread
+// @Summary Get thread data
+// @Success 200 {object} Thread "Response"
+// @Router /v1/threads/{thread_id} [get]
+func GetThreadEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		if threadID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id is required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		i := findThread(threadID)
+		if i == -1 {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find thread with id: %s", threadID)))
+		}
+		return c.Status(fiber.StatusOK).JSON(Threads[i])
+	}
+}
+
+// ModifyThreadEndpoint is the OpenAI Assistant API endpoint to modify threads https://platform.openai.com/docs/api-reference/threads/modifyThread
+// @Summary Modify thread metadata
+// @Param request body ThreadRequest true "query params"
+// @Success 200 {object} Thread "Response"
+// @Router /v1/threads/{thread_id} [post]
+func ModifyThreadEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ThreadRequest)
+		if err := c.BodyParser(request); err != nil {
+			log.Warn().AnErr("Unable to parse ThreadRequest", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadID := c.Params("thread_id")
+		if threadID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id is required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		i := findThread(threadID)
+		if i == -1 {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find thread with id: %s", threadID)))
+		}
+
+		if request.Metadata != nil {
+			Threads[i].Metadata = request.Metadata
+		}
+
+		saveThreads(appConfig)
+		return c.Status(fiber.StatusOK).JSON(Threads[i])
+	}
+}
+
+// DeleteThreadEndpoint is the OpenAI Assistant API endpoint to delete threads https://platform.openai.com/docs/api-reference/threads/deleteThread
+// @Summary Delete a thread, its messages and runs
+// @Success 200 {object} schema.DeleteAssistantResponse "Response"
+// @Router /v1/threads/{thread_id} [delete]
+func DeleteThreadEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		if threadID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id is required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		i := findThread(threadID)
+		if i == -1 {
+			log.Warn().Msgf("Unable to find thread %s for deletion", threadID)
+			return c.Status(fiber.StatusNotFound).JSON(schema.DeleteAssistantResponse{
+				ID:      threadID,
+				Object:  "thread.deleted",
+				Deleted: false,
+			})
+		}
+
+		Threads = append(Threads[:i], Threads[i+1:]...)
+
+		messages := []ThreadMessage{}
+		for _, m := range ThreadMessages {
+			if m.ThreadID != threadID {
+				messages = append(messages, m)
+			}
+		}
+		ThreadMessages = messages
+
+		runs := []Run{}
+		for _, r := range Runs {
+			if r.ThreadID != threadID {
+				runs = append(runs, r)
+			} else {
+				cancelRun(r.ID)
+			}
+		}
+		Runs = runs
+
+		steps := []RunStep{}
+		for _, s := range RunSteps {
+			if s.ThreadID != threadID {
+				steps = append(steps, s)
+			}
+		}
+		RunSteps = steps
+
+		saveThreads(appConfig)
+		saveRuns(appConfig)
+		return c.Status(fiber.StatusOK).JSON(schema.DeleteAssistantResponse{
+			ID:      threadID,
+			Object:  "thread.deleted",
+			Deleted: true,
+		})
+	}
+}
+
+// CreateThreadMessageEndpoint is the OpenAI Assistant API endpoint to add messages to a thread https://platform.openai.com/docs/api-reference/messages/createMessage
+// @Summary Create a message in a thread.
+// @Param request body ThreadMessageRequest true "query params"
+// @Success 200 {object} ThreadMessage "Response"
+// @Router /v1/threads/{thread_id}/messages [post]
+func CreateThreadMessageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ThreadMessageRequest)
+		if err := c.BodyParser(request); err != nil {
+			log.Warn().AnErr("Unable to parse ThreadMessageRequest", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadID := c.Params("thread_id")
+		if threadID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id is required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		if findThread(threadID) == -1 {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find thread with id: %s", threadID)))
+		}
+
+		if activeRun(threadID) != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Can't add messages to %s while a run is active.", threadID)))
+		}
+
+		message, err := newThreadMessage(threadID, *request)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+
+		saveThreads(appConfig)
+		return c.Status(fiber.StatusOK).JSON(message)
+	}
+}
+
+// ListThreadMessagesEndpoint is the OpenAI Assistant API endpoint to list the messages of a thread https://platform.openai.com/docs/api-reference/messages/listMessages
+// @Summary List the messages of a thread
+// @Param limit query int false "Limit the number of messages returned"
+// @Param order query string false "Order of messages returned"
+// @Param after query string false "Return messages after the given ID"
+// @Param before query string false "Return messages before the given ID"
+// @Param run_id query string false "Only return messages generated by the given run"
+// @Success 200 {object} ListResponse "Response"
+// @Router /v1/threads/{thread_id}/messages [get]
+func ListThreadMessagesEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		if threadID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id is required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		if findThread(threadID) == -1 {
+			return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find thread with id: %s", threadID)))
+		}
+
+		messages := threadMessagesFor(threadID)
+		if runID := c.Query("run_id"); runID != "" {
+			filtered := []ThreadMessage{}
+			for _, m := range messages {
+				if m.RunID == runID {
+					filtered = append(filtered, m)
+				}
+			}
+			messages = filtered
+		}
+
+		response, err := paginate(c, messages,
+			func(m ThreadMessage) string { return m.ID },
+			func(m ThreadMessage) int64 { return m.CreatedAt },
+		)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).SendString(bluemonday.StrictPolicy().Sanitize(err.Error()))
+		}
+		return c.Status(fiber.StatusOK).JSON(response)
+	}
+}
+
+// GetThreadMessageEndpoint is the OpenAI Assistant API endpoint to get a message https://platform.openai.com/docs/api-reference/messages/getMessage
+// @Summary Get a message of a thread
+// @Success 200 {object} ThreadMessage "Response"
+// @Router /v1/threads/{thread_id}/messages/{message_id} [get]
+func GetThreadMessageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		threadID := c.Params("thread_id")
+		messageID := c.Params("message_id")
+		if threadID == "" || messageID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id and message_id are required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		for _, m := range ThreadMessages {
+			if m.ThreadID == threadID && m.ID == messageID {
+				return c.Status(fiber.StatusOK).JSON(m)
+			}
+		}
+		return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find message %s in thread %s", messageID, threadID)))
+	}
+}
+
+// ModifyThreadMessageEndpoint is the OpenAI Assistant API endpoint to modify a message https://platform.openai.com/docs/api-reference/messages/modifyMessage
+// @Summary Modify the metadata of a message
+// @Success 200 {object} ThreadMessage "Response"
+// @Router /v1/threads/{thread_id}/messages/{message_id} [post]
+func ModifyThreadMessageEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		request := new(ThreadMessageRequest)
+		if err := c.BodyParser(request); err != nil {
+			log.Warn().AnErr("Unable to parse ThreadMessageRequest", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot parse JSON"})
+		}
+
+		threadID := c.Params("thread_id")
+		messageID := c.Params("message_id")
+		if threadID == "" || messageID == "" {
+			return c.Status(fiber.StatusBadRequest).SendString("parameter thread_id and message_id are required")
+		}
+
+		threadsMu.Lock()
+		defer threadsMu.Unlock()
+
+		for i, m := range ThreadMessages {
+			if m.ThreadID == threadID && m.ID == messageID {
+				if request.Metadata != nil {
+					ThreadMessages[i].Metadata = request.Metadata
+				}
+				saveThreads(appConfig)
+				return c.Status(fiber.StatusOK).JSON(ThreadMessages[i])
+			}
+		}
+		return c.Status(fiber.StatusNotFound).SendString(bluemonday.StrictPolicy().Sanitize(fmt.Sprintf("Unable to find message %s in thread %s", messageID, threadID)))
+	}
+}
@@ -0,0 +1,268 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func tearDownThreads() func() {
+	return func() {
+		Threads = []Thread{}
+		ThreadMessages = []ThreadMessage{}
+		Runs = []Run{}
+		RunSteps = []RunStep{}
+		Assistants = []Assistant{}
+	}
+}
+
+func TestThreadEndpoints(t *testing.T) {
+	cl := &config.BackendConfigLoader{}
+	modelPath := "/tmp/localai/model"
+	ml := model.NewModelLoader(modelPath)
+	evaluator := templates.NewEvaluator(modelPath)
+
+	appConfig := &config.ApplicationConfig{
+		ConfigsDir: configsDir,
+		ModelPath:  modelPath,
+	}
+	_ = os.MkdirAll(appConfig.ConfigsDir, 0750)
+
+	app := fiber.New()
+	app.Post("/threads", CreateThreadEndpoint(cl, ml, appConfig))
+	app.Post("/threads/runs", CreateThreadAndRunEndpoint(cl, ml, evaluator, appConfig))
+	app.Get("/threads/:thread_id", GetThreadEndpoint(cl, ml, appConfig))
+	app.Post("/threads/:thread_id", ModifyThreadEndpoint(cl, ml, appConfig))
+	app.Delete("/threads/:thread_id", DeleteThreadEndpoint(cl, ml, appConfig))
+	app.Get("/threads/:thread_id/messages", ListThreadMessagesEndpoint(cl, ml, appConfig))
+	app.Post("/threads/:thread_id/messages", CreateThreadMessageEndpoint(cl, ml, appConfig))
+	app.Get("/threads/:thread_id/messages/:message_id", GetThreadMessageEndpoint(cl, ml, appConfig))
+	app.Post("/threads/:thread_id/runs", CreateRunEndpoint(cl, ml, evaluator, appConfig))
+	app.Get("/threads/:thread_id/runs", ListRunsEndpoint(cl, ml, appConfig))
+	app.Post("/threads/:thread_id/runs/:run_id/cancel", CancelRunEndpoint(cl, ml, appConfig))
+	app.Post("/threads/:thread_id/runs/:run_id/submit_tool_outputs", SubmitToolOutputsEndpoint(cl, ml, evaluator, appConfig))
+
+	t.Run("CreateThreadWithMessages", func(t *testing.T) {
+		t.Cleanup(tearDownThreads())
+
+		var thread Thread
+		resp := doJSON(t, app, http.MethodPost, "/threads", ThreadRequest{
+			Messages: []ThreadMessageRequest{
+				{Role: "user", Content: "Hello"},
+				{Role: "user", Content: []interface{}{
+					map[string]interface{}{"type": "text", "text": "What is in this image?"},
+					map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com/image.png"}},
+				}},
+			},
+			Metadata: map[string]string{"key": "value"},
+		}, &thread)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "thread", thread.Object)
+		assert.Equal(t, "value", thread.Metadata["key"])
+
+		var list struct {
+			Data    []ThreadMessage `json:"data"`
+			HasMore bool            `json:"has_more"`
+		}
+		resp = doJSON(t, app, http.MethodGet, fmt.Sprintf("/threads/%s/messages?order=asc", thread.ID), nil, &list)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Len(t, list.Data, 2)
+		assert.False(t, list.HasMore)
+		assert.Equal(t, "Hello", list.Data[0].Content[0].Text.Value)
+		assert.Equal(t, "https://example.com/image.png", list.Data[1].Content[1].ImageURL.URL)
+
+		m := list.Data[1].toSchemaMessage()
+		assert.IsType(t, []interface{}{}, m.Content)
+	})
+
+	t.Run("CreateThreadInvalidRole", func(t *testing.T) {
+		t.Cleanup(tearDownThreads())
+
+		resp := doJSON(t, app, http.MethodPost, "/threads", ThreadRequest{
+			Messages: []ThreadMessageRequest{{Role: "system", Content: "Hello"}},
+		}, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, Threads)
+		assert.Empty(t, ThreadMessages)
+	})
+
+	t.Run("MessagesAndPagination", func(t *testing.T) {
+		t.Cleanup(tearDownThreads())
+
+		var thread Thread
+		doJSON(t, app, http.MethodPost, "/threads", ThreadRequest{}, &thread)
+
+		ids := []string{}
+		for i := 0; i < 3; i++ {
+			var message ThreadMessage
+			resp := doJSON(t, app, http.MethodPost, fmt.Sprintf("/threads/%s/messages", thread.ID), ThreadMessageRequest{Role: "user", Content: fmt.Sprintf("message %d", i)}, &message)
+			assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+			ids = append(ids, message.ID)
+		}
+
+		var message ThreadMessage
+		resp := doJSON(t, app, http.MethodGet, fmt.Sprintf("/threads/%s/messages/%s", thread.ID, ids[1]), nil, &message)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "message 1", message.Content[0].Text.Value)
+
+		var list ListResponse
+		doJSON(t, app, http.MethodGet, fmt.Sprintf("/threads/%s/messages?order=asc&limit=1&after=%s", thread.ID, ids[0]), nil, &list)
+		assert.True(t, list.HasMore)
+		assert.Equal(t, ids[1], list.FirstID)
+		assert.Equal(t, ids[1], list.LastID)
+
+		// the messages created in the same second are listed newest first by default
+		doJSON(t, app, http.MethodGet, fmt.Sprintf("/threads/%s/messages", thread.ID), nil, &list)
+		assert.False(t, list.HasMore)
+		assert.Equal(t, ids[2], list.FirstID)
+		assert.Equal(t, ids[0], list.LastID)
+
+		resp = doJSON(t, app, http.MethodGet, fmt.Sprintf("/threads/%s/messages", "thread_missing"), nil, nil)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("ModifyAndDeleteThread", func(t *testing.T) {
+		t.Cleanup(tearDownThreads())
+
+		var thread Thread
+		doJSON(t, app, http.MethodPost, "/threads", ThreadRequest{Messages: []ThreadMessageRequest{{Role: "user", Content: "Hello"}}}, &thread)
+
+		var modified Thread
+		resp := doJSON(t, app, http.MethodPost, fmt.Sprintf("/threads/%s", thread.ID), ThreadRequest{Metadata: map[string]string{"updated": "true"}}, &modified)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, "true", modified.Metadata["updated"])
+
+		var deleted schema.DeleteAssistantResponse
+		resp = doJSON(t, app, http.MethodDelete, fmt.Sprintf("/threads/%s", thread.ID), nil, &deleted)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.True(t, deleted.Deleted)
+		assert.Empty(t, ThreadMessages)
+
+		resp = doJSON(t, app, http.MethodGet, fmt.Sprintf("/threads/%s", thread.ID), nil, nil)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("CreateRunUnknownAssistant", func(t *testing.T) {
+		t.Cleanup(tearDownThreads())
+
+		var thread Thread
+		doJSON(t, app, http.MethodPost, "/threads", ThreadRequest{}, &thread)
+
+		resp := doJSON(t, app, http.MethodPost, fmt.Sprintf("/threads/%s/runs", thread.ID), RunRequest{AssistantID: "asst_missing"}, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		assert.Empty(t, Runs)
+
+		resp = doJSON(t, app, http.MethodPost, "/threads/runs", CreateThreadAndRunRequest{RunRequest: RunRequest{AssistantID: "asst_missing"}}, nil)
+		assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+		assert.Len(t, Threads, 1)
+	})
+
+	t.Run("SubmitToolOutputs", func(t *testing.T) {
+		t.Cleanup(tearDownThreads())
+
+		var thread Thread
+		doJSON(t, app, http.MethodPost, "/threads", ThreadRequest{Messages: []ThreadMessageRequest{{Role: "user", Content: "What's the weather?"}}}, &thread)
+
+		// Simulate a run paused on a tool call
+		Runs = append(Runs, Run{ID: "run_1", ThreadID: thread.ID, Status: RunStatusRequiresAction, RequiredAction: &RequiredAction{Type: "submit_tool_outputs"}})
+		RunSteps = append(RunSteps, RunStep{ID: "step_1", RunID: "run_1", ThreadID: thread.ID, Type: "tool_calls", Status: RunStatusInProgress,
+			StepDetails: RunStepDetails{Type: "tool_calls", ToolCalls: []RunToolCall{{ID: "call_1", Type: "function", Function: RunToolCallFunction{Name: "get_weather", Arguments: `{"city":"Rome"}`}}}}})
+
+		// Adding messages is not allowed while the run is active
+		resp := doJSON(t, app, http.MethodPost, fmt.Sprintf("/threads/%s/messages", thread.ID), ThreadMessageRequest{Role: "user", Content: "Hello?"}, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		resp = doJSON(t, app, http.MethodPost, fmt.Sprintf("/threads/%s/runs/run_1/submit_tool_outputs", thread.ID), SubmitToolOutputsRequest{ToolOutputs: []ToolOutput{{ToolCallID: "call_2", Output: "sunny"}}}, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+		// Once the outputs are submitted, the tool calls are part of the conversation of the run
+		threadsMu.Lock()
+		step := findRunStep("step_1")
+		output := "sunny"
+		step.StepDetails.ToolCalls[0].Function.Output = &output
+		step.Status = RunStatusCompleted
+		messages := conversation(findRun(thread.ID, "run_1"))
+		threadsMu.Unlock()
+
+		assert.Len(t, messages, 3)
+		assert.Equal(t, "get_weather", messages[1].ToolCalls[0].FunctionCall.Name)
+		assert.Equal(t, "tool", messages[2].Role)
+		assert.Equal(t, "sunny", messages[2].Content)
+	})
+
+	t.Run("CancelRunRequiringAction", func(t *testing.T) {
+		t.Cleanup(tearDownThreads())
+
+		var thread Thread
+		doJSON(t, app, http.MethodPost, "/threads", ThreadRequest{}, &thread)
+		Runs = append(Runs, Run{ID: "run_1", ThreadID: thread.ID, Status: RunStatusRequiresAction, RequiredAction: &RequiredAction{Type: "submit_tool_outputs"}})
+
+		var run Run
+		resp := doJSON(t, app, http.MethodPost, fmt.Sprintf("/threads/%s/runs/run_1/cancel", thread.ID), nil, &run)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		assert.Equal(t, RunStatusCancelled, run.Status)
+
+		resp = doJSON(t, app, http.MethodPost, fmt.Sprintf("/threads/%s/runs/run_1/cancel", thread.ID), nil, nil)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestRecoverRuns(t *testing.T) {
+	t.Cleanup(tearDownThreads())
+	appConfig := &config.ApplicationConfig{ConfigsDir: configsDir}
+	_ = os.MkdirAll(appConfig.ConfigsDir, 0750)
+
+	Threads = []Thread{{ID: "thread_1"}, {ID: "thread_2"}, {ID: "thread_3"}}
+	Runs = []Run{
+		{ID: "run_1", ThreadID: "thread_1", Status: RunStatusInProgress},
+		{ID: "run_2", ThreadID: "thread_2", Status: RunStatusCancelling},
+		{ID: "run_3", ThreadID: "thread_3", Status: RunStatusRequiresAction},
+	}
+	RunSteps = []RunStep{{ID: "step_1", RunID: "run_1", ThreadID: "thread_1", Status: RunStatusInProgress}}
+	ThreadMessages = []ThreadMessage{{ID: "msg_1", RunID: "run_1", ThreadID: "thread_1", Status: "in_progress"}}
+
+	RecoverRuns(appConfig)
+
+	assert.Equal(t, RunStatusFailed, Runs[0].Status)
+	assert.NotNil(t, Runs[0].LastError)
+	assert.Equal(t, RunStatusCancelled, Runs[1].Status)
+	assert.Equal(t, RunStatusRequiresAction, Runs[2].Status)
+	assert.Equal(t, RunStatusFailed, RunSteps[0].Status)
+	assert.Equal(t, "incomplete", ThreadMessages[0].Status)
+	assert.Nil(t, activeRun("thread_1"))
+	assert.Nil(t, activeRun("thread_2"))
+}
+
+func doJSON(t *testing.T, app *fiber.App, method, target string, body interface{}, out interface{}) *http.Response {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		assert.NoError(t, err)
+		reader = strings.NewReader(string(data))
+	}
+
+	request := httptest.NewRequest(method, target, reader)
+	request.Header.Set(fiber.HeaderContentType, "application/json")
+	request.Header.Set("OpenAi-Beta", "assistants=v2")
+
+	resp, err := app.Test(request)
+	assert.NoError(t, err)
+
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		assert.NoError(t, err)
+	}
+	return resp
+}
@@ -7,9 +7,13 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/mudler/LocalAI/core/backend"
 	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/schema"
 	model "github.com/mudler/LocalAI/pkg/model"
 
 	"github.com/gofiber/fiber/v2"
@@ -21,57 +25,205 @@ import (
 // @accept multipart/form-data
 // @Param model formData string true "model"
 // @Param file formData file true "file"
+// @Param prompt formData string false "text to guide the style of the transcription"
+// @Param temperature formData number false "sampling temperature"
+// @Param response_format formData string false "json, text, srt, vtt or verbose_json"
+// @Param timestamp_granularities[] formData []string false "segment and/or word, with verbose_json"
 // @Success 200 {object} map[string]string	 "Response"
 // @Router /v1/audio/transcriptions [post]
 func TranscriptEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
 	return func(c *fiber.Ctx) error {
-		m, input, err := readRequest(c, cl, ml, appConfig, false)
-		if err != nil {
-			return fmt.Errorf("failed reading parameters from request:%w", err)
-		}
+		return transcribe(c, cl, ml, appConfig, false)
+	}
+}
 
-		config, input, err := mergeRequestWithConfig(m, input, cl, ml, appConfig.Debug, appConfig.Threads, appConfig.ContextSize, appConfig.F16)
-		if err != nil {
-			return fmt.Errorf("failed reading parameters from request: %w", err)
-		}
-		// retrieve the file data from the request
-		file, err := c.FormFile("file")
-		if err != nil {
-			return err
-		}
-		f, err := file.Open()
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+// TranslationEndpoint is the OpenAI Whisper API endpoint https://platform.openai.com/docs/api-reference/audio/createTranslation
+// @Summary Translates audio into English.
+// @accept multipart/form-data
+// @Param model formData string true "model"
+// @Param file formData file true "file"
+// @Param prompt formData string false "text in English to guide the style of the translation"
+// @Param temperature formData number false "sampling temperature"
+// @Param response_format formData string false "json, text, srt, vtt or verbose_json"
+// @Param timestamp_granularities[] formData []string false "segment and/or word, with verbose_json"
+// @Success 200 {object} map[string]string	 "Response"
+// @Router /v1/audio/translations [post]
+func TranslationEndpoint(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		return transcribe(c, cl, ml, appConfig, true)
+	}
+}
 
-		dir, err := os.MkdirTemp("", "whisper")
+// transcribe handles both transcriptions and translations, which always translate to English
+func transcribe(c *fiber.Ctx, cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig, translate bool) error {
+	m, input, err := readRequest(c, cl, ml, appConfig, false)
+	if err != nil {
+		return fmt.Errorf("failed reading parameters from request:%w", err)
+	}
 
-		if err != nil {
-			return err
-		}
-		defer os.RemoveAll(dir)
+	config, input, err := mergeRequestWithConfig(m, input, cl, ml, appConfig.Debug, appConfig.Threads, appConfig.ContextSize, appConfig.F16)
+	if err != nil {
+		return fmt.Errorf("failed reading parameters from request: %w", err)
+	}
 
-		dst := filepath.Join(dir, path.Base(file.Filename))
-		dstFile, err := os.Create(dst)
-		if err != nil {
-			return err
-		}
+	responseFormat := c.FormValue("response_format")
+	granularities := []string{}
+	if form, err := c.MultipartForm(); err == nil {
+		granularities = append(granularities, form.Value["timestamp_granularities[]"]...)
+		granularities = append(granularities, form.Value["timestamp_granularities"]...)
+	}
+	if err := validateTranscriptionFormat(responseFormat, granularities); err != nil {
+		return c.Status(fiber.StatusBadRequest).SendString(err.Error())
+	}
 
-		if _, err := io.Copy(dstFile, f); err != nil {
-			log.Debug().Msgf("Audio file copying error %+v - %+v - err %+v", file.Filename, dst, err)
-			return err
-		}
+	// the temperature of the model configuration is meant for text generation
+	var temperature float32
+	if input.Temperature != nil {
+		temperature = float32(*input.Temperature)
+	}
+
+	task := "transcribe"
+	if translate || input.Translate {
+		task = "translate"
+	}
 
-		log.Debug().Msgf("Audio file copied to: %+v", dst)
+	// retrieve the file data from the request
+	file, err := c.FormFile("file")
+	if err != nil {
+		return err
+	}
+	f, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dir, err := os.MkdirTemp("", "whisper")
+
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	dst := filepath.Join(dir, path.Base(file.Filename))
+	dstFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
 
-		tr, err := backend.ModelTranscription(dst, input.Language, input.Translate, ml, *config, appConfig)
-		if err != nil {
-			return err
+	if _, err := io.Copy(dstFile, f); err != nil {
+		log.Debug().Msgf("Audio file copying error %+v - %+v - err %+v", file.Filename, dst, err)
+		return err
+	}
+
+	log.Debug().Msgf("Audio file copied to: %+v", dst)
+
+	tr, err := backend.ModelTranscription(dst, input.Language, task == "translate", slices.Contains(granularities, "word"), c.FormValue("prompt"), temperature, ml, *config, appConfig)
+	if err != nil {
+		return err
+	}
+	if task == "translate" {
+		tr.Language = "en"
+	}
+
+	log.Debug().Msgf("Trascribed: %+v", tr)
+	return sendTranscription(c, tr, task, responseFormat, granularities)
+}
+
+func validateTranscriptionFormat(responseFormat string, granularities []string) error {
+	switch responseFormat {
+	case "", "json", "text", "srt", "vtt", "verbose_json":
+	default:
+		return fmt.Errorf("unsupported response_format: %s", responseFormat)
+	}
+
+	for _, g := range granularities {
+		if g != "segment" && g != "word" {
+			return fmt.Errorf("unsupported timestamp granularity: %s", g)
 		}
+	}
+	if len(granularities) > 0 && responseFormat != "verbose_json" {
+		return fmt.Errorf("timestamp_granularities requires the verbose_json response_format")
+	}
+	return nil
+}
 
-		log.Debug().Msgf("Trascribed: %+v", tr)
-		// TODO: handle different outputs here
+// sendTranscription returns the transcription in the OpenAI response format requested.
+// Without a format the whole result is returned, including the segments.
+func sendTranscription(c *fiber.Ctx, tr *schema.TranscriptionResult, task, responseFormat string, granularities []string) error {
+	switch responseFormat {
+	case "json":
+		return c.Status(http.StatusOK).JSON(fiber.Map{"text": tr.Text})
+	case "text":
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.Status(http.StatusOK).SendString(tr.Text)
+	case "srt":
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.Status(http.StatusOK).SendString(toSRT(tr))
+	case "vtt":
+		c.Set(fiber.HeaderContentType, "text/vtt; charset=utf-8")
+		return c.Status(http.StatusOK).SendString(toVTT(tr))
+	case "verbose_json":
+		return c.Status(http.StatusOK).JSON(toVerboseTranscription(tr, task, granularities))
+	default:
 		return c.Status(http.StatusOK).JSON(tr)
 	}
 }
+
+func toVerboseTranscription(tr *schema.TranscriptionResult, task string, granularities []string) schema.VerboseTranscription {
+	verbose := schema.VerboseTranscription{
+		Task:     task,
+		Language: tr.Language,
+		Duration: tr.Duration.Seconds(),
+		Text:     tr.Text,
+	}
+
+	// segments are the default granularity
+	withSegments := len(granularities) == 0 || slices.Contains(granularities, "segment")
+	withWords := slices.Contains(granularities, "word")
+
+	for _, s := range tr.Segments {
+		if withSegments {
+			verbose.Segments = append(verbose.Segments, schema.VerboseTranscriptionSegment{
+				Id:     s.Id,
+				Start:  s.Start.Seconds(),
+				End:    s.End.Seconds(),
+				Text:   s.Text,
+				Tokens: s.Tokens,
+			})
+		}
+		if withWords {
+			for _, w := range s.Words {
+				verbose.Words = append(verbose.Words, schema.VerboseTranscriptionWord{
+					Word:  w.Word,
+					Start: w.Start.Seconds(),
+					End:   w.End.Seconds(),
+				})
+			}
+		}
+	}
+	return verbose
+}
+
+func toSRT(tr *schema.TranscriptionResult) string {
+	var sb strings.Builder
+	for i, s := range tr.Segments {
+		fmt.Fprintf(&sb, "%d\n%s --> %s\n%s\n\n", i+1, formatTimestamp(s.Start, ","), formatTimestamp(s.End, ","), strings.TrimSpace(s.Text))
+	}
+	return sb.String()
+}
+
+func toVTT(tr *schema.TranscriptionResult) string {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n\n")
+	for _, s := range tr.Segments {
+		fmt.Fprintf(&sb, "%s --> %s\n%s\n\n", formatTimestamp(s.Start, "."), formatTimestamp(s.End, "."), strings.TrimSpace(s.Text))
+	}
+	return sb.String()
+}
+
+// formatTimestamp formats a duration as hh:mm:ss followed by the milliseconds, as used by subtitles
+func formatTimestamp(d time.Duration, millisSeparator string) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, millisSeparator, ms%1000)
+}
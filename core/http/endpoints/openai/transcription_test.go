@@ -0,0 +1,45 @@
+package openai
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mudler/LocalAI/core/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTranscription = &schema.TranscriptionResult{
+	Text:     " Hello world. How are you?",
+	Language: "en",
+	Duration: 3500 * time.Millisecond,
+	Segments: []schema.Segment{
+		{Id: 0, Start: 0, End: 1500 * time.Millisecond, Text: " Hello world.", Tokens: []int{1, 2},
+			Words: []schema.Word{{Word: "Hello", Start: 0, End: 700 * time.Millisecond}, {Word: "world.", Start: 700 * time.Millisecond, End: 1500 * time.Millisecond}}},
+		{Id: 1, Start: 1500 * time.Millisecond, End: 3723*time.Second + 45*time.Millisecond, Text: " How are you?", Tokens: []int{3}},
+	},
+}
+
+func TestTranscriptionFormats(t *testing.T) {
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,500\nHello world.\n\n2\n00:00:01,500 --> 01:02:03,045\nHow are you?\n\n", toSRT(testTranscription))
+	assert.Equal(t, "WEBVTT\n\n00:00:00.000 --> 00:00:01.500\nHello world.\n\n00:00:01.500 --> 01:02:03.045\nHow are you?\n\n", toVTT(testTranscription))
+
+	verbose := toVerboseTranscription(testTranscription, "transcribe", nil)
+	assert.Equal(t, "transcribe", verbose.Task)
+	assert.Equal(t, 3.5, verbose.Duration)
+	assert.Len(t, verbose.Segments, 2)
+	assert.Equal(t, 1.5, verbose.Segments[1].Start)
+	assert.Empty(t, verbose.Words)
+
+	verbose = toVerboseTranscription(testTranscription, "transcribe", []string{"word"})
+	assert.Empty(t, verbose.Segments)
+	assert.Equal(t, []schema.VerboseTranscriptionWord{{Word: "Hello", Start: 0, End: 0.7}, {Word: "world.", Start: 0.7, End: 1.5}}, verbose.Words)
+}
+
+func TestValidateTranscriptionFormat(t *testing.T) {
+	assert.NoError(t, validateTranscriptionFormat("", nil))
+	assert.NoError(t, validateTranscriptionFormat("srt", nil))
+	assert.NoError(t, validateTranscriptionFormat("verbose_json", []string{"word", "segment"}))
+	assert.Error(t, validateTranscriptionFormat("xml", nil))
+	assert.Error(t, validateTranscriptionFormat("json", []string{"word"}))
+	assert.Error(t, validateTranscriptionFormat("verbose_json", []string{"sentence"}))
+}
@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mudler/LocalAI/core/backend"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/rs/zerolog/log"
+)
+
+// ModelHeader is the response header reporting the model, or the variant, which served a request to a virtual model
+const ModelHeader = "X-Model"
+
+// shadowPriority makes the mirrored requests give way to the ones served to the clients in the model queues
+const shadowPriority = -1
+
+// ModelRouter routes the requests for virtual models, which define a list of targets or variants, to the
+// models serving them, and mirrors part of the requests to shadow models
+type ModelRouter struct {
+	cl        *config.BackendConfigLoader
+	ml        *model.ModelLoader
+	appConfig *config.ApplicationConfig
+	// load loads a target before it serves a request
+	load func(name string) error
+}
+
+func NewModelRouter(cl *config.BackendConfigLoader, ml *model.ModelLoader, appConfig *config.ApplicationConfig) *ModelRouter {
+	r := &ModelRouter{cl: cl, ml: ml, appConfig: appConfig}
+	r.load = r.loadModel
+	return r
+}
+
+// Route wraps the handler of an endpoint, sending the requests for a virtual model to one of its variants,
+// picked by weight, or else to its first healthy target.
+// If the model can't serve the request because it fails loading, or its queue is full, the next target is tried.
+// The targets are loaded before calling the handler, as a streamed response can't fall back once it started.
+func (r *ModelRouter) Route(handler fiber.Handler) fiber.Handler {
+	// the mirrored requests are dispatched in-process to the same handler, like the batch requests
+	shadows := fiber.New(fiber.Config{
+		BodyLimit:             r.appConfig.UploadLimitMB * 1024 * 1024,
+		DisableStartupMessage: true,
+	})
+	shadows.Post("/", handler)
+
+	return func(c *fiber.Ctx) error {
+		body := map[string]json.RawMessage{}
+		isJSON := json.Unmarshal(c.Body(), &body) == nil
+
+		// the model is picked like in ModelFromContext: the path and the query take precedence over the body.
+		// The model of the multipart and form requests (e.g. the transcriptions) is read from the form, the
+		// handlers read the target from the request locals.
+		var name string
+		if isJSON {
+			json.Unmarshal(body["model"], &name)
+		} else {
+			name = c.FormValue("model")
+		}
+		if c.Params("model") != "" {
+			name = c.Params("model")
+		}
+		if c.Query("model") != "" {
+			name = c.Query("model")
+		}
+		cfg, exists := r.cl.GetBackendConfig(name)
+		if !exists {
+			return handler(c)
+		}
+
+		if isJSON && cfg.Shadow.Model != "" && rand.Float64()*100 < cfg.Shadow.Percent {
+			r.shadow(shadows, c, body, name, cfg.Shadow.Model)
+		}
+
+		models := r.candidates(cfg)
+		if len(models) == 0 {
+			return handler(c)
+		}
+
+		var err error
+		for _, target := range models {
+			if err = r.load(target); err == nil {
+				if isJSON {
+					body["model"], _ = json.Marshal(target)
+					data, _ := json.Marshal(body)
+					c.Request().SetBody(data)
+				}
+				c.Locals(fiberContext.RoutedModelKey, target)
+				c.Status(fiber.StatusOK)
+				c.Response().ResetBody()
+				c.Set(ModelHeader, target)
+
+				err = handler(c)
+			}
+			if !isFallbackError(err) {
+				return err
+			}
+			log.Warn().Err(err).Str("model", name).Str("target", target).Msg("target failed, falling back to the next one")
+		}
+		return err
+	}
+}
+
+// Resolve returns the model serving the requests for a model, for the callers which don't go through Route
+// (e.g. the assistant runs): the first variant or target of a virtual model which loads, or else the model itself.
+func (r *ModelRouter) Resolve(name string) (string, error) {
+	cfg, exists := r.cl.GetBackendConfig(name)
+	if !exists {
+		return name, nil
+	}
+	models := r.candidates(cfg)
+	if len(models) == 0 {
+		return name, nil
+	}
+
+	var err error
+	for _, target := range models {
+		if err = r.load(target); !isFallbackError(err) {
+			return target, err
+		}
+		log.Warn().Err(err).Str("model", name).Str("target", target).Msg("target failed, falling back to the next one")
+	}
+	return "", err
+}
+
+// candidates returns the models which can serve the requests for a virtual model, in the order they are tried:
+// the variant picked by weight, the other variants by decreasing weight, then the healthy targets and finally
+// the unhealthy ones
+func (r *ModelRouter) candidates(cfg config.BackendConfig) []string {
+	models := []string{}
+	if variant := pickVariant(cfg.Variants); variant != "" {
+		models = append(models, variant)
+		variants := slices.Clone(cfg.Variants)
+		slices.SortStableFunc(variants, func(a, b config.Variant) int { return b.Weight - a.Weight })
+		for _, v := range variants {
+			if v.Weight > 0 && !slices.Contains(models, v.Model) {
+				models = append(models, v.Model)
+			}
+		}
+	}
+	for _, t := range r.targets(cfg.Targets) {
+		if !slices.Contains(models, t) {
+			models = append(models, t)
+		}
+	}
+	return models
+}
+
+// loadModel loads the model, so a failure can be handled before the response starts
+func (r *ModelRouter) loadModel(name string) error {
+	cfg, err := r.cl.LoadBackendConfigFileByName(name, r.appConfig.ModelPath,
+		config.LoadOptionDebug(r.appConfig.Debug),
+		config.LoadOptionThreads(r.appConfig.Threads),
+		config.LoadOptionContextSize(r.appConfig.ContextSize),
+		config.LoadOptionF16(r.appConfig.F16),
+	)
+	if err != nil {
+		return err
+	}
+	if err := r.ml.Available(backend.ModelID(*cfg)); err != nil {
+		return err
+	}
+	_, err = r.ml.Load(backend.ModelOptions(*cfg, r.appConfig)...)
+	return err
+}
+
+// shadow sends a copy of the request to the shadow model in the background, and logs the status, the latency
+// and the usage of its response, the response itself is logged only at the debug level as it can hold user data.
+// The request and the mirrored one share their correlation ID, so their responses can be compared.
+func (r *ModelRouter) shadow(shadows *fiber.App, c *fiber.Ctx, body map[string]json.RawMessage, name, shadowModel string) {
+	correlationID := c.Get("X-Correlation-ID")
+	if correlationID == "" {
+		correlationID = uuid.New().String()
+		c.Request().Header.Set("X-Correlation-ID", correlationID)
+	}
+
+	mirrored := maps.Clone(body)
+	mirrored["model"], _ = json.Marshal(shadowModel)
+	// the response is only logged, there is no need to stream it
+	mirrored["stream"] = json.RawMessage("false")
+	data, _ := json.Marshal(mirrored)
+
+	go func() {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set("X-Correlation-ID", correlationID)
+		req.Header.Set(fiberContext.PriorityHeader, strconv.Itoa(shadowPriority))
+
+		l := log.With().Str("model", name).Str("shadow", shadowModel).Str("correlation_id", correlationID).Logger()
+		start := time.Now()
+		resp, err := shadows.Test(req, -1)
+		if err != nil {
+			l.Error().Err(err).Msg("shadow request failed")
+			return
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			l.Error().Err(err).Msg("shadow request failed")
+			return
+		}
+		event := l.Info().Int("status", resp.StatusCode).Dur("latency", time.Since(start))
+		usage := struct {
+			Usage json.RawMessage `json:"usage"`
+		}{}
+		if json.Unmarshal(respBody, &usage) == nil && len(usage.Usage) > 0 {
+			event = event.RawJSON("usage", usage.Usage)
+		}
+		event.Msg("shadow response")
+		l.Debug().Str("response", string(respBody)).Msg("shadow response body")
+	}()
+}
+
+// pickVariant picks one of the variants at random, by weight
+func pickVariant(variants []config.Variant) string {
+	total := 0
+	for _, v := range variants {
+		total += max(v.Weight, 0)
+	}
+	if total == 0 {
+		return ""
+	}
+	n := rand.IntN(total)
+	for _, v := range variants {
+		if n < max(v.Weight, 0) {
+			return v.Model
+		}
+		n -= max(v.Weight, 0)
+	}
+	return ""
+}
+
+// targets returns the healthy targets first, keeping their order
+func (r *ModelRouter) targets(targets []string) []string {
+	healthy, unhealthy := []string{}, []string{}
+	for _, t := range targets {
+		if r.healthy(t) {
+			healthy = append(healthy, t)
+		} else {
+			unhealthy = append(unhealthy, t)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// healthy returns false if the model is known not to be able to serve a request right now
+func (r *ModelRouter) healthy(name string) bool {
+	return r.ml.Available(name) == nil
+}
+
+// isFallbackError returns true if the request can be served by another model
+func isFallbackError(err error) bool {
+	var loadErr *model.LoadError
+	return errors.As(err, &loadErr) ||
+		errors.Is(err, model.ErrQueueFull) ||
+		errors.Is(err, model.ErrQueueTimeout) ||
+		errors.Is(err, model.ErrBackendRestarting) ||
+		errors.Is(err, model.ErrBackendFailed)
+}
@@ -0,0 +1,366 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	fiberContext "github.com/mudler/LocalAI/core/http/ctx"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelRouter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "virtual.yaml"), []byte("name: virtual\ntargets:\n- big\n- small\n"), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+	ml := model.NewModelLoader(dir)
+
+	var served []string
+	failing := map[string]error{}
+	failingLoad := map[string]error{}
+
+	router := NewModelRouter(cl, ml, &config.ApplicationConfig{})
+	router.load = func(name string) error { return failingLoad[name] }
+
+	app := fiber.New()
+	app.Post("/", router.Route(func(c *fiber.Ctx) error {
+		input := struct {
+			Model string `json:"model"`
+		}{}
+		if err := c.BodyParser(&input); err != nil {
+			return err
+		}
+		served = append(served, input.Model)
+		if err := failing[input.Model]; err != nil {
+			return err
+		}
+		return c.SendString(input.Model)
+	}))
+
+	for _, tc := range []struct {
+		name         string
+		model        string
+		failing      map[string]error
+		failingLoad  map[string]error
+		expectStatus int
+		expectHeader string
+		expectServed []string
+	}{
+		{
+			name:         "plain model",
+			model:        "big",
+			expectStatus: 200,
+			expectServed: []string{"big"},
+		},
+		{
+			name:         "virtual model",
+			model:        "virtual",
+			expectStatus: 200,
+			expectHeader: "big",
+			expectServed: []string{"big"},
+		},
+		{
+			name:         "fallback on load error",
+			model:        "virtual",
+			failing:      map[string]error{"big": &model.LoadError{ModelID: "big", Err: fmt.Errorf("failed")}},
+			expectStatus: 200,
+			expectHeader: "small",
+			expectServed: []string{"big", "small"},
+		},
+		{
+			name:         "fallback before the response starts",
+			model:        "virtual",
+			failingLoad:  map[string]error{"big": &model.LoadError{ModelID: "big", Err: fmt.Errorf("failed")}},
+			expectStatus: 200,
+			expectHeader: "small",
+			expectServed: []string{"small"},
+		},
+		{
+			name:         "fallback on full queue",
+			model:        "virtual",
+			failing:      map[string]error{"big": model.ErrQueueFull},
+			expectStatus: 200,
+			expectHeader: "small",
+			expectServed: []string{"big", "small"},
+		},
+		{
+			name:         "no fallback on other errors",
+			model:        "virtual",
+			failing:      map[string]error{"big": fiber.ErrBadRequest},
+			expectStatus: 400,
+			expectHeader: "big",
+			expectServed: []string{"big"},
+		},
+		{
+			name:    "all targets failing",
+			model:   "virtual",
+			failing: map[string]error{"big": model.ErrQueueFull, "small": model.ErrQueueTimeout},
+			// the error of the last target is returned
+			expectStatus: 500,
+			expectHeader: "small",
+			expectServed: []string{"big", "small"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			served = nil
+			failing = tc.failing
+			failingLoad = tc.failingLoad
+
+			req := httptest.NewRequest("POST", "/", strings.NewReader(fmt.Sprintf(`{"model":%q,"prompt":"hello"}`, tc.model)))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req, -1)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectStatus, resp.StatusCode)
+			require.Equal(t, tc.expectHeader, resp.Header.Get(ModelHeader))
+			require.Equal(t, tc.expectServed, served)
+
+			if tc.expectStatus == 200 {
+				// the response comes from the last target tried
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				require.Equal(t, served[len(served)-1], string(body))
+			}
+		})
+	}
+}
+
+func TestModelRouterVariants(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "canary.yaml"), []byte("name: canary\ntargets:\n- small\nvariants:\n- model: stable\n  weight: 1\n- model: candidate\n  weight: 0\n"), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+	ml := model.NewModelLoader(dir)
+
+	router := NewModelRouter(cl, ml, &config.ApplicationConfig{})
+	router.load = func(string) error { return nil }
+
+	var served []string
+	app := fiber.New()
+	app.Post("/", router.Route(func(c *fiber.Ctx) error {
+		input := struct {
+			Model string `json:"model"`
+		}{}
+		if err := c.BodyParser(&input); err != nil {
+			return err
+		}
+		served = append(served, input.Model)
+		if input.Model == "stable" {
+			return model.ErrQueueTimeout
+		}
+		return c.SendString(input.Model)
+	}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"model":"canary"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	// the variant is tried first, then the targets
+	require.Equal(t, []string{"stable", "small"}, served)
+	require.Equal(t, "small", resp.Header.Get(ModelHeader))
+}
+
+func TestModelRouterPathModel(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "virtual.yaml"), []byte("name: virtual\ntargets:\n- big\n"), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+
+	router := NewModelRouter(cl, model.NewModelLoader(dir), &config.ApplicationConfig{})
+	router.load = func(string) error { return nil }
+
+	app := fiber.New()
+	app.Post("/v1/engines/:model/completions", router.Route(func(c *fiber.Ctx) error {
+		m, err := fiberContext.ModelFromContext(c, cl, nil, "", false)
+		if err != nil {
+			return err
+		}
+		return c.SendString(m)
+	}))
+
+	req := httptest.NewRequest("POST", "/v1/engines/virtual/completions", strings.NewReader(`{"prompt":"hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "big", string(body))
+}
+
+func TestModelRouterMultipart(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "virtual.yaml"), []byte("name: virtual\ntargets:\n- big\n- small\n"), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+
+	router := NewModelRouter(cl, model.NewModelLoader(dir), &config.ApplicationConfig{})
+	router.load = func(name string) error {
+		if name == "big" {
+			return model.ErrQueueFull
+		}
+		return nil
+	}
+
+	app := fiber.New()
+	app.Post("/v1/audio/transcriptions", router.Route(func(c *fiber.Ctx) error {
+		m, err := fiberContext.ModelFromContext(c, cl, nil, c.FormValue("model"), false)
+		if err != nil {
+			return err
+		}
+		file, err := c.FormFile("file")
+		if err != nil {
+			return err
+		}
+		return c.SendString(m + " " + file.Filename)
+	}))
+
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	require.NoError(t, form.WriteField("model", "virtual"))
+	w, err := form.CreateFormFile("file", "audio.wav")
+	require.NoError(t, err)
+	_, err = w.Write([]byte("RIFF"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest("POST", "/v1/audio/transcriptions", &buf)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "small audio.wav", string(body))
+	require.Equal(t, "small", resp.Header.Get(ModelHeader))
+}
+
+func TestModelRouterResolve(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "virtual.yaml"), []byte("name: virtual\ntargets:\n- big\n- small\n"), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+
+	failingLoad := map[string]error{}
+	router := NewModelRouter(cl, model.NewModelLoader(dir), &config.ApplicationConfig{})
+	router.load = func(name string) error { return failingLoad[name] }
+
+	m, err := router.Resolve("plain")
+	require.NoError(t, err)
+	require.Equal(t, "plain", m)
+
+	m, err = router.Resolve("virtual")
+	require.NoError(t, err)
+	require.Equal(t, "big", m)
+
+	failingLoad["big"] = model.ErrQueueFull
+	m, err = router.Resolve("virtual")
+	require.NoError(t, err)
+	require.Equal(t, "small", m)
+
+	failingLoad["small"] = model.ErrQueueTimeout
+	_, err = router.Resolve("virtual")
+	require.ErrorIs(t, err, model.ErrQueueTimeout)
+}
+
+func TestModelRouterShadow(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.yaml"), []byte("name: main\nshadow:\n  model: candidate\n  percent: 100\n"), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+	ml := model.NewModelLoader(dir)
+
+	type request struct {
+		Model         string `json:"model"`
+		Stream        bool   `json:"stream"`
+		Prompt        string `json:"prompt"`
+		CorrelationID string `json:"-"`
+	}
+	requests := make(chan request, 2)
+
+	app := fiber.New()
+	app.Post("/", NewModelRouter(cl, ml, &config.ApplicationConfig{}).Route(func(c *fiber.Ctx) error {
+		input := request{}
+		if err := c.BodyParser(&input); err != nil {
+			return err
+		}
+		input.CorrelationID = c.Get("X-Correlation-ID")
+		requests <- input
+		return c.SendString(input.Model)
+	}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"model":"main","stream":true,"prompt":"hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "main", string(body))
+
+	received := map[string]request{}
+	for range 2 {
+		r := <-requests
+		received[r.Model] = r
+	}
+	require.True(t, received["main"].Stream)
+	require.False(t, received["candidate"].Stream)
+	require.Equal(t, "hello", received["candidate"].Prompt)
+	require.NotEmpty(t, received["main"].CorrelationID)
+	require.Equal(t, received["main"].CorrelationID, received["candidate"].CorrelationID)
+}
+
+func TestPickVariant(t *testing.T) {
+	variants := []config.Variant{{Model: "stable", Weight: 3}, {Model: "candidate", Weight: 1}, {Model: "disabled"}}
+
+	picked := map[string]int{}
+	for range 4000 {
+		picked[pickVariant(variants)]++
+	}
+	require.Zero(t, picked["disabled"])
+	require.InDelta(t, 3000, picked["stable"], 300)
+	require.InDelta(t, 1000, picked["candidate"], 300)
+
+	require.Empty(t, pickVariant(nil))
+	require.Empty(t, pickVariant([]config.Variant{{Model: "disabled"}}))
+}
+
+func TestModelRouterCandidates(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "canary.yaml"), []byte("name: canary\ntargets:\n- small\n- stable\nvariants:\n- model: candidate\n  weight: 1\n- model: stable\n  weight: 3\n- model: disabled\n  weight: 0\n"), 0600))
+
+	cl := config.NewBackendConfigLoader(dir)
+	require.NoError(t, cl.LoadBackendConfigsFromPath(dir))
+	router := NewModelRouter(cl, model.NewModelLoader(dir), &config.ApplicationConfig{})
+	cfg, exists := cl.GetBackendConfig("canary")
+	require.True(t, exists)
+
+	// the other variants are tried by weight before the targets, each model once
+	picked := map[string]bool{}
+	for range 100 {
+		models := router.candidates(cfg)
+		picked[models[0]] = true
+		if models[0] == "stable" {
+			require.Equal(t, []string{"stable", "candidate", "small"}, models)
+		} else {
+			require.Equal(t, []string{"candidate", "stable", "small"}, models)
+		}
+	}
+	require.Len(t, picked, 2)
+}
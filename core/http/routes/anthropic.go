@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/anthropic"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+)
+
+func RegisterAnthropicRoutes(app *fiber.App,
+	cl *config.BackendConfigLoader,
+	ml *model.ModelLoader,
+	evaluator *templates.Evaluator,
+	appConfig *config.ApplicationConfig) {
+
+	// Anthropic Messages API
+	app.Post("/v1/messages", middleware.NewModelRouter(cl, ml, appConfig).Route(anthropic.MessagesEndpoint(cl, ml, evaluator, appConfig)))
+}
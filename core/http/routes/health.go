@@ -1,13 +1,45 @@
 package routes
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/application"
+)
 
-func HealthRoutes(app *fiber.App) {
+func HealthRoutes(app *fiber.App, application *application.Application) {
 	// Service health checks
 	ok := func(c *fiber.Ctx) error {
 		return c.SendStatus(200)
 	}
 
+	// ready once the models loaded at startup are loaded, and not anymore while draining the requests on shutdown.
+	// The readiness scoped to models reports their configuration, so it is served after the authentication.
+	ready := func(c *fiber.Ctx) error {
+		if c.Context().QueryArgs().Has("model") {
+			return c.Next()
+		}
+		return readiness(c, application, nil)
+	}
+
 	app.Get("/healthz", ok)
-	app.Get("/readyz", ok)
+	app.Get("/readyz", ready)
+}
+
+// ModelReadinessRoutes serves the readiness scoped to models with ?model=, repeated for several models.
+// It must be registered after the authentication middleware.
+func ModelReadinessRoutes(app *fiber.App, application *application.Application) {
+	app.Get("/readyz", func(c *fiber.Ctx) error {
+		models := []string{}
+		for _, m := range c.Context().QueryArgs().PeekMulti("model") {
+			models = append(models, string(m))
+		}
+		return readiness(c, application, models)
+	})
+}
+
+func readiness(c *fiber.Ctx, application *application.Application, models []string) error {
+	resp := application.Readiness(models)
+	if !resp.Ready {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return c.JSON(resp)
 }
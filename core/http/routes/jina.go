@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http/endpoints/jina"
+	"github.com/mudler/LocalAI/core/http/middleware"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/mudler/LocalAI/pkg/model"
@@ -14,5 +15,5 @@ func RegisterJINARoutes(app *fiber.App,
 	appConfig *config.ApplicationConfig) {
 
 	// POST endpoint to mimic the reranking
-	app.Post("/v1/rerank", jina.JINARerankEndpoint(cl, ml, appConfig))
+	app.Post("/v1/rerank", middleware.NewModelRouter(cl, ml, appConfig).Route(jina.JINARerankEndpoint(cl, ml, appConfig)))
 }
@@ -5,6 +5,7 @@ import (
 	"github.com/gofiber/swagger"
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
+	"github.com/mudler/LocalAI/core/http/middleware"
 	"github.com/mudler/LocalAI/core/p2p"
 	"github.com/mudler/LocalAI/core/services"
 	"github.com/mudler/LocalAI/internal"
@@ -33,8 +34,10 @@ func RegisterLocalAIRoutes(router *fiber.App,
 		router.Get("/models/jobs", modelGalleryEndpointService.GetAllStatusEndpoint())
 	}
 
-	router.Post("/tts", localai.TTSEndpoint(cl, ml, appConfig))
-	router.Post("/vad", localai.VADEndpoint(cl, ml, appConfig))
+	// requests for virtual models are routed to their targets
+	modelRouter := middleware.NewModelRouter(cl, ml, appConfig)
+	router.Post("/tts", modelRouter.Route(localai.TTSEndpoint(cl, ml, appConfig)))
+	router.Post("/vad", modelRouter.Route(localai.VADEndpoint(cl, ml, appConfig)))
 
 	// Stores
 	sl := model.NewModelLoader("")
@@ -51,6 +54,9 @@ func RegisterLocalAIRoutes(router *fiber.App,
 	backendMonitorService := services.NewBackendMonitorService(ml, cl, appConfig) // Split out for now
 	router.Get("/backend/monitor", localai.BackendMonitorEndpoint(backendMonitorService))
 	router.Post("/backend/shutdown", localai.BackendShutdownEndpoint(backendMonitorService))
+	router.Post("/backend/reset", localai.BackendResetEndpoint(backendMonitorService))
+	router.Post("/backend/pin", localai.BackendPinEndpoint(backendMonitorService))
+	router.Post("/backend/unpin", localai.BackendUnpinEndpoint(backendMonitorService))
 
 	// p2p
 	if p2p.IsP2PEnabled() {
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mudler/LocalAI/core/config"
+	"github.com/mudler/LocalAI/core/http/endpoints/ollama"
+	"github.com/mudler/LocalAI/core/http/middleware"
+	"github.com/mudler/LocalAI/core/services"
+	"github.com/mudler/LocalAI/pkg/model"
+	"github.com/mudler/LocalAI/pkg/templates"
+)
+
+func RegisterOllamaRoutes(app *fiber.App,
+	cl *config.BackendConfigLoader,
+	ml *model.ModelLoader,
+	evaluator *templates.Evaluator,
+	appConfig *config.ApplicationConfig,
+	galleryService *services.GalleryService) {
+
+	// requests for virtual models are routed to their targets
+	modelRouter := middleware.NewModelRouter(cl, ml, appConfig)
+
+	// Ollama API
+	app.Post("/api/chat", modelRouter.Route(ollama.ChatEndpoint(cl, ml, evaluator, appConfig)))
+	app.Post("/api/generate", modelRouter.Route(ollama.GenerateEndpoint(cl, ml, evaluator, appConfig)))
+	app.Post("/api/embed", modelRouter.Route(ollama.EmbedEndpoint(cl, ml, appConfig)))
+	app.Post("/api/embeddings", modelRouter.Route(ollama.EmbeddingsEndpoint(cl, ml, appConfig)))
+	app.Post("/api/show", ollama.ShowEndpoint(cl, ml))
+	app.Post("/api/pull", ollama.PullEndpoint(galleryService, appConfig))
+	app.Get("/api/tags", ollama.TagsEndpoint(cl, ml))
+	app.Get("/api/version", ollama.VersionEndpoint())
+}
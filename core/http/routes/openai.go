@@ -5,48 +5,52 @@ import (
 	"github.com/mudler/LocalAI/core/application"
 	"github.com/mudler/LocalAI/core/http/endpoints/localai"
 	"github.com/mudler/LocalAI/core/http/endpoints/openai"
+	"github.com/mudler/LocalAI/core/http/middleware"
 )
 
 func RegisterOpenAIRoutes(app *fiber.App,
 	application *application.Application) {
+	// requests for virtual models are routed to their targets
+	modelRouter := middleware.NewModelRouter(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())
+
 	// openAI compatible API endpoint
 
 	// chat
 	app.Post("/v1/chat/completions",
-		openai.ChatEndpoint(
+		modelRouter.Route(openai.ChatEndpoint(
 			application.BackendLoader(),
 			application.ModelLoader(),
 			application.TemplatesEvaluator(),
 			application.ApplicationConfig(),
-		),
+		)),
 	)
 
 	app.Post("/chat/completions",
-		openai.ChatEndpoint(
+		modelRouter.Route(openai.ChatEndpoint(
 			application.BackendLoader(),
 			application.ModelLoader(),
 			application.TemplatesEvaluator(),
 			application.ApplicationConfig(),
-		),
+		)),
 	)
 
 	// edit
 	app.Post("/v1/edits",
-		openai.EditEndpoint(
+		modelRouter.Route(openai.EditEndpoint(
 			application.BackendLoader(),
 			application.ModelLoader(),
 			application.TemplatesEvaluator(),
 			application.ApplicationConfig(),
-		),
+		)),
 	)
 
 	app.Post("/edits",
-		openai.EditEndpoint(
+		modelRouter.Route(openai.EditEndpoint(
 			application.BackendLoader(),
 			application.ModelLoader(),
 			application.TemplatesEvaluator(),
 			application.ApplicationConfig(),
-		),
+		)),
 	)
 
 	// assistant
@@ -69,6 +73,42 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	app.Get("/v1/assistants/:assistant_id/files/:file_id", openai.GetAssistantFileEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
 	app.Get("/assistants/:assistant_id/files/:file_id", openai.GetAssistantFileEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
 
+	// threads
+	app.Post("/v1/threads", openai.CreateThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/threads", openai.CreateThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/threads/runs", openai.CreateThreadAndRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Post("/threads/runs", openai.CreateThreadAndRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Get("/v1/threads/:thread_id", openai.GetThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/threads/:thread_id", openai.GetThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/threads/:thread_id", openai.ModifyThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/threads/:thread_id", openai.ModifyThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Delete("/v1/threads/:thread_id", openai.DeleteThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Delete("/threads/:thread_id", openai.DeleteThreadEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/v1/threads/:thread_id/messages", openai.ListThreadMessagesEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/threads/:thread_id/messages", openai.ListThreadMessagesEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/threads/:thread_id/messages", openai.CreateThreadMessageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/threads/:thread_id/messages", openai.CreateThreadMessageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/v1/threads/:thread_id/messages/:message_id", openai.GetThreadMessageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/threads/:thread_id/messages/:message_id", openai.GetThreadMessageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/threads/:thread_id/messages/:message_id", openai.ModifyThreadMessageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/threads/:thread_id/messages/:message_id", openai.ModifyThreadMessageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+
+	// runs
+	app.Get("/v1/threads/:thread_id/runs", openai.ListRunsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/threads/:thread_id/runs", openai.ListRunsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/threads/:thread_id/runs", openai.CreateRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Post("/threads/:thread_id/runs", openai.CreateRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Get("/v1/threads/:thread_id/runs/:run_id", openai.GetRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/threads/:thread_id/runs/:run_id", openai.GetRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/threads/:thread_id/runs/:run_id/cancel", openai.CancelRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/threads/:thread_id/runs/:run_id/cancel", openai.CancelRunEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/threads/:thread_id/runs/:run_id/submit_tool_outputs", openai.SubmitToolOutputsEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Post("/threads/:thread_id/runs/:run_id/submit_tool_outputs", openai.SubmitToolOutputsEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Get("/v1/threads/:thread_id/runs/:run_id/steps", openai.ListRunStepsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/threads/:thread_id/runs/:run_id/steps", openai.ListRunStepsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/v1/threads/:thread_id/runs/:run_id/steps/:step_id", openai.GetRunStepEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Get("/threads/:thread_id/runs/:run_id/steps/:step_id", openai.GetRunStepEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+
 	// files
 	app.Post("/v1/files", openai.UploadFilesEndpoint(application.BackendLoader(), application.ApplicationConfig()))
 	app.Post("/files", openai.UploadFilesEndpoint(application.BackendLoader(), application.ApplicationConfig()))
@@ -81,45 +121,61 @@ func RegisterOpenAIRoutes(app *fiber.App,
 	app.Get("/v1/files/:file_id/content", openai.GetFilesContentsEndpoint(application.BackendLoader(), application.ApplicationConfig()))
 	app.Get("/files/:file_id/content", openai.GetFilesContentsEndpoint(application.BackendLoader(), application.ApplicationConfig()))
 
+	// batches
+	app.Post("/v1/batches", openai.CreateBatchEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Post("/batches", openai.CreateBatchEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
+	app.Get("/v1/batches", openai.ListBatchesEndpoint(application.BackendLoader(), application.ApplicationConfig()))
+	app.Get("/batches", openai.ListBatchesEndpoint(application.BackendLoader(), application.ApplicationConfig()))
+	app.Get("/v1/batches/:batch_id", openai.GetBatchEndpoint(application.BackendLoader(), application.ApplicationConfig()))
+	app.Get("/batches/:batch_id", openai.GetBatchEndpoint(application.BackendLoader(), application.ApplicationConfig()))
+	app.Post("/v1/batches/:batch_id/cancel", openai.CancelBatchEndpoint(application.BackendLoader(), application.ApplicationConfig()))
+	app.Post("/batches/:batch_id/cancel", openai.CancelBatchEndpoint(application.BackendLoader(), application.ApplicationConfig()))
+
 	// completion
 	app.Post("/v1/completions",
-		openai.CompletionEndpoint(
+		modelRouter.Route(openai.CompletionEndpoint(
 			application.BackendLoader(),
 			application.ModelLoader(),
 			application.TemplatesEvaluator(),
 			application.ApplicationConfig(),
-		),
+		)),
 	)
 
 	app.Post("/completions",
-		openai.CompletionEndpoint(
+		modelRouter.Route(openai.CompletionEndpoint(
 			application.BackendLoader(),
 			application.ModelLoader(),
 			application.TemplatesEvaluator(),
 			application.ApplicationConfig(),
-		),
+		)),
 	)
 
 	app.Post("/v1/engines/:model/completions",
-		openai.CompletionEndpoint(
+		modelRouter.Route(openai.CompletionEndpoint(
 			application.BackendLoader(),
 			application.ModelLoader(),
 			application.TemplatesEvaluator(),
 			application.ApplicationConfig(),
-		),
+		)),
 	)
 
 	// embeddings
-	app.Post("/v1/embeddings", openai.EmbeddingsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
-	app.Post("/embeddings", openai.EmbeddingsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
-	app.Post("/v1/engines/:model/embeddings", openai.EmbeddingsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/embeddings", modelRouter.Route(openai.EmbeddingsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
+	app.Post("/embeddings", modelRouter.Route(openai.EmbeddingsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
+	app.Post("/v1/engines/:model/embeddings", modelRouter.Route(openai.EmbeddingsEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
 
 	// audio
-	app.Post("/v1/audio/transcriptions", openai.TranscriptEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
-	app.Post("/v1/audio/speech", localai.TTSEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/audio/transcriptions", modelRouter.Route(openai.TranscriptEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
+	app.Post("/v1/audio/translations", modelRouter.Route(openai.TranslationEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
+	app.Post("/v1/audio/speech", modelRouter.Route(localai.TTSEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
+
+	// realtime
+	app.Get("/v1/realtime", openai.RealtimeEndpoint(application.BackendLoader(), application.ModelLoader(), application.TemplatesEvaluator(), application.ApplicationConfig()))
 
 	// images
-	app.Post("/v1/images/generations", openai.ImageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig()))
+	app.Post("/v1/images/generations", modelRouter.Route(openai.ImageEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
+	app.Post("/v1/images/edits", modelRouter.Route(openai.ImageEditEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
+	app.Post("/v1/images/variations", modelRouter.Route(openai.ImageVariationEndpoint(application.BackendLoader(), application.ModelLoader(), application.ApplicationConfig())))
 
 	if application.ApplicationConfig().ImageDir != "" {
 		app.Static("/generated-images", application.ApplicationConfig().ImageDir)
@@ -0,0 +1,120 @@
+package schema
+
+import "encoding/json"
+
+// AnthropicRequest is the request of the Anthropic Messages API https://docs.anthropic.com/en/api/messages
+type AnthropicRequest struct {
+	Model    string             `json:"model"`
+	Messages []AnthropicMessage `json:"messages"`
+	// System is either a string or a list of text blocks
+	System        interface{}            `json:"system"`
+	MaxTokens     int                    `json:"max_tokens"`
+	Stream        bool                   `json:"stream"`
+	Temperature   *float64               `json:"temperature"`
+	TopP          *float64               `json:"top_p"`
+	TopK          *int                   `json:"top_k"`
+	StopSequences []string               `json:"stop_sequences"`
+	Tools         []AnthropicTool        `json:"tools"`
+	ToolChoice    *AnthropicToolChoice   `json:"tool_choice"`
+	Metadata      map[string]interface{} `json:"metadata"`
+}
+
+type AnthropicMessage struct {
+	Role string `json:"role"`
+	// Content is either a string or a list of content blocks
+	Content interface{} `json:"content"`
+}
+
+// AnthropicContentBlock is a block of a message, the fields in use depend on its type:
+// text, image, tool_use or tool_result
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+
+	Text   string                `json:"text,omitempty"`
+	Source *AnthropicImageSource `json:"source,omitempty"`
+
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	// Content of the tool result, either a string or a list of content blocks
+	Content interface{} `json:"content,omitempty"`
+	IsError bool        `json:"is_error,omitempty"`
+}
+
+// MarshalJSON always includes the text of the text blocks, as the clients expect it even when empty
+func (b AnthropicContentBlock) MarshalJSON() ([]byte, error) {
+	type block AnthropicContentBlock
+	if b.Type != "text" {
+		return json.Marshal(block(b))
+	}
+	return json.Marshal(struct {
+		block
+		Text string `json:"text"`
+	}{block(b), b.Text})
+}
+
+type AnthropicImageSource struct {
+	// Type is either base64 or url
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type AnthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+type AnthropicToolChoice struct {
+	// Type is one of auto, any, tool or none
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type AnthropicResponse struct {
+	ID           string                  `json:"id"`
+	Type         string                  `json:"type"`
+	Role         string                  `json:"role"`
+	Model        string                  `json:"model"`
+	Content      []AnthropicContentBlock `json:"content"`
+	StopReason   *string                 `json:"stop_reason"`
+	StopSequence *string                 `json:"stop_sequence"`
+	Usage        AnthropicUsage          `json:"usage"`
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// AnthropicStreamEvent is an event of a streamed response, the fields in use depend on its type
+type AnthropicStreamEvent struct {
+	Type         string                 `json:"type"`
+	Message      *AnthropicResponse     `json:"message,omitempty"`
+	Index        *int                   `json:"index,omitempty"`
+	ContentBlock *AnthropicContentBlock `json:"content_block,omitempty"`
+	Delta        *AnthropicDelta        `json:"delta,omitempty"`
+	Usage        *AnthropicUsage        `json:"usage,omitempty"`
+	Error        *AnthropicError        `json:"error,omitempty"`
+}
+
+type AnthropicDelta struct {
+	Type        string `json:"type,omitempty"`
+	Text        string `json:"text,omitempty"`
+	PartialJSON string `json:"partial_json,omitempty"`
+	StopReason  string `json:"stop_reason,omitempty"`
+}
+
+type AnthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type AnthropicErrorResponse struct {
+	Type  string         `json:"type"`
+	Error AnthropicError `json:"error"`
+}
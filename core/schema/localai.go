@@ -2,6 +2,8 @@ package schema
 
 import (
 	"github.com/mudler/LocalAI/core/p2p"
+	"github.com/mudler/LocalAI/pkg/grpc/proto"
+	"github.com/mudler/LocalAI/pkg/model"
 	gopsutil "github.com/shirou/gopsutil/v3/process"
 )
 
@@ -19,6 +21,14 @@ type BackendMonitorResponse struct {
 	CPUPercent    float64
 }
 
+// BackendMonitorStatus is the status reported by a loaded backend, along with the queue of the model
+// and the crashes of its backend
+type BackendMonitorStatus struct {
+	*proto.StatusResponse
+	Queue   *model.QueueStatus `json:"queue,omitempty"`
+	Crashes *model.CrashState  `json:"crashes,omitempty"`
+}
+
 type GalleryResponse struct {
 	ID        string `json:"uuid"`
 	StatusURL string `json:"status"`
@@ -83,10 +93,49 @@ type P2PNodesResponse struct {
 }
 
 type SysInfoModel struct {
-	ID string `json:"id"`
+	ID     string `json:"id"`
+	Pinned bool   `json:"pinned,omitempty"`
 }
 
 type SystemInformationResponse struct {
-	Backends []string       `json:"backends"`
-	Models   []SysInfoModel `json:"loaded_models"`
+	Backends []string                    `json:"backends"`
+	Models   []SysInfoModel              `json:"loaded_models"`
+	Crashes  map[string]model.CrashState `json:"crashes,omitempty"`
+	// Pinned lists the pinned models, including the ones not loaded yet
+	Pinned []string `json:"pinned,omitempty"`
+}
+
+const (
+	PreloadPending = "pending"
+	PreloadLoaded  = "loaded"
+	PreloadFailed  = "failed"
+)
+
+// ModelPreload reports the loading of a model at startup (LoadToMemory).
+// The error is not returned by the API, as it can hold the details of the host.
+type ModelPreload struct {
+	Model string `json:"model"`
+	State string `json:"state"`
+	Error string `json:"-"`
+}
+
+// ModelReadiness reports whether a model can serve requests
+type ModelReadiness struct {
+	Model        string   `json:"model"`
+	Ready        bool     `json:"ready"`
+	ConfigLoaded bool     `json:"config_loaded"`
+	MissingFiles []string `json:"missing_files,omitempty"`
+	// Loaded is true when the backend of the model is started, Healthy when it answers its health check or is busy
+	// serving requests. Error reports why the model is not ready, without the details of the failures, which are logged.
+	Loaded  bool   `json:"loaded"`
+	Healthy bool   `json:"healthy,omitempty"`
+	Preload string `json:"preload,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Ready    bool             `json:"ready"`
+	Draining bool             `json:"draining,omitempty"`
+	Preload  []ModelPreload   `json:"preload,omitempty"`
+	Models   []ModelReadiness `json:"models,omitempty"`
 }
@@ -0,0 +1,142 @@
+package schema
+
+import "time"
+
+// OllamaOptions are the model parameters of the Ollama API https://github.com/ollama/ollama/blob/main/docs/api.md
+type OllamaOptions struct {
+	Temperature   *float64 `json:"temperature"`
+	TopP          *float64 `json:"top_p"`
+	TopK          *int     `json:"top_k"`
+	NumPredict    *int     `json:"num_predict"`
+	Stop          []string `json:"stop"`
+	Seed          *int     `json:"seed"`
+	RepeatPenalty float64  `json:"repeat_penalty"`
+}
+
+type OllamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images are base64 encoded
+	Images []string `json:"images,omitempty"`
+}
+
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Stream   *bool           `json:"stream"`
+	// Format is either "json" or a JSON schema
+	Format  interface{}    `json:"format"`
+	Options *OllamaOptions `json:"options"`
+}
+
+type OllamaGenerateRequest struct {
+	Model  string   `json:"model"`
+	Prompt string   `json:"prompt"`
+	System string   `json:"system"`
+	Images []string `json:"images"`
+	// Raw disables the prompt template
+	Raw     bool           `json:"raw"`
+	Stream  *bool          `json:"stream"`
+	Format  interface{}    `json:"format"`
+	Options *OllamaOptions `json:"options"`
+}
+
+// OllamaMetrics are the timings (in nanoseconds) and token counts of a generation
+type OllamaMetrics struct {
+	TotalDuration      int64 `json:"total_duration,omitempty"`
+	PromptEvalCount    int   `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration int64 `json:"prompt_eval_duration,omitempty"`
+	EvalCount          int   `json:"eval_count,omitempty"`
+	EvalDuration       int64 `json:"eval_duration,omitempty"`
+}
+
+type OllamaChatResponse struct {
+	Model      string        `json:"model"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Message    OllamaMessage `json:"message"`
+	Done       bool          `json:"done"`
+	DoneReason string        `json:"done_reason,omitempty"`
+	OllamaMetrics
+}
+
+type OllamaGenerateResponse struct {
+	Model      string    `json:"model"`
+	CreatedAt  time.Time `json:"created_at"`
+	Response   string    `json:"response"`
+	Done       bool      `json:"done"`
+	DoneReason string    `json:"done_reason,omitempty"`
+	OllamaMetrics
+}
+
+type OllamaEmbedRequest struct {
+	Model string `json:"model"`
+	// Input is either a string or a list of strings
+	Input interface{} `json:"input"`
+	// Prompt is the input of the legacy embeddings endpoint
+	Prompt string `json:"prompt"`
+}
+
+type OllamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count,omitempty"`
+}
+
+type OllamaEmbeddingsResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+type OllamaShowRequest struct {
+	Model string `json:"model"`
+	// Name is the deprecated alias of Model
+	Name string `json:"name"`
+}
+
+type OllamaShowResponse struct {
+	Modelfile    string                 `json:"modelfile"`
+	Parameters   string                 `json:"parameters"`
+	Template     string                 `json:"template"`
+	System       string                 `json:"system,omitempty"`
+	Details      OllamaModelDetails     `json:"details"`
+	ModelInfo    map[string]interface{} `json:"model_info"`
+	Capabilities []string               `json:"capabilities"`
+}
+
+type OllamaPullRequest struct {
+	Model string `json:"model"`
+	// Name is the deprecated alias of Model
+	Name   string `json:"name"`
+	Stream *bool  `json:"stream"`
+}
+
+type OllamaProgressResponse struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+type OllamaErrorResponse struct {
+	Error string `json:"error"`
+}
@@ -29,9 +29,10 @@ type OpenAIUsage struct {
 }
 
 type Item struct {
-	Embedding []float32 `json:"embedding"`
-	Index     int       `json:"index"`
-	Object    string    `json:"object,omitempty"`
+	// Embedding is a []float32, or a base64 string with the base64 encoding format
+	Embedding interface{} `json:"embedding,omitempty"`
+	Index     int         `json:"index"`
+	Object    string      `json:"object,omitempty"`
 
 	// Images
 	URL     string `json:"url,omitempty"`
@@ -49,12 +50,42 @@ type OpenAIResponse struct {
 	Usage OpenAIUsage `json:"usage"`
 }
 
+type StreamOptions struct {
+	// IncludeUsage adds a last chunk to the stream with the usage of the whole request
+	IncludeUsage bool `json:"include_usage" yaml:"include_usage"`
+}
+
 type Choice struct {
-	Index        int      `json:"index"`
-	FinishReason string   `json:"finish_reason"`
-	Message      *Message `json:"message,omitempty"`
-	Delta        *Message `json:"delta,omitempty"`
-	Text         string   `json:"text,omitempty"`
+	Index        int       `json:"index"`
+	FinishReason string    `json:"finish_reason"`
+	Message      *Message  `json:"message,omitempty"`
+	Delta        *Message  `json:"delta,omitempty"`
+	Text         string    `json:"text,omitempty"`
+	Logprobs     *Logprobs `json:"logprobs,omitempty"`
+}
+
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+type LogprobContent struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// Logprobs are the log probabilities of the tokens of a choice.
+// Chat completions use Content, completions use the legacy format (Tokens, TokenLogprobs, TopLogprobs and TextOffset)
+type Logprobs struct {
+	Content []LogprobContent `json:"content,omitempty"`
+
+	Tokens        []string             `json:"tokens,omitempty"`
+	TokenLogprobs []float64            `json:"token_logprobs,omitempty"`
+	TopLogprobs   []map[string]float64 `json:"top_logprobs,omitempty"`
+	TextOffset    []int                `json:"text_offset,omitempty"`
 }
 
 type Content struct {
@@ -171,6 +202,8 @@ type OpenAIRequest struct {
 	Size string `json:"size"`
 	// Prompt is read only by completion/image API calls
 	Prompt interface{} `json:"prompt" yaml:"prompt"`
+	// Suffix is the text that comes after the completion, for fill-in-the-middle
+	Suffix string `json:"suffix" yaml:"suffix"`
 
 	// Edit endpoint
 	Instruction string      `json:"instruction" yaml:"instruction"`
@@ -187,8 +220,17 @@ type OpenAIRequest struct {
 
 	Tools       []functions.Tool `json:"tools,omitempty" yaml:"tools"`
 	ToolsChoice interface{}      `json:"tool_choice,omitempty" yaml:"tool_choice"`
+	// ParallelToolCalls allows the LLM to call more than one tool in the same response
+	ParallelToolCalls *bool `json:"parallel_tool_calls,omitempty" yaml:"parallel_tool_calls"`
 
 	Stream bool `json:"stream"`
+	// StreamOptions are the options of streamed responses
+	StreamOptions *StreamOptions `json:"stream_options,omitempty" yaml:"stream_options"`
+
+	// Log probabilities of the output tokens: a boolean for chat completions,
+	// the number of most likely tokens to return for each position for completions
+	Logprobs    interface{} `json:"logprobs,omitempty" yaml:"logprobs"`
+	TopLogprobs *int        `json:"top_logprobs,omitempty" yaml:"top_logprobs"`
 
 	// Image (not supported by OpenAI)
 	Mode int `json:"mode"`
@@ -49,4 +49,10 @@ type PredictionOptions struct {
 
 	// RWKV (?)
 	Tokenizer string `json:"tokenizer" yaml:"tokenizer"`
+
+	// Embeddings, part of the OpenAI spec
+	// EncodingFormat is either float (default) or base64
+	EncodingFormat string `json:"encoding_format" yaml:"encoding_format"`
+	// Dimensions truncates the embeddings (for models trained with Matryoshka representation learning)
+	Dimensions int `json:"dimensions" yaml:"dimensions"`
 }
@@ -0,0 +1,101 @@
+package schema
+
+// RealtimeSession is the configuration of a realtime session https://platform.openai.com/docs/api-reference/realtime-sessions
+type RealtimeSession struct {
+	ID                      string                           `json:"id,omitempty"`
+	Object                  string                           `json:"object,omitempty"`
+	Model                   string                           `json:"model,omitempty"`
+	Modalities              []string                         `json:"modalities,omitempty"`
+	Instructions            string                           `json:"instructions,omitempty"`
+	Voice                   string                           `json:"voice,omitempty"`
+	InputAudioFormat        string                           `json:"input_audio_format,omitempty"`
+	OutputAudioFormat       string                           `json:"output_audio_format,omitempty"`
+	InputAudioTranscription *RealtimeInputAudioTranscription `json:"input_audio_transcription,omitempty"`
+	TurnDetection           *RealtimeTurnDetection           `json:"turn_detection"`
+	Temperature             *float64                         `json:"temperature,omitempty"`
+}
+
+type RealtimeInputAudioTranscription struct {
+	Model    string `json:"model,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// RealtimeTurnDetection configures the detection of the end of the user turn, "server_vad" is the only supported type
+type RealtimeTurnDetection struct {
+	Type              string `json:"type"`
+	PrefixPaddingMs   int    `json:"prefix_padding_ms,omitempty"`
+	SilenceDurationMs int    `json:"silence_duration_ms,omitempty"`
+	CreateResponse    *bool  `json:"create_response,omitempty"`
+}
+
+// RealtimeItem is an item of the conversation
+type RealtimeItem struct {
+	ID      string            `json:"id"`
+	Object  string            `json:"object"`
+	Type    string            `json:"type"`
+	Status  string            `json:"status,omitempty"`
+	Role    string            `json:"role,omitempty"`
+	Content []RealtimeContent `json:"content"`
+}
+
+type RealtimeContent struct {
+	Type       string `json:"type"`
+	Text       string `json:"text,omitempty"`
+	Audio      string `json:"audio,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+}
+
+// Text returns the text of the item, using the transcript of the audio content
+func (i RealtimeItem) Text() string {
+	text := ""
+	for _, c := range i.Content {
+		switch {
+		case c.Text != "":
+			text += c.Text
+		case c.Transcript != "":
+			text += c.Transcript
+		}
+	}
+	return text
+}
+
+type RealtimeResponse struct {
+	ID     string         `json:"id"`
+	Object string         `json:"object"`
+	Status string         `json:"status"`
+	Output []RealtimeItem `json:"output"`
+	Usage  *RealtimeUsage `json:"usage,omitempty"`
+}
+
+type RealtimeUsage struct {
+	TotalTokens  int `json:"total_tokens"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type RealtimeError struct {
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	EventID string `json:"event_id,omitempty"`
+}
+
+// RealtimeEvent is an event sent by the client or the server over the realtime WebSocket,
+// the fields in use depend on the type of the event https://platform.openai.com/docs/api-reference/realtime-client-events
+type RealtimeEvent struct {
+	EventID        string            `json:"event_id,omitempty"`
+	Type           string            `json:"type"`
+	Session        *RealtimeSession  `json:"session,omitempty"`
+	Item           *RealtimeItem     `json:"item,omitempty"`
+	ItemID         string            `json:"item_id,omitempty"`
+	PreviousItemID string            `json:"previous_item_id,omitempty"`
+	Response       *RealtimeResponse `json:"response,omitempty"`
+	ResponseID     string            `json:"response_id,omitempty"`
+	Audio          string            `json:"audio,omitempty"`
+	Delta          string            `json:"delta,omitempty"`
+	Text           string            `json:"text,omitempty"`
+	Transcript     string            `json:"transcript,omitempty"`
+	AudioStartMs   int               `json:"audio_start_ms,omitempty"`
+	AudioEndMs     int               `json:"audio_end_ms,omitempty"`
+	Error          *RealtimeError    `json:"error,omitempty"`
+}
@@ -2,15 +2,50 @@ package schema
 
 import "time"
 
+type Word struct {
+	Word  string        `json:"word"`
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+}
+
 type Segment struct {
 	Id     int           `json:"id"`
 	Start  time.Duration `json:"start"`
 	End    time.Duration `json:"end"`
 	Text   string        `json:"text"`
 	Tokens []int         `json:"tokens"`
+	Words  []Word        `json:"words,omitempty"`
 }
 
 type TranscriptionResult struct {
-	Segments []Segment `json:"segments"`
-	Text     string    `json:"text"`
+	Segments []Segment     `json:"segments"`
+	Text     string        `json:"text"`
+	Language string        `json:"language,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// VerboseTranscriptionWord is a word of the OpenAI verbose_json response format, times are in seconds
+type VerboseTranscriptionWord struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// VerboseTranscriptionSegment is a segment of the OpenAI verbose_json response format, times are in seconds
+type VerboseTranscriptionSegment struct {
+	Id     int     `json:"id"`
+	Start  float64 `json:"start"`
+	End    float64 `json:"end"`
+	Text   string  `json:"text"`
+	Tokens []int   `json:"tokens"`
+}
+
+// VerboseTranscription is the OpenAI verbose_json response format of transcriptions and translations
+type VerboseTranscription struct {
+	Task     string                        `json:"task"`
+	Language string                        `json:"language"`
+	Duration float64                       `json:"duration"`
+	Text     string                        `json:"text"`
+	Segments []VerboseTranscriptionSegment `json:"segments,omitempty"`
+	Words    []VerboseTranscriptionWord    `json:"words,omitempty"`
 }
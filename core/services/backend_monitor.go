@@ -3,7 +3,6 @@ package services
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/mudler/LocalAI/core/config"
 	"github.com/mudler/LocalAI/core/schema"
@@ -31,33 +30,19 @@ func NewBackendMonitorService(modelLoader *model.ModelLoader, configLoader *conf
 
 func (bms BackendMonitorService) getModelLoaderIDFromModelName(modelName string) (string, error) {
 	config, exists := bms.backendConfigLoader.GetBackendConfig(modelName)
-	var backendId string
-	if exists {
-		backendId = config.Model
-	} else {
-		// Last ditch effort: use it raw, see if a backend happens to match.
-		backendId = modelName
-	}
-
-	if !strings.HasSuffix(backendId, ".bin") {
-		backendId = fmt.Sprintf("%s.bin", backendId)
+	if exists && config.Name != "" {
+		// models are loaded with the name of their configuration
+		return config.Name, nil
 	}
 
-	return backendId, nil
+	// Last ditch effort: use it raw, see if a backend happens to match.
+	return modelName, nil
 }
 
 func (bms *BackendMonitorService) SampleLocalBackendProcess(model string) (*schema.BackendMonitorResponse, error) {
-	config, exists := bms.backendConfigLoader.GetBackendConfig(model)
-	var backend string
-	if exists {
-		backend = config.Model
-	} else {
-		// Last ditch effort: use it raw, see if a backend happens to match.
-		backend = model
-	}
-
-	if !strings.HasSuffix(backend, ".bin") {
-		backend = fmt.Sprintf("%s.bin", backend)
+	backend, err := bms.getModelLoaderIDFromModelName(model)
+	if err != nil {
+		return nil, err
 	}
 
 	pid, err := bms.modelLoader.GetGRPCPID(backend)
@@ -101,16 +86,31 @@ func (bms *BackendMonitorService) SampleLocalBackendProcess(model string) (*sche
 	}, nil
 }
 
-func (bms BackendMonitorService) CheckAndSample(modelName string) (*proto.StatusResponse, error) {
+func (bms BackendMonitorService) CheckAndSample(modelName string) (*schema.BackendMonitorStatus, error) {
 	backendId, err := bms.getModelLoaderIDFromModelName(modelName)
 	if err != nil {
 		return nil, err
 	}
+	// checking the model records the crash of its backend, if it is not running anymore
 	modelAddr := bms.modelLoader.CheckIsLoaded(backendId)
+
+	result := &schema.BackendMonitorStatus{}
+	if crashes, exists := bms.modelLoader.CrashState(backendId); exists {
+		result.Crashes = &crashes
+	}
+
 	if modelAddr == nil {
+		// the crashes explain why the backend is not loaded
+		if result.Crashes != nil {
+			return result, nil
+		}
 		return nil, fmt.Errorf("backend %s is not currently loaded", backendId)
 	}
 
+	if queue, exists := bms.modelLoader.QueueStatus(backendId); exists {
+		result.Queue = &queue
+	}
+
 	status, rpcErr := modelAddr.GRPC(false, nil).Status(context.TODO())
 	if rpcErr != nil {
 		log.Warn().Msgf("backend %s experienced an error retrieving status info: %s", backendId, rpcErr.Error())
@@ -118,7 +118,7 @@ func (bms BackendMonitorService) CheckAndSample(modelName string) (*proto.Status
 		if slbErr != nil {
 			return nil, fmt.Errorf("backend %s experienced an error retrieving status info via rpc: %s, then failed local node process sample: %s", backendId, rpcErr.Error(), slbErr.Error())
 		}
-		return &proto.StatusResponse{
+		result.StatusResponse = &proto.StatusResponse{
 			State: proto.StatusResponse_ERROR,
 			Memory: &proto.MemoryUsageData{
 				Total: val.MemoryInfo.VMS,
@@ -126,9 +126,34 @@ func (bms BackendMonitorService) CheckAndSample(modelName string) (*proto.Status
 					"gopsutil-RSS": val.MemoryInfo.RSS,
 				},
 			},
-		}, nil
+		}
+		return result, nil
+	}
+	result.StatusResponse = status
+	return result, nil
+}
+
+// ResetModel forgets the crashes of the backend of the model, so it can be started again right away
+func (bms BackendMonitorService) ResetModel(modelName string) error {
+	backendId, err := bms.getModelLoaderIDFromModelName(modelName)
+	if err != nil {
+		return err
+	}
+	return bms.modelLoader.ResetCrashes(backendId)
+}
+
+// PinModel keeps the model loaded, or lets it be stopped again by the watchdog and the eviction
+func (bms BackendMonitorService) PinModel(modelName string, pinned bool) error {
+	backendId, err := bms.getModelLoaderIDFromModelName(modelName)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		bms.modelLoader.Pin(backendId)
+	} else {
+		bms.modelLoader.Unpin(backendId)
 	}
-	return status, nil
+	return nil
 }
 
 func (bms BackendMonitorService) ShutdownModel(modelName string) error {
@@ -425,6 +425,17 @@ var _ = Describe("JSON schema grammar tests", func() {
 			Expect(len(results)).To(Equal(len(strings.Split(grammar, "\n"))), grammar)
 		})
 
+		It("requires a function call", func() {
+			structuredGrammar := JSONFunctionStructure{
+				OneOf: testFunctionsName}
+			grammar, err := structuredGrammar.Grammar(EnableMaybeString, EnableMaybeArray, DisableParallelNewLines, RequireFunctionCall)
+			Expect(err).To(BeNil())
+			Expect(grammar).To(ContainSubstring("root ::= arr | realvalue"))
+			Expect(grammar).ToNot(ContainSubstring("root ::= mixedstring"))
+			Expect(grammar).ToNot(ContainSubstring(`)? "]"`))
+			Expect(grammar).To(ContainSubstring(`) "]"`))
+		})
+
 		It("generates parallel tools without newlines in JSON", func() {
 			structuredGrammar := JSONFunctionStructure{
 				OneOf: testFunctionsName}
@@ -8,6 +8,7 @@ type GrammarOption struct {
 	MaybeString             bool
 	NoMixedFreeString       bool
 	ExpectStringsAfterJSON  bool
+	RequireFunctionCall     bool
 
 	FunctionName string
 	SchemaType   SchemaConverterType
@@ -40,6 +41,12 @@ var ExpectStringsAfterJSON func(*GrammarOption) = func(o *GrammarOption) {
 	o.ExpectStringsAfterJSON = true
 }
 
+// RequireFunctionCall forces the LLM to call at least a function, disabling free strings and empty arrays
+var RequireFunctionCall func(*GrammarOption) = func(o *GrammarOption) {
+	o.MaybeString = false
+	o.RequireFunctionCall = true
+}
+
 func SetPrefix(suffix string) func(*GrammarOption) {
 	return func(o *GrammarOption) {
 		o.Prefix = suffix
@@ -69,11 +69,15 @@ func (rules Rules) ToGrammar(options ...func(*GrammarOption)) string {
 	}
 
 	lines = append(lines, fmt.Sprintf("%s ::= %s", "root", newRoot))
+	arr := arrayNewLines
 	if disableParallelNewLines {
-		lines = append(lines, array)
-	} else {
-		lines = append(lines, arrayNewLines)
+		arr = array
+	}
+	if grammarOpts.RequireFunctionCall {
+		// the array must contain at least a function call
+		arr = strings.Replace(arr, `)? "]"`, `) "]"`, 1)
 	}
+	lines = append(lines, arr)
 
 	if maybeArray {
 		if grammarOpts.ExpectStringsAfterJSON {
@@ -0,0 +1,202 @@
+package functions
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// PartialFuncCallResults is a function call extracted from an LLM output which might still be generated
+type PartialFuncCallResults struct {
+	Name string
+	// Arguments is the raw JSON of the arguments generated so far
+	Arguments string
+	// ArgumentsComplete is true once the JSON of the arguments is complete
+	ArgumentsComplete bool
+}
+
+// SupportsPartialParsing returns true if the function calls can be parsed while the LLM output is generated.
+// Regexes and replacements need the whole output, so they can't be applied to partial results.
+func (g FunctionsConfig) SupportsPartialParsing() bool {
+	return len(g.ResponseRegex) == 0 && len(g.JSONRegexMatch) == 0 &&
+		len(g.ReplaceFunctionResults) == 0 && len(g.ReplaceLLMResult) == 0
+}
+
+// ParsePartialFunctionCall extracts the function calls from a partial LLM output, so they can be streamed while
+// the output is generated. A call is returned once its name is complete, the arguments are the raw JSON text
+// generated so far: as the output grows, the arguments of the same call only grow too.
+func ParsePartialFunctionCall(llmresult string, functionConfig FunctionsConfig) []PartialFuncCallResults {
+	functionNameKey := defaultFunctionNameKey
+	functionArgumentsKey := defaultFunctionArgumentsKey
+	if functionConfig.FunctionNameKey != "" {
+		functionNameKey = functionConfig.FunctionNameKey
+	}
+	if functionConfig.FunctionArgumentsKey != "" {
+		functionArgumentsKey = functionConfig.FunctionArgumentsKey
+	}
+
+	results := []PartialFuncCallResults{}
+	p := &partialParser{s: llmresult}
+	for {
+		start := strings.IndexByte(p.s[p.i:], '{')
+		if start < 0 {
+			return results
+		}
+		p.i += start + 1
+
+		call, complete := p.object(functionNameKey, functionArgumentsKey)
+		if call.Name != "" {
+			results = append(results, call)
+		}
+		if !complete {
+			return results
+		}
+	}
+}
+
+// partialParser scans JSON objects which might be truncated
+type partialParser struct {
+	s string
+	i int
+}
+
+func (p *partialParser) skipSpaces() {
+	for p.i < len(p.s) && strings.IndexByte(" \t\r\n", p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// object scans the fields of an object, after its opening brace, looking for the function name and arguments
+func (p *partialParser) object(nameKey, argumentsKey string) (PartialFuncCallResults, bool) {
+	call := PartialFuncCallResults{}
+	for {
+		p.skipSpaces()
+		if p.i >= len(p.s) {
+			return call, false
+		}
+		if p.s[p.i] == '}' {
+			p.i++
+			return call, true
+		}
+		if p.s[p.i] != '"' {
+			// not a function call object, skip it
+			return PartialFuncCallResults{}, p.skipValue('{')
+		}
+
+		key, complete := p.string()
+		if !complete {
+			return call, false
+		}
+		p.skipSpaces()
+		if p.i >= len(p.s) || p.s[p.i] != ':' {
+			return call, false
+		}
+		p.i++
+		p.skipSpaces()
+		if p.i >= len(p.s) {
+			return call, false
+		}
+
+		start := p.i
+		switch key {
+		case nameKey:
+			if p.s[p.i] != '"' {
+				// not a function call object, skip it
+				if !p.value() {
+					return PartialFuncCallResults{}, false
+				}
+				return PartialFuncCallResults{}, p.skipValue('{')
+			}
+			name, complete := p.string()
+			if !complete {
+				return call, false
+			}
+			call.Name = name
+		case argumentsKey:
+			complete := p.value()
+			call.Arguments = p.s[start:p.i]
+			call.ArgumentsComplete = complete
+			if !complete {
+				return call, false
+			}
+		default:
+			if !p.value() {
+				return call, false
+			}
+		}
+
+		p.skipSpaces()
+		if p.i >= len(p.s) {
+			return call, false
+		}
+		if p.s[p.i] == ',' {
+			p.i++
+		}
+	}
+}
+
+// string scans a string and returns its decoded value
+func (p *partialParser) string() (string, bool) {
+	start := p.i
+	p.i++
+	for p.i < len(p.s) {
+		switch p.s[p.i] {
+		case '\\':
+			p.i += 2
+			continue
+		case '"':
+			p.i++
+			var value string
+			if err := json.Unmarshal([]byte(p.s[start:p.i]), &value); err != nil {
+				return "", true
+			}
+			return value, true
+		}
+		p.i++
+	}
+	p.i = len(p.s)
+	return "", false
+}
+
+// value skips a value, it returns false if the value is not complete
+func (p *partialParser) value() bool {
+	switch c := p.s[p.i]; c {
+	case '"':
+		_, complete := p.string()
+		return complete
+	case '{', '[':
+		p.i++
+		return p.skipValue(c)
+	default:
+		for p.i < len(p.s) && strings.IndexByte(",}] \t\r\n", p.s[p.i]) < 0 {
+			p.i++
+		}
+		return p.i < len(p.s)
+	}
+}
+
+// skipValue skips the rest of an object or array, after its opening character
+func (p *partialParser) skipValue(open byte) bool {
+	closing := map[byte]byte{'{': '}', '[': ']'}
+	stack := []byte{closing[open]}
+	for p.i < len(p.s) {
+		switch c := p.s[p.i]; c {
+		case '"':
+			if _, complete := p.string(); !complete {
+				return false
+			}
+			continue
+		case '{', '[':
+			stack = append(stack, closing[c])
+		case '}', ']':
+			if c == stack[len(stack)-1] {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				p.i++
+				return true
+			}
+		}
+		p.i++
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package functions_test
+
+import (
+	"encoding/json"
+
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParsePartialFunctionCall()", func() {
+	var functionConfig FunctionsConfig
+
+	BeforeEach(func() {
+		functionConfig = FunctionsConfig{}
+	})
+
+	It("parses the complete function calls like ParseFunctionCall", func() {
+		input := `[{"name": "add", "arguments": {"x": 5, "y": "a}b"}}, {"arguments": {"q": [1, {"z": "]"}]}, "name": "search"}]`
+
+		results := ParsePartialFunctionCall(input, functionConfig)
+		Expect(results).To(HaveLen(2))
+		Expect(results[0]).To(Equal(PartialFuncCallResults{Name: "add", Arguments: `{"x": 5, "y": "a}b"}`, ArgumentsComplete: true}))
+		Expect(results[1]).To(Equal(PartialFuncCallResults{Name: "search", Arguments: `{"q": [1, {"z": "]"}]}`, ArgumentsComplete: true}))
+
+		for i, r := range ParseFunctionCall(input, functionConfig) {
+			Expect(r.Name).To(Equal(results[i].Name))
+			Expect(r.Arguments).To(MatchJSON(results[i].Arguments))
+		}
+	})
+
+	It("returns growing arguments while the output is generated", func() {
+		input := `{"name": "add", "arguments": {"x": 5, "text": "hello \"world\""}}`
+
+		arguments := ""
+		for i := range input {
+			results := ParsePartialFunctionCall(input[:i], functionConfig)
+			if len(results) == 0 {
+				Expect(arguments).To(BeEmpty())
+				continue
+			}
+			Expect(results).To(HaveLen(1))
+			Expect(results[0].Name).To(Equal("add"))
+			Expect(results[0].Arguments).To(HavePrefix(arguments))
+			arguments = results[0].Arguments
+		}
+		Expect(arguments).To(Equal(`{"x": 5, "text": "hello \"world\""}`))
+		Expect(json.Valid([]byte(arguments))).To(BeTrue())
+	})
+
+	It("waits for the name to be complete", func() {
+		Expect(ParsePartialFunctionCall(`{"name": "ad`, functionConfig)).To(BeEmpty())
+		Expect(ParsePartialFunctionCall(`{"name": "add"`, functionConfig)).To(Equal([]PartialFuncCallResults{{Name: "add"}}))
+	})
+
+	It("uses the configured keys", func() {
+		functionConfig.FunctionNameKey = "function"
+		results := ParsePartialFunctionCall(`I will call {"function": "add", "arguments": {"x": 1`, functionConfig)
+		Expect(results).To(Equal([]PartialFuncCallResults{{Name: "add", Arguments: `{"x": 1`}}))
+	})
+
+	It("skips objects which are not function calls", func() {
+		results := ParsePartialFunctionCall(`{"foo": {"name": "bar"}} {"name": 1, "arguments": {}} {"name": "add", "arguments": {}}`, functionConfig)
+		Expect(results).To(Equal([]PartialFuncCallResults{{Name: "add", Arguments: `{}`, ArgumentsComplete: true}}))
+	})
+
+	It("does not support regexes", func() {
+		Expect(functionConfig.SupportsPartialParsing()).To(BeTrue())
+		functionConfig.ResponseRegex = []string{`(?P<name>\w+)\s*\((?P<arguments>.*)\)`}
+		Expect(functionConfig.SupportsPartialParsing()).To(BeFalse())
+	})
+})
@@ -0,0 +1,34 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ValidateJSON checks that the text is a JSON document matching the given JSON schema.
+// A nil schema only checks that the text is valid JSON.
+func ValidateJSON(text string, schema interface{}) error {
+	var document interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &document); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if schema == nil {
+		return nil
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(document))
+	if err != nil {
+		return fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	if !result.Valid() {
+		errs := []string{}
+		for _, e := range result.Errors() {
+			errs = append(errs, e.String())
+		}
+		return fmt.Errorf("the JSON does not match the schema: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package functions_test
+
+import (
+	. "github.com/mudler/LocalAI/pkg/functions"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateJSON()", func() {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{"type": "string"},
+			"age":  map[string]interface{}{"type": "integer"},
+		},
+		"required": []interface{}{"name", "age"},
+	}
+
+	It("accepts outputs matching the schema", func() {
+		Expect(ValidateJSON(`{"name": "John", "age": 30}`, schema)).To(Succeed())
+	})
+
+	It("rejects outputs not matching the schema", func() {
+		err := ValidateJSON(`{"name": "John", "age": "thirty"}`, schema)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("age"))
+
+		Expect(ValidateJSON(`{"name": "John"}`, schema)).ToNot(Succeed())
+	})
+
+	It("rejects truncated outputs", func() {
+		Expect(ValidateJSON(`{"name": "Jo`, schema)).ToNot(Succeed())
+		Expect(ValidateJSON(`{"name": "Jo`, nil)).ToNot(Succeed())
+	})
+
+	It("only checks the syntax without a schema", func() {
+		Expect(ValidateJSON(`[1, 2, 3]`, nil)).To(Succeed())
+	})
+})
@@ -0,0 +1,239 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	process "github.com/mudler/go-processmanager"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxRestartBackoff caps the exponential backoff between restarts
+	maxRestartBackoff = 5 * time.Minute
+	// crashResetTime is how long a model has to run to forget its previous crashes
+	crashResetTime = 10 * time.Minute
+	// stderrLines is the number of lines of the backend stderr kept after a crash
+	stderrLines = 20
+)
+
+var (
+	// ErrBackendRestarting is returned when loading a model which crashed recently
+	ErrBackendRestarting = errors.New("the model backend crashed, waiting before restarting it")
+	// ErrBackendFailed is returned when loading a model which crashed too many times
+	ErrBackendFailed = errors.New("the model backend crashed too many times")
+)
+
+const (
+	CrashStateBackoff    = "backoff"
+	CrashStateFailed     = "failed"
+	CrashStateRecovering = "recovering"
+)
+
+// CrashState reports the crashes of the backend of a model
+type CrashState struct {
+	// State is backoff while waiting to restart the backend, failed when it crashed too many times
+	// and recovering when it can be loaded again
+	State     string     `json:"state"`
+	Crashes   int        `json:"crashes"`
+	LastError string     `json:"last_error,omitempty"`
+	LastCrash time.Time  `json:"last_crash"`
+	RetryAt   *time.Time `json:"retry_at,omitempty"`
+	Stderr    []string   `json:"stderr,omitempty"`
+}
+
+type crashes struct {
+	sync.Mutex
+	maxCrashes int
+	backoff    time.Duration
+	models     map[string]*crashRecord
+	// backends which failed loading the model with their stderr, until the failure is recorded
+	failures map[string][]string
+}
+
+type crashRecord struct {
+	crashes   int
+	lastError string
+	lastCrash time.Time
+	loadedAt  time.Time
+	stderr    []string
+}
+
+// SetRestartPolicy sets how the backends which crash are restarted: the restarts are delayed with an
+// exponential backoff starting from backoff, and the model is marked as failed after maxCrashes crashes.
+// A backoff of 0 disables the backoff, maxCrashes of 0 never marks a model as failed.
+func (ml *ModelLoader) SetRestartPolicy(maxCrashes int, backoff time.Duration) {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+	ml.crashes.maxCrashes = maxCrashes
+	ml.crashes.backoff = backoff
+}
+
+// CrashState returns the crashes of the backend of a model, if it crashed
+func (ml *ModelLoader) CrashState(modelID string) (CrashState, bool) {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	r, exists := ml.crashes.models[modelID]
+	if !exists {
+		return CrashState{}, false
+	}
+	return ml.crashes.state(r), true
+}
+
+// CrashStates returns the crashes of the backends of all the models which crashed
+func (ml *ModelLoader) CrashStates() map[string]CrashState {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	states := map[string]CrashState{}
+	for id, r := range ml.crashes.models {
+		states[id] = ml.crashes.state(r)
+	}
+	return states
+}
+
+// ResetCrashes forgets the crashes of a model, so it can be loaded again right away
+func (ml *ModelLoader) ResetCrashes(modelID string) error {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	if _, exists := ml.crashes.models[modelID]; !exists {
+		return fmt.Errorf("model %s has not crashed", modelID)
+	}
+	delete(ml.crashes.models, modelID)
+	return nil
+}
+
+// checkCrashes returns an error if the model can't be loaded because of its previous crashes
+func (ml *ModelLoader) checkCrashes(modelID string) error {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	r, exists := ml.crashes.models[modelID]
+	if !exists {
+		return nil
+	}
+	state := ml.crashes.state(r)
+	switch state.State {
+	case CrashStateFailed:
+		return fmt.Errorf("%w: model %s crashed %d times (%s)", ErrBackendFailed, modelID, state.Crashes, state.LastError)
+	case CrashStateBackoff:
+		return fmt.Errorf("%w: model %s crashed %d times (%s), retrying in %s", ErrBackendRestarting, modelID, state.Crashes, state.LastError, time.Until(*state.RetryAt).Round(time.Second))
+	}
+	return nil
+}
+
+// recordLoad records that the model was loaded after crashing
+func (ml *ModelLoader) recordLoad(modelID string) {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	// another backend may have failed before the one which loaded the model
+	delete(ml.crashes.failures, modelID)
+	if r, exists := ml.crashes.models[modelID]; exists && r.loadedAt.IsZero() {
+		r.loadedAt = time.Now()
+	}
+}
+
+// recordBackendFailure records that the backend failed loading the model, with its stderr
+func (ml *ModelLoader) recordBackendFailure(modelID string, p *process.Process) {
+	stderr := stderrTail(p)
+
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	if ml.crashes.failures == nil {
+		ml.crashes.failures = make(map[string][]string)
+	}
+	ml.crashes.failures[modelID] = stderr
+}
+
+// backendFailure returns the stderr of the backend, if it failed loading the model, and forgets the failure
+func (ml *ModelLoader) backendFailure(modelID string) ([]string, bool) {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	stderr, failed := ml.crashes.failures[modelID]
+	delete(ml.crashes.failures, modelID)
+	return stderr, failed
+}
+
+// recordCrash records that the backend process of the model exited, or failed loading the model
+func (ml *ModelLoader) recordCrash(modelID string, err error, stderr []string) {
+	ml.crashes.Lock()
+	defer ml.crashes.Unlock()
+
+	if ml.crashes.models == nil {
+		ml.crashes.models = make(map[string]*crashRecord)
+	}
+	r, exists := ml.crashes.models[modelID]
+	if !exists {
+		r = &crashRecord{}
+		ml.crashes.models[modelID] = r
+	}
+
+	// the model ran fine for a while since its last crash
+	if !r.loadedAt.IsZero() && time.Since(r.loadedAt) > crashResetTime {
+		r.crashes = 0
+	}
+	r.crashes++
+	r.lastError = err.Error()
+	r.lastCrash = time.Now()
+	r.loadedAt = time.Time{}
+	if len(stderr) > 0 {
+		r.stderr = stderr
+	}
+
+	state := ml.crashes.state(r)
+	log.Warn().Str("model", modelID).Int("crashes", state.Crashes).Str("state", state.State).Msgf("backend crashed: %s", r.lastError)
+}
+
+// state must be called with the lock held
+func (c *crashes) state(r *crashRecord) CrashState {
+	state := CrashState{
+		State:     CrashStateRecovering,
+		Crashes:   r.crashes,
+		LastError: r.lastError,
+		LastCrash: r.lastCrash,
+		Stderr:    r.stderr,
+	}
+
+	if c.maxCrashes > 0 && r.crashes >= c.maxCrashes {
+		state.State = CrashStateFailed
+		return state
+	}
+
+	if c.backoff > 0 && r.loadedAt.IsZero() {
+		backoff := maxRestartBackoff
+		if r.crashes < 20 {
+			backoff = min(c.backoff*time.Duration(1<<(r.crashes-1)), maxRestartBackoff)
+		}
+		retryAt := r.lastCrash.Add(backoff)
+		state.RetryAt = &retryAt
+		if time.Now().Before(retryAt) {
+			state.State = CrashStateBackoff
+		}
+	}
+	return state
+}
+
+// stderrTail returns the last lines written by the process on stderr
+func stderrTail(p *process.Process) []string {
+	if p == nil {
+		return nil
+	}
+	data, err := os.ReadFile(p.StderrPath())
+	if err != nil || strings.TrimSpace(string(data)) == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > stderrLines {
+		lines = lines[len(lines)-stderrLines:]
+	}
+	return lines
+}
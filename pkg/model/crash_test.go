@@ -0,0 +1,114 @@
+package model_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backend crashes", func() {
+	var (
+		modelLoader *model.ModelLoader
+		assetDir    string
+	)
+
+	// load loads a model with a backend which exits right away, so it always fails
+	load := func(opts ...model.Option) error {
+		_, err := modelLoader.Load(append([]model.Option{
+			model.WithExternalBackend("failing", filepath.Join(assetDir, "failing.sh")),
+			model.WithBackendString("failing"),
+			model.WithModel("foo"),
+			model.WithModelID("foo"),
+			model.WithAssetDir(assetDir),
+			model.WithGRPCAttempts(1),
+			model.WithGRPCAttemptsDelay(0),
+		}, opts...)...)
+		return err
+	}
+
+	BeforeEach(func() {
+		var err error
+		assetDir, err = os.MkdirTemp("", "crashes")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(assetDir, "failing.sh"), []byte("#!/bin/sh\necho 'out of memory' >&2\nexit 1\n"), 0755)).To(Succeed())
+		modelLoader = model.NewModelLoader(assetDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(assetDir)
+	})
+
+	It("records the failures", func() {
+		Expect(load()).To(HaveOccurred())
+		Expect(load()).To(HaveOccurred())
+
+		state, exists := modelLoader.CrashState("foo")
+		Expect(exists).To(BeTrue())
+		Expect(state.State).To(Equal(model.CrashStateRecovering))
+		Expect(state.Crashes).To(Equal(2))
+		Expect(state.LastError).To(ContainSubstring("grpc service not ready"))
+		Expect(state.Stderr).To(ContainElement(ContainSubstring("out of memory")))
+		Expect(modelLoader.CrashStates()).To(HaveKey("foo"))
+	})
+
+	It("doesn't record the failures which are not crashes", func() {
+		// the backend doesn't exist
+		Expect(load(model.WithBackendString("missing-backend"))).To(HaveOccurred())
+		// the model has no configuration
+		Expect(load(model.WithoutCrashTracking())).To(HaveOccurred())
+		// the load was canceled
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		Expect(load(model.WithContext(ctx))).To(HaveOccurred())
+
+		_, exists := modelLoader.CrashState("foo")
+		Expect(exists).To(BeFalse())
+	})
+
+	It("reports that a backend which crashed recently is not available", func() {
+		modelLoader.SetRestartPolicy(0, time.Minute)
+
+		Expect(modelLoader.Available("foo")).To(Succeed())
+		Expect(load()).To(HaveOccurred())
+		Expect(modelLoader.Available("foo")).To(MatchError(model.ErrBackendRestarting))
+	})
+
+	It("waits before restarting a backend which crashed", func() {
+		modelLoader.SetRestartPolicy(0, 50*time.Millisecond)
+
+		Expect(load()).ToNot(MatchError(model.ErrBackendRestarting))
+		Expect(load()).To(MatchError(model.ErrBackendRestarting))
+
+		state, _ := modelLoader.CrashState("foo")
+		Expect(state.State).To(Equal(model.CrashStateBackoff))
+		Expect(state.Crashes).To(Equal(1))
+		Expect(state.RetryAt).ToNot(BeNil())
+
+		Eventually(load).ShouldNot(MatchError(model.ErrBackendRestarting))
+		// the backoff doubles
+		state, _ = modelLoader.CrashState("foo")
+		Expect(state.Crashes).To(Equal(2))
+		Expect(state.RetryAt.Sub(state.LastCrash)).To(Equal(100 * time.Millisecond))
+	})
+
+	It("marks the model as failed after too many crashes, until it is reset", func() {
+		modelLoader.SetRestartPolicy(2, 0)
+
+		Expect(load()).ToNot(MatchError(model.ErrBackendFailed))
+		Expect(load()).ToNot(MatchError(model.ErrBackendFailed))
+		Expect(load()).To(MatchError(model.ErrBackendFailed))
+
+		state, _ := modelLoader.CrashState("foo")
+		Expect(state.State).To(Equal(model.CrashStateFailed))
+
+		Expect(modelLoader.ResetCrashes("foo")).To(Succeed())
+		_, exists := modelLoader.CrashState("foo")
+		Expect(exists).To(BeFalse())
+		Expect(load()).ToNot(MatchError(model.ErrBackendFailed))
+	})
+})
@@ -15,3 +15,9 @@ func allExcept(s string) GRPCProcessFilter {
 		return id != s
 	}
 }
+
+func unpinned(ml *ModelLoader, filter GRPCProcessFilter) GRPCProcessFilter {
+	return func(id string, p *process.Process) bool {
+		return filter(id, p) && !ml.IsPinned(id)
+	}
+}
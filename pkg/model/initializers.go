@@ -349,7 +349,7 @@ func (ml *ModelLoader) grpcModel(backend string, autodetect bool, o *Options) fu
 					return nil, fmt.Errorf("failed allocating free ports: %s", err.Error())
 				}
 				// Make sure the process is executable
-				process, err := ml.startProcess(uri, modelID, serverAddress)
+				process, err := ml.startProcess(uri, modelID, serverAddress, o.limits)
 				if err != nil {
 					log.Error().Err(err).Str("path", uri).Msg("failed to launch ")
 					return nil, err
@@ -392,7 +392,7 @@ func (ml *ModelLoader) grpcModel(backend string, autodetect bool, o *Options) fu
 			args, grpcProcess = library.LoadLDSO(o.assetDir, args, grpcProcess)
 
 			// Make sure the process is executable in any circumstance
-			process, err := ml.startProcess(grpcProcess, modelID, serverAddress, args...)
+			process, err := ml.startProcess(grpcProcess, modelID, serverAddress, o.limits, args...)
 			if err != nil {
 				return nil, err
 			}
@@ -422,10 +422,11 @@ func (ml *ModelLoader) grpcModel(backend string, autodetect bool, o *Options) fu
 
 		if !ready {
 			log.Debug().Msgf("GRPC Service NOT ready")
+			err := fmt.Errorf("grpc service not ready")
 			if process := client.Process(); process != nil {
-				process.Stop()
+				err = ml.stopFailedBackend(modelID, process, err)
 			}
-			return nil, fmt.Errorf("grpc service not ready")
+			return nil, err
 		}
 
 		options := *o.gRPCOptions
@@ -437,16 +438,18 @@ func (ml *ModelLoader) grpcModel(backend string, autodetect bool, o *Options) fu
 
 		res, err := client.GRPC(o.parallelRequests, ml.wd).LoadModel(o.context, &options)
 		if err != nil {
+			err = fmt.Errorf("could not load model: %w", err)
 			if process := client.Process(); process != nil {
-				process.Stop()
+				err = ml.stopFailedBackend(modelID, process, err)
 			}
-			return nil, fmt.Errorf("could not load model: %w", err)
+			return nil, err
 		}
 		if !res.Success {
+			err := fmt.Errorf("could not load model (no success): %s", res.Message)
 			if process := client.Process(); process != nil {
-				process.Stop()
+				err = ml.stopFailedBackend(modelID, process, err)
 			}
-			return nil, fmt.Errorf("could not load model (no success): %s", res.Message)
+			return nil, err
 		}
 
 		return client, nil
@@ -503,19 +506,51 @@ func (ml *ModelLoader) backendLoader(opts ...Option) (client grpc.Backend, err e
 }
 
 func (ml *ModelLoader) stopActiveBackends(modelID string, singleActiveBackend bool) {
-	// If we can have only one backend active, kill all the others (except external backends)
+	// If we can have only one backend active, kill all the others (except external backends and pinned models)
 	if singleActiveBackend {
 		log.Debug().Msgf("Stopping all backends except '%s'", modelID)
-		err := ml.StopGRPC(allExcept(modelID))
+		err := ml.StopGRPC(unpinned(ml, allExcept(modelID)))
 		if err != nil {
 			log.Error().Err(err).Str("keptModel", modelID).Msg("error while shutting down all backends except for the keptModel - greedyloader continuing")
 		}
 	}
 }
 
+// LoadError is returned when a model can't be loaded
+type LoadError struct {
+	ModelID string
+	Err     error
+}
+
+func (e *LoadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
 func (ml *ModelLoader) Load(opts ...Option) (grpc.Backend, error) {
 	o := NewOptions(opts...)
 
+	backend, err := ml.load(o, opts...)
+	if err != nil {
+		// only the backends which exited or failed loading the model are crashes, not e.g. a missing
+		// backend or a canceled load
+		stderr, failed := ml.backendFailure(o.modelID)
+		if failed && !o.noCrashTracking && o.context.Err() == nil && !errors.Is(err, context.Canceled) {
+			ml.recordCrash(o.modelID, err, stderr)
+		}
+		return nil, &LoadError{ModelID: o.modelID, Err: err}
+	}
+	ml.recordLoad(o.modelID)
+	ml.pinByConfig(o.modelID, o.pinned)
+
+	return ml.queued(o.modelID, o.queue, backend), nil
+}
+
+func (ml *ModelLoader) load(o *Options, opts ...Option) (grpc.Backend, error) {
+
 	// Return earlier if we have a model already loaded
 	// (avoid looping through all the backends)
 	if m := ml.CheckIsLoaded(o.modelID); m != nil {
@@ -524,6 +559,11 @@ func (ml *ModelLoader) Load(opts ...Option) (grpc.Backend, error) {
 		return m.GRPC(o.parallelRequests, ml.wd), nil
 	}
 
+	// Don't restart the backends crashing in a loop
+	if err := ml.checkCrashes(o.modelID); err != nil {
+		return nil, err
+	}
+
 	ml.stopActiveBackends(o.modelID, o.singleActiveBackend)
 
 	if o.backendString != "" {
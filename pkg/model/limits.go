@@ -0,0 +1,114 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ProcessLimits caps the resources used by the backend process of a model
+type ProcessLimits struct {
+	// Memory is the memory the process can use in bytes, 0 means no limit.
+	// It is enforced with a cgroup v2, and not enforced if cgroup v2 is not available.
+	Memory uint64
+	// CPUs are the CPUs the process can run on, empty means any CPU
+	CPUs []int
+	// Nice is the nice level of the process, 0 keeps the one of LocalAI
+	Nice int
+	// MaxOpenFiles is the number of files the process can open, 0 means no limit
+	MaxOpenFiles uint64
+}
+
+// backendLimitsEnv holds the limits to apply to a backend process, when LocalAI runs itself in the process to
+// apply them before executing the backend
+const backendLimitsEnv = "LOCALAI_BACKEND_LIMITS"
+
+// launchLimits are the limits applied to a backend process before executing the backend
+type launchLimits struct {
+	// Cgroup is the cgroup capping the memory, joined by the process
+	Cgroup       string `json:"cgroup,omitempty"`
+	MaxOpenFiles uint64 `json:"max_open_files,omitempty"`
+	CPUs         []int  `json:"cpus,omitempty"`
+	Nice         int    `json:"nice,omitempty"`
+}
+
+// IsZero returns true if no limit is set
+func (l ProcessLimits) IsZero() bool {
+	return l.Memory == 0 && len(l.CPUs) == 0 && l.Nice == 0 && l.MaxOpenFiles == 0
+}
+
+// ParseCPUSet parses a list of CPUs in the cpuset format, e.g. 0-3,8
+func ParseCPUSet(s string) ([]int, error) {
+	cpus := []int{}
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(r, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid CPU %q in %q", first, s)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(last))
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid CPU range %q in %q", r, s)
+			}
+		}
+		for cpu := start; cpu <= end; cpu++ {
+			if !slices.Contains(cpus, cpu) {
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+	slices.Sort(cpus)
+	return cpus, nil
+}
+
+// cgroups tracks the cgroups created to cap the memory of the backends
+type cgroups struct {
+	sync.Mutex
+	// root is the cgroup holding the cgroups of the backends, set up on first use
+	root    string
+	rootErr error
+	once    sync.Once
+	// models are the cgroups of the backends, by model
+	models map[string]cgroup
+}
+
+type cgroup struct {
+	path string
+	// oomKills is the count of processes killed for exceeding the memory limit when the backend started
+	oomKills int
+}
+
+func (c *cgroups) set(modelID string, cg cgroup) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.models == nil {
+		c.models = make(map[string]cgroup)
+	}
+	c.models[modelID] = cg
+}
+
+func (c *cgroups) get(modelID string) (cgroup, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	cg, exists := c.models[modelID]
+	return cg, exists
+}
+
+func (c *cgroups) remove(modelID string) (cgroup, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	cg, exists := c.models[modelID]
+	delete(c.models, modelID)
+	return cg, exists
+}
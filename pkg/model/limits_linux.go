@@ -0,0 +1,218 @@
+//go:build linux
+// +build linux
+
+package model
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+const cgroupMountPoint = "/sys/fs/cgroup"
+
+var invalidCgroupChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+func init() {
+	if data, exists := os.LookupEnv(backendLimitsEnv); exists {
+		launchBackend(data)
+	}
+}
+
+// launchBackend runs in the process started for a backend, before the backend itself: it applies the limits
+// to the process and executes the backend in its place, so the limits are inherited by the processes it starts
+func launchBackend(data string) {
+	err := func() error {
+		limits := launchLimits{}
+		if err := json.Unmarshal([]byte(data), &limits); err != nil {
+			return err
+		}
+		if len(os.Args) < 2 {
+			return errors.New("no backend to run")
+		}
+
+		if limits.Cgroup != "" {
+			if err := os.WriteFile(filepath.Join(limits.Cgroup, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+				return fmt.Errorf("failed to limit the memory of the backend: %w", err)
+			}
+		}
+		if limits.MaxOpenFiles > 0 {
+			if err := unix.Setrlimit(unix.RLIMIT_NOFILE, &unix.Rlimit{Cur: limits.MaxOpenFiles, Max: limits.MaxOpenFiles}); err != nil {
+				return fmt.Errorf("failed to limit the open files of the backend: %w", err)
+			}
+		}
+
+		// the affinity and the nice level are set per thread: the backend gets the ones of the thread executing it
+		runtime.LockOSThread()
+		if len(limits.CPUs) > 0 {
+			var set unix.CPUSet
+			for _, cpu := range limits.CPUs {
+				set.Set(cpu)
+			}
+			if err := unix.SchedSetaffinity(0, &set); err != nil {
+				return fmt.Errorf("failed to set the CPUs of the backend: %w", err)
+			}
+		}
+		if limits.Nice != 0 {
+			if err := unix.Setpriority(unix.PRIO_PROCESS, 0, limits.Nice); err != nil {
+				return fmt.Errorf("failed to set the nice level of the backend: %w", err)
+			}
+		}
+
+		env := slices.DeleteFunc(os.Environ(), func(e string) bool { return strings.HasPrefix(e, backendLimitsEnv+"=") })
+		return unix.Exec(os.Args[1], os.Args[1:], env)
+	}()
+	fmt.Fprintf(os.Stderr, "failed to start the backend: %s\n", err)
+	os.Exit(1)
+}
+
+// backendLauncher returns the limits to apply with launchBackend to the backend process of a model.
+// The memory is capped with a cgroup v2, it is not capped if cgroup v2 is not available.
+func (ml *ModelLoader) backendLauncher(modelID string, limits ProcessLimits) (string, error) {
+	if limits.IsZero() {
+		return "", nil
+	}
+
+	l := log.Info().Str("model", modelID)
+	launch := launchLimits{
+		MaxOpenFiles: limits.MaxOpenFiles,
+		CPUs:         limits.CPUs,
+		Nice:         limits.Nice,
+	}
+
+	if limits.Memory > 0 {
+		cg, err := ml.memoryCgroup(modelID, limits.Memory)
+		if err == nil {
+			ml.cgroups.set(modelID, cg)
+			launch.Cgroup = cg.path
+			l = l.Str("memory", fmt.Sprintf("%d bytes (cgroup %s)", limits.Memory, cg.path))
+		} else {
+			log.Warn().Err(err).Str("model", modelID).Msg("cgroup v2 is not available, the memory of the backend is not limited")
+		}
+	}
+	if limits.MaxOpenFiles > 0 {
+		l = l.Uint64("max_open_files", limits.MaxOpenFiles)
+	}
+	if len(limits.CPUs) > 0 {
+		l = l.Ints("cpus", limits.CPUs)
+	}
+	if limits.Nice != 0 {
+		l = l.Int("nice", limits.Nice)
+	}
+
+	data, err := json.Marshal(launch)
+	if err != nil {
+		return "", err
+	}
+	l.Msg("starting the backend with resource limits")
+	return string(data), nil
+}
+
+// memoryCgroup creates the cgroup capping the memory of the backend of a model, which joins it once started
+func (ml *ModelLoader) memoryCgroup(modelID string, memory uint64) (cgroup, error) {
+	ml.cgroups.once.Do(func() {
+		ml.cgroups.root, ml.cgroups.rootErr = setupCgroupRoot()
+	})
+	if ml.cgroups.rootErr != nil {
+		return cgroup{}, ml.cgroups.rootErr
+	}
+
+	cg := cgroup{path: filepath.Join(ml.cgroups.root, "backend-"+invalidCgroupChars.ReplaceAllString(modelID, "_"))}
+	if err := os.Mkdir(cg.path, 0755); err != nil && !os.IsExist(err) {
+		return cgroup{}, err
+	}
+	// the cgroup can be left from a previous run of the backend, its OOM kills must not be counted again
+	cg.oomKills = oomKills(cg.path)
+	if err := os.WriteFile(filepath.Join(cg.path, "memory.max"), []byte(strconv.FormatUint(memory, 10)), 0644); err != nil {
+		return cgroup{}, err
+	}
+	return cg, nil
+}
+
+// setupCgroupRoot returns the cgroup of LocalAI, with the memory controller enabled for its children.
+// The controllers can be enabled only in a cgroup without processes, so LocalAI is moved in a child
+// cgroup of its own if needed.
+func setupCgroupRoot() (string, error) {
+	if _, err := os.Stat(filepath.Join(cgroupMountPoint, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not mounted: %w", err)
+	}
+
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var root string
+	for _, line := range strings.Split(string(data), "\n") {
+		if path, found := strings.CutPrefix(line, "0::"); found {
+			root = filepath.Join(cgroupMountPoint, path)
+		}
+	}
+	if root == "" {
+		return "", fmt.Errorf("the cgroup of LocalAI was not found")
+	}
+
+	enable := func() error {
+		return os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+memory"), 0644)
+	}
+	err = enable()
+	if errors.Is(err, unix.EBUSY) {
+		self := filepath.Join(root, "localai")
+		if err := os.Mkdir(self, 0755); err != nil && !os.IsExist(err) {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(self, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+			return "", err
+		}
+		err = enable()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to enable the memory controller in %s: %w", root, err)
+	}
+	return root, nil
+}
+
+// oomKilled returns true if the backend of the model was killed for exceeding its memory limit
+func (ml *ModelLoader) oomKilled(modelID string) bool {
+	cg, exists := ml.cgroups.get(modelID)
+	return exists && oomKills(cg.path) > cg.oomKills
+}
+
+// removeCgroup removes the cgroup of the backend of a model, once it is stopped
+func (ml *ModelLoader) removeCgroup(modelID string) {
+	cg, exists := ml.cgroups.remove(modelID)
+	if !exists {
+		return
+	}
+	if err := os.Remove(cg.path); err != nil {
+		log.Debug().Err(err).Str("model", modelID).Msgf("failed to remove the cgroup %s", cg.path)
+	}
+}
+
+// oomKills returns the count of processes of a cgroup killed for exceeding the memory limit
+func oomKills(path string) int {
+	f, err := os.Open(filepath.Join(path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if count, found := strings.CutPrefix(scanner.Text(), "oom_kill "); found {
+			n, _ := strconv.Atoi(count)
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+// +build !linux
+
+package model
+
+import (
+	"github.com/rs/zerolog/log"
+)
+
+// backendLauncher is only supported on Linux, the limits are ignored elsewhere
+func (ml *ModelLoader) backendLauncher(modelID string, limits ProcessLimits) (string, error) {
+	if !limits.IsZero() {
+		log.Warn().Str("model", modelID).Msg("backend resource limits are only supported on Linux, ignoring them")
+	}
+	return "", nil
+}
+
+func (ml *ModelLoader) oomKilled(modelID string) bool {
+	return false
+}
+
+func (ml *ModelLoader) removeCgroup(modelID string) {}
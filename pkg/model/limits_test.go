@@ -0,0 +1,70 @@
+package model_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Process limits", func() {
+	It("parses cpusets", func() {
+		cpus, err := model.ParseCPUSet("0-3, 8,2")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpus).To(Equal([]int{0, 1, 2, 3, 8}))
+
+		cpus, err = model.ParseCPUSet("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cpus).To(BeEmpty())
+
+		for _, invalid := range []string{"a", "3-1", "-1", "0-b"} {
+			_, err := model.ParseCPUSet(invalid)
+			Expect(err).To(HaveOccurred(), invalid)
+		}
+	})
+
+	It("reports whether limits are set", func() {
+		Expect(model.ProcessLimits{}.IsZero()).To(BeTrue())
+		Expect(model.ProcessLimits{Nice: 5}.IsZero()).To(BeFalse())
+	})
+
+	It("applies the limits to the backend and to the processes it starts", func() {
+		if runtime.GOOS != "linux" {
+			Skip("the limits are only supported on Linux")
+		}
+		assetDir, err := os.MkdirTemp("", "limits")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(assetDir)
+
+		// the backend reports the limits of a child process, then exits
+		output := filepath.Join(assetDir, "limits.txt")
+		script := "#!/bin/sh\nsh -c 'ulimit -n; nice; grep Cpus_allowed_list /proc/self/status; env' > " + output + "\nexit 1\n"
+		Expect(os.WriteFile(filepath.Join(assetDir, "limits.sh"), []byte(script), 0755)).To(Succeed())
+
+		modelLoader := model.NewModelLoader(assetDir)
+		_, err = modelLoader.Load(
+			model.WithExternalBackend("limits", filepath.Join(assetDir, "limits.sh")),
+			model.WithBackendString("limits"),
+			model.WithModel("foo"),
+			model.WithModelID("foo"),
+			model.WithAssetDir(assetDir),
+			model.WithGRPCAttempts(1),
+			// gives the time to the backend to report the limits before it is stopped
+			model.WithGRPCAttemptsDelay(1),
+			model.WithProcessLimits(model.ProcessLimits{CPUs: []int{0}, Nice: 5, MaxOpenFiles: 128}),
+		)
+		Expect(err).To(HaveOccurred())
+
+		data, err := os.ReadFile(output)
+		Expect(err).ToNot(HaveOccurred())
+		lines := strings.Split(string(data), "\n")
+		Expect(lines[0]).To(Equal("128"))
+		Expect(lines[1]).To(Equal("5"))
+		Expect(lines[2]).To(MatchRegexp(`^Cpus_allowed_list:\s+0$`))
+		Expect(string(data)).ToNot(ContainSubstring("LOCALAI_BACKEND_LIMITS"))
+	})
+})
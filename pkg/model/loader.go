@@ -22,17 +22,36 @@ type ModelLoader struct {
 	mu        sync.Mutex
 	models    map[string]*Model
 	wd        *WatchDog
+
+	memoryBudget uint64
+
+	queues map[string]*Queue
+
+	crashes crashes
+
+	pins pins
+
+	cgroups cgroups
+
+	noStopOnSignal bool
 }
 
 func NewModelLoader(modelPath string) *ModelLoader {
 	nml := &ModelLoader{
 		ModelPath: modelPath,
 		models:    make(map[string]*Model),
+		queues:    make(map[string]*Queue),
 	}
 
 	return nml
 }
 
+// DisableStopOnSignal keeps the backends running when the process gets SIGINT or SIGTERM,
+// they are stopped by StopAllGRPC instead, e.g. once the in-flight requests are completed
+func (ml *ModelLoader) DisableStopOnSignal() {
+	ml.noStopOnSignal = true
+}
+
 func (ml *ModelLoader) SetWatchDog(wd *WatchDog) {
 	ml.wd = wd
 }
@@ -123,8 +142,32 @@ func (ml *ModelLoader) LoadModel(modelID, modelName string, loader func(string,
 	modelFile := filepath.Join(ml.ModelPath, modelName)
 	log.Debug().Msgf("Loading model in memory from file: %s", modelFile)
 
+	model, err := ml.loadModel(modelID, modelName, modelFile, loader)
+	if err != nil {
+		return nil, err
+	}
+
+	// the size is measured once the lock is released, as it calls the backend
+	if ml.memoryBudget > 0 {
+		size := ml.measureSize(model)
+		ml.mu.Lock()
+		model.size = size
+		ml.mu.Unlock()
+	}
+
+	return model, nil
+}
+
+// loadModel loads the model with ml.mu held, evicting the models exceeding the memory budget first
+func (ml *ModelLoader) loadModel(modelID, modelName, modelFile string, loader func(string, string, string) (*Model, error)) (*Model, error) {
 	ml.mu.Lock()
 	defer ml.mu.Unlock()
+
+	size := fileSize(modelFile)
+	if ml.memoryBudget > 0 {
+		ml.evictModels(modelID, size)
+	}
+
 	model, err := loader(modelID, modelName, modelFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load model with internal loader: %s", err)
@@ -134,6 +177,9 @@ func (ml *ModelLoader) LoadModel(modelID, modelName string, loader func(string,
 		return nil, fmt.Errorf("loader didn't return a model")
 	}
 
+	model.lastUsed = time.Now()
+	model.fileSize = size
+	model.size = size
 	ml.models[modelID] = model
 
 	return model, nil
@@ -175,6 +221,7 @@ func (ml *ModelLoader) CheckIsLoaded(s string) *Model {
 	}
 
 	log.Debug().Msgf("Model already loaded in memory: %s", s)
+	m.lastUsed = time.Now()
 	client := m.GRPC(false, ml.wd)
 
 	log.Debug().Msgf("Checking model availability (%s)", s)
@@ -192,6 +239,14 @@ func (ml *ModelLoader) CheckIsLoaded(s string) *Model {
 		}
 		if !process.IsAlive() {
 			log.Debug().Msgf("GRPC Process is not responding: %s", s)
+			crash := fmt.Errorf("backend process exited")
+			if exitCode, err := process.ExitCode(); err == nil {
+				crash = fmt.Errorf("backend process exited with code %s", strings.TrimSpace(exitCode))
+			}
+			if ml.oomKilled(s) {
+				crash = fmt.Errorf("backend process was killed for exceeding its memory limit")
+			}
+			ml.recordCrash(s, crash, stderrTail(process))
 			// stop and delete the process, this forces to re-load the model and re-create again the service
 			err := ml.deleteProcess(s)
 			if err != nil {
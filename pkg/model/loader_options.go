@@ -21,6 +21,11 @@ type Options struct {
 	grpcAttemptsDelay   int
 	singleActiveBackend bool
 	parallelRequests    bool
+
+	queue           QueueOptions
+	pinned          bool
+	limits          ProcessLimits
+	noCrashTracking bool
 }
 
 type Option func(*Options)
@@ -86,6 +91,34 @@ func WithSingleActiveBackend() Option {
 	}
 }
 
+// WithQueue limits the requests that the model serves concurrently
+func WithQueue(queue QueueOptions) Option {
+	return func(o *Options) {
+		o.queue = queue
+	}
+}
+
+// WithPinned keeps the model loaded, see ModelLoader.Pin
+func WithPinned() Option {
+	return func(o *Options) {
+		o.pinned = true
+	}
+}
+
+// WithProcessLimits caps the resources used by the backend process of the model
+func WithProcessLimits(limits ProcessLimits) Option {
+	return func(o *Options) {
+		o.limits = limits
+	}
+}
+
+// WithoutCrashTracking doesn't record the failures of the backend of the model as crashes
+func WithoutCrashTracking() Option {
+	return func(o *Options) {
+		o.noCrashTracking = true
+	}
+}
+
 func WithModelID(id string) Option {
 	return func(o *Options) {
 		o.modelID = id
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const statusTimeout = 5 * time.Second
+
+// SetMemoryBudget sets the RAM (in bytes) that the loaded models can use, the VRAM is not reported by the
+// backends and is not considered. When loading a model
+// would exceed the budget, the least recently used idle models are stopped first.
+// A budget of 0 disables the eviction.
+func (ml *ModelLoader) SetMemoryBudget(budget uint64) {
+	ml.memoryBudget = budget
+}
+
+// fileSize returns the size of the model file, or 0 if it is not a regular file
+// (e.g. models pulled by the backend itself)
+func fileSize(modelFile string) uint64 {
+	info, err := os.Stat(modelFile)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return uint64(info.Size())
+}
+
+// measureSize returns the memory used by a model as reported by its backend, once loaded,
+// the size of the model file is used if the backend reports less (or nothing at all).
+// It is measured before the request loading the model is served, without holding ml.mu: reading the status
+// of a backend waits for its running requests, and would mark it as idle when they run in parallel.
+func (ml *ModelLoader) measureSize(m *Model) uint64 {
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+
+	status, err := m.GRPC(false, ml.wd).Status(ctx)
+	if err != nil || status.GetMemory() == nil {
+		return m.fileSize
+	}
+
+	// the total is the virtual memory of the process, the resident set is closer to what the model uses
+	reported := status.GetMemory().GetTotal()
+	if rss, ok := status.GetMemory().GetBreakdown()["gopsutil-RSS"]; ok {
+		reported = rss
+	}
+	return max(reported, m.fileSize)
+}
+
+// evictModels stops the least recently used idle models until a model of the given size
+// fits in the memory budget. Busy and pinned models are never stopped. It must be called with ml.mu held,
+// and doesn't call the backends: the sizes of the models are the ones measured when they were loaded.
+func (ml *ModelLoader) evictModels(modelID string, size uint64) {
+	type loaded struct {
+		id   string
+		size uint64
+		m    *Model
+	}
+
+	var used uint64
+	candidates := []loaded{}
+	for id, m := range ml.models {
+		if id == modelID {
+			continue
+		}
+		used += m.size
+		candidates = append(candidates, loaded{id: id, size: m.size, m: m})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].m.lastUsed.Before(candidates[j].m.lastUsed)
+	})
+
+	for _, c := range candidates {
+		if used+size <= ml.memoryBudget {
+			return
+		}
+		if ml.IsPinned(c.id) {
+			log.Debug().Msgf("Model '%s' is pinned, not evicting it", c.id)
+			continue
+		}
+		if c.m.GRPC(false, ml.wd).IsBusy() {
+			log.Debug().Msgf("Model '%s' is busy, not evicting it", c.id)
+			continue
+		}
+
+		log.Info().Msgf("Evicting model '%s' (%d bytes) to fit '%s' (%d bytes) in the memory budget", c.id, c.size, modelID, size)
+		if err := ml.deleteProcess(c.id); err != nil {
+			log.Error().Err(err).Str("model", c.id).Msg("error while evicting model")
+			continue
+		}
+		used -= c.size
+	}
+
+	if used+size > ml.memoryBudget {
+		log.Warn().Msgf("Loading model '%s' exceeds the memory budget (%d bytes used, %d bytes needed, %d bytes budget)", modelID, used, size, ml.memoryBudget)
+	}
+}
@@ -0,0 +1,87 @@
+package model_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Memory budget", func() {
+	var (
+		modelLoader *model.ModelLoader
+		modelPath   string
+	)
+
+	// loadModel loads a model whose file has the given size
+	loadModel := func(id string, size int64) {
+		modelFile := filepath.Join(modelPath, id+".gguf")
+		Expect(os.WriteFile(modelFile, nil, 0644)).To(Succeed())
+		Expect(os.Truncate(modelFile, size)).To(Succeed())
+
+		_, err := modelLoader.LoadModel(id, id+".gguf", func(modelID, modelName, modelFile string) (*model.Model, error) {
+			return model.NewModel(modelID, "127.0.0.1:1", nil), nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	loadedModels := func() []string {
+		ids := []string{}
+		for _, m := range modelLoader.ListModels() {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	BeforeEach(func() {
+		var err error
+		modelPath, err = os.MkdirTemp("", "memory_budget")
+		Expect(err).ToNot(HaveOccurred())
+		modelLoader = model.NewModelLoader(modelPath)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(modelPath)
+	})
+
+	It("keeps all the models without a budget", func() {
+		loadModel("a", 1000)
+		loadModel("b", 1000)
+		loadModel("c", 1000)
+		Expect(loadedModels()).To(ConsistOf("a", "b", "c"))
+	})
+
+	It("evicts the least recently used models when the budget is exceeded", func() {
+		modelLoader.SetMemoryBudget(2500)
+
+		loadModel("a", 1000)
+		loadModel("b", 1000)
+		// a is used again, so b becomes the least recently used
+		Expect(modelLoader.CheckIsLoaded("a")).ToNot(BeNil())
+
+		loadModel("c", 1000)
+		Expect(loadedModels()).To(ConsistOf("a", "c"))
+
+		loadModel("d", 2000)
+		Expect(loadedModels()).To(ConsistOf("d"))
+	})
+
+	It("never evicts the pinned models", func() {
+		modelLoader.SetMemoryBudget(2500)
+		modelLoader.Pin("a")
+
+		loadModel("a", 1000)
+		loadModel("b", 1000)
+		loadModel("c", 1000)
+		Expect(loadedModels()).To(ConsistOf("a", "c"))
+	})
+
+	It("loads the model anyway if it doesn't fit in the budget", func() {
+		modelLoader.SetMemoryBudget(500)
+
+		loadModel("a", 1000)
+		Expect(loadedModels()).To(ConsistOf("a"))
+	})
+})
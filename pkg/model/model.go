@@ -2,6 +2,7 @@ package model
 
 import (
 	"sync"
+	"time"
 
 	grpc "github.com/mudler/LocalAI/pkg/grpc"
 	process "github.com/mudler/go-processmanager"
@@ -13,6 +14,12 @@ type Model struct {
 	client  grpc.Backend
 	process *process.Process
 	sync.Mutex
+
+	// lastUsed and size are used to pick the models to evict under a memory budget,
+	// size is the memory used by the model measured once loaded, and at least the size of its file
+	lastUsed time.Time
+	fileSize uint64
+	size     uint64
 }
 
 func NewModel(ID, address string, process *process.Process) *Model {
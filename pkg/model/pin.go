@@ -0,0 +1,86 @@
+package model
+
+import (
+	"slices"
+	"sync"
+)
+
+// pins tracks the models which must stay loaded: they are neither stopped by the watchdog,
+// nor evicted to load other models
+type pins struct {
+	sync.Mutex
+	// models pinned by their configuration, when they are loaded
+	config map[string]bool
+	// models pinned or unpinned at runtime, overriding their configuration
+	runtime map[string]bool
+}
+
+// Pin keeps a model loaded once it is loaded, even if it is idle or another model needs its memory
+func (ml *ModelLoader) Pin(modelID string) {
+	ml.pins.set(modelID, true)
+}
+
+// Unpin lets a model be stopped again, even if it is pinned by its configuration
+func (ml *ModelLoader) Unpin(modelID string) {
+	ml.pins.set(modelID, false)
+}
+
+// IsPinned returns true if the model must stay loaded
+func (ml *ModelLoader) IsPinned(modelID string) bool {
+	ml.pins.Lock()
+	defer ml.pins.Unlock()
+	return ml.pins.pinned(modelID)
+}
+
+// PinnedModels returns the models which must stay loaded
+func (ml *ModelLoader) PinnedModels() []string {
+	ml.pins.Lock()
+	defer ml.pins.Unlock()
+
+	models := []string{}
+	for id := range ml.pins.config {
+		if ml.pins.pinned(id) {
+			models = append(models, id)
+		}
+	}
+	for id := range ml.pins.runtime {
+		if ml.pins.pinned(id) && !slices.Contains(models, id) {
+			models = append(models, id)
+		}
+	}
+	slices.Sort(models)
+	return models
+}
+
+// pinByConfig records whether the configuration of a model, being loaded, pins it
+func (ml *ModelLoader) pinByConfig(modelID string, pinned bool) {
+	ml.pins.Lock()
+	defer ml.pins.Unlock()
+
+	if !pinned {
+		delete(ml.pins.config, modelID)
+		return
+	}
+	if ml.pins.config == nil {
+		ml.pins.config = make(map[string]bool)
+	}
+	ml.pins.config[modelID] = true
+}
+
+func (p *pins) set(modelID string, pinned bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.runtime == nil {
+		p.runtime = make(map[string]bool)
+	}
+	p.runtime[modelID] = pinned
+}
+
+// pinned must be called with the lock held
+func (p *pins) pinned(modelID string) bool {
+	if pinned, exists := p.runtime[modelID]; exists {
+		return pinned
+	}
+	return p.config[modelID]
+}
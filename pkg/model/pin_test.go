@@ -0,0 +1,29 @@
+package model_test
+
+import (
+	"github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pinned models", func() {
+	var modelLoader *model.ModelLoader
+
+	BeforeEach(func() {
+		modelLoader = model.NewModelLoader("")
+	})
+
+	It("pins and unpins models at runtime", func() {
+		Expect(modelLoader.IsPinned("a")).To(BeFalse())
+		Expect(modelLoader.PinnedModels()).To(BeEmpty())
+
+		modelLoader.Pin("b")
+		modelLoader.Pin("a")
+		Expect(modelLoader.IsPinned("a")).To(BeTrue())
+		Expect(modelLoader.PinnedModels()).To(Equal([]string{"a", "b"}))
+
+		modelLoader.Unpin("b")
+		Expect(modelLoader.IsPinned("b")).To(BeFalse())
+		Expect(modelLoader.PinnedModels()).To(Equal([]string{"a"}))
+	})
+})
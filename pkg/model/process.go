@@ -38,6 +38,7 @@ func (ml *ModelLoader) deleteProcess(s string) error {
 	if err != nil {
 		log.Error().Err(err).Msgf("(deleteProcess) error while deleting process %s", s)
 	}
+	ml.removeCgroup(s)
 
 	return err
 }
@@ -70,7 +71,21 @@ func (ml *ModelLoader) GetGRPCPID(id string) (int, error) {
 	return strconv.Atoi(p.Process().PID)
 }
 
-func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string, args ...string) (*process.Process, error) {
+// stopFailedBackend stops the backend process which failed loading a model, and records its failure.
+// The error is the one of the failure, reporting if the backend was killed for exceeding its memory limit.
+func (ml *ModelLoader) stopFailedBackend(modelID string, p *process.Process, err error) error {
+	if ml.oomKilled(modelID) {
+		err = fmt.Errorf("backend process was killed for exceeding its memory limit: %w", err)
+	}
+	ml.recordBackendFailure(modelID, p)
+	if err := p.Stop(); err != nil {
+		log.Error().Err(err).Msg("error while shutting down grpc process")
+	}
+	ml.removeCgroup(modelID)
+	return err
+}
+
+func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string, limits ProcessLimits, args ...string) (*process.Process, error) {
 	// Make sure the process is executable
 	if err := os.Chmod(grpcProcess, 0700); err != nil {
 		return nil, err
@@ -85,11 +100,31 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 		return nil, err
 	}
 
+	name := filepath.Base(grpcProcess)
+	args = append(args, []string{"--addr", serverAddress}...)
+	env := os.Environ()
+
+	// the limits are applied by LocalAI itself, started in the process of the backend before executing it,
+	// so the processes started by the backend (e.g. by run.sh) can't escape them
+	launcher, err := ml.backendLauncher(id, limits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply the resource limits of %s: %w", id, err)
+	}
+	if launcher != "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply the resource limits of %s: %w", id, err)
+		}
+		args = append([]string{name}, args...)
+		name = executable
+		env = append(env, backendLimitsEnv+"="+launcher)
+	}
+
 	grpcControlProcess := process.New(
 		process.WithTemporaryStateDir(),
-		process.WithName(filepath.Base(grpcProcess)),
-		process.WithArgs(append(args, []string{"--addr", serverAddress}...)...),
-		process.WithEnvironment(os.Environ()...),
+		process.WithName(name),
+		process.WithArgs(args...),
+		process.WithEnvironment(env...),
 		process.WithWorkDir(workDir),
 	)
 
@@ -99,20 +134,23 @@ func (ml *ModelLoader) startProcess(grpcProcess, id string, serverAddress string
 	}
 
 	if err := grpcControlProcess.Run(); err != nil {
+		ml.removeCgroup(id)
 		return grpcControlProcess, err
 	}
 
 	log.Debug().Msgf("GRPC Service state dir: %s", grpcControlProcess.StateDir())
 	// clean up process
-	go func() {
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-		<-c
-		err := grpcControlProcess.Stop()
-		if err != nil {
-			log.Error().Err(err).Msg("error while shutting down grpc process")
-		}
-	}()
+	if !ml.noStopOnSignal {
+		go func() {
+			c := make(chan os.Signal, 1)
+			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+			<-c
+			err := grpcControlProcess.Stop()
+			if err != nil {
+				log.Error().Err(err).Msg("error while shutting down grpc process")
+			}
+		}()
+	}
 
 	go func() {
 		t, err := tail.TailFile(grpcControlProcess.StderrPath(), tail.Config{Follow: true})
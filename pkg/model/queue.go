@@ -0,0 +1,212 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/grpc"
+)
+
+var (
+	// ErrQueueFull is returned when the queue of a model has reached its maximum length
+	ErrQueueFull = errors.New("too many requests queued for the model")
+	// ErrQueueTimeout is returned when a request waited in the queue longer than the queue timeout
+	ErrQueueTimeout = errors.New("timed out waiting in the model queue")
+)
+
+// QueueOptions limits the requests that a model serves concurrently
+type QueueOptions struct {
+	// MaxConcurrency is the number of requests served at the same time, 0 disables the queue
+	MaxConcurrency int
+	// MaxLength is the number of requests that can wait, 0 means no limit
+	MaxLength int
+	// Timeout is how long a request can wait, 0 means no limit
+	Timeout time.Duration
+}
+
+// QueueStatus reports the requests served and waiting in a model queue
+type QueueStatus struct {
+	Running        int `json:"running"`
+	Waiting        int `json:"waiting"`
+	MaxConcurrency int `json:"max_concurrency"`
+	MaxLength      int `json:"max_length,omitempty"`
+}
+
+type priorityKeyType string
+
+const priorityKey priorityKeyType = "priority"
+
+// WithPriority returns a context carrying the priority of a request.
+// Requests with a higher priority are served first, the default priority is 0.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey, priority)
+}
+
+// PriorityFromContext returns the priority of a request
+func PriorityFromContext(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	priority, _ := ctx.Value(priorityKey).(int)
+	return priority
+}
+
+// Queue limits the concurrent requests to a model. Waiting requests are served
+// by priority, and in arrival order within the same priority.
+type Queue struct {
+	sync.Mutex
+	options QueueOptions
+	running int
+	waiting []*queueItem
+}
+
+type queueItem struct {
+	priority int
+	ready    chan struct{}
+	granted  bool
+}
+
+func NewQueue(options QueueOptions) *Queue {
+	return &Queue{options: options}
+}
+
+func (q *Queue) setOptions(options QueueOptions) {
+	q.Lock()
+	defer q.Unlock()
+	q.options = options
+	// more requests might be served with the new limit
+	q.dispatch()
+}
+
+// Status returns the requests served and waiting
+func (q *Queue) Status() QueueStatus {
+	q.Lock()
+	defer q.Unlock()
+	return QueueStatus{
+		Running:        q.running,
+		Waiting:        len(q.waiting),
+		MaxConcurrency: q.options.MaxConcurrency,
+		MaxLength:      q.options.MaxLength,
+	}
+}
+
+// Acquire waits until the request can be served, the returned function must be called when it is done
+func (q *Queue) Acquire(ctx context.Context) (func(), error) {
+	q.Lock()
+	if len(q.waiting) == 0 && q.running < q.options.MaxConcurrency {
+		q.running++
+		q.Unlock()
+		return q.release, nil
+	}
+	if q.options.MaxLength > 0 && len(q.waiting) >= q.options.MaxLength {
+		q.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	item := &queueItem{priority: PriorityFromContext(ctx), ready: make(chan struct{})}
+	i := len(q.waiting)
+	for i > 0 && q.waiting[i-1].priority < item.priority {
+		i--
+	}
+	q.waiting = append(q.waiting[:i], append([]*queueItem{item}, q.waiting[i:]...)...)
+	timeout := q.options.Timeout
+	q.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case <-item.ready:
+		return q.release, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-expired:
+		err = ErrQueueTimeout
+	}
+
+	q.Lock()
+	defer q.Unlock()
+	if item.granted {
+		// served while giving up, pass the turn to the next request
+		q.running--
+		q.dispatch()
+		return nil, err
+	}
+	for j, w := range q.waiting {
+		if w == item {
+			q.waiting = append(q.waiting[:j], q.waiting[j+1:]...)
+			break
+		}
+	}
+	return nil, err
+}
+
+func (q *Queue) release() {
+	q.Lock()
+	defer q.Unlock()
+	q.running--
+	q.dispatch()
+}
+
+// dispatch serves the waiting requests while there is room, it must be called with the lock held
+func (q *Queue) dispatch() {
+	for len(q.waiting) > 0 && q.running < q.options.MaxConcurrency {
+		item := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		item.granted = true
+		q.running++
+		close(item.ready)
+	}
+}
+
+// queued puts the model queue in front of the backend, if the model limits its concurrent requests
+func (ml *ModelLoader) queued(modelID string, options QueueOptions, backend grpc.Backend) grpc.Backend {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	q, exists := ml.queues[modelID]
+	if options.MaxConcurrency <= 0 {
+		// requests already queued are still served with the previous limits
+		delete(ml.queues, modelID)
+		return backend
+	}
+
+	if exists {
+		q.setOptions(options)
+	} else {
+		q = NewQueue(options)
+		ml.queues[modelID] = q
+	}
+	return &queuedBackend{Backend: backend, queue: q}
+}
+
+// QueueStatus returns the status of the queue of a model, if the model limits its concurrent requests
+func (ml *ModelLoader) QueueStatus(modelID string) (QueueStatus, bool) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	q, exists := ml.queues[modelID]
+	if !exists {
+		return QueueStatus{}, false
+	}
+	return q.Status(), true
+}
+
+// Available returns an error if the model can't take a request right now, because its backend is crashing or
+// its queue is full. The streamed requests check it before their response starts, as their status can't change afterwards.
+func (ml *ModelLoader) Available(modelID string) error {
+	if err := ml.checkCrashes(modelID); err != nil {
+		return err
+	}
+	if queue, exists := ml.QueueStatus(modelID); exists && queue.MaxLength > 0 && queue.Waiting >= queue.MaxLength {
+		return ErrQueueFull
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package model_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/mudler/LocalAI/pkg/model"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Queue", func() {
+	// wait queues a request in the background, returning the channel where its release function is sent once served
+	wait := func(q *model.Queue, priority int) chan func() {
+		served := make(chan func(), 1)
+		go func() {
+			defer GinkgoRecover()
+			release, err := q.Acquire(model.WithPriority(context.Background(), priority))
+			Expect(err).ToNot(HaveOccurred())
+			served <- release
+		}()
+		return served
+	}
+
+	It("limits the concurrent requests", func() {
+		q := model.NewQueue(model.QueueOptions{MaxConcurrency: 2})
+
+		release1, err := q.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		_, err = q.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		served := wait(q, 0)
+		Eventually(q.Status).Should(Equal(model.QueueStatus{Running: 2, Waiting: 1, MaxConcurrency: 2}))
+		Consistently(served).ShouldNot(Receive())
+
+		release1()
+		Eventually(served).Should(Receive())
+		Expect(q.Status()).To(Equal(model.QueueStatus{Running: 2, Waiting: 0, MaxConcurrency: 2}))
+	})
+
+	It("serves the requests with higher priority first", func() {
+		q := model.NewQueue(model.QueueOptions{MaxConcurrency: 1})
+
+		release, err := q.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		low := wait(q, -1)
+		Eventually(func() int { return q.Status().Waiting }).Should(Equal(1))
+		normal := wait(q, 0)
+		Eventually(func() int { return q.Status().Waiting }).Should(Equal(2))
+		high := wait(q, 1)
+		Eventually(func() int { return q.Status().Waiting }).Should(Equal(3))
+
+		release()
+		var next func()
+		Eventually(high).Should(Receive(&next))
+		Consistently(normal).ShouldNot(Receive())
+
+		next()
+		Eventually(normal).Should(Receive(&next))
+		Consistently(low).ShouldNot(Receive())
+
+		next()
+		Eventually(low).Should(Receive())
+	})
+
+	It("rejects the requests when the queue is full", func() {
+		q := model.NewQueue(model.QueueOptions{MaxConcurrency: 1, MaxLength: 1})
+
+		_, err := q.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		wait(q, 0)
+		Eventually(func() int { return q.Status().Waiting }).Should(Equal(1))
+
+		_, err = q.Acquire(context.Background())
+		Expect(err).To(MatchError(model.ErrQueueFull))
+	})
+
+	It("stops waiting after the timeout", func() {
+		q := model.NewQueue(model.QueueOptions{MaxConcurrency: 1, Timeout: 10 * time.Millisecond})
+
+		_, err := q.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = q.Acquire(context.Background())
+		Expect(err).To(MatchError(model.ErrQueueTimeout))
+		Expect(q.Status().Waiting).To(Equal(0))
+	})
+
+	It("stops waiting when the request is cancelled", func() {
+		q := model.NewQueue(model.QueueOptions{MaxConcurrency: 1})
+
+		release, err := q.Acquire(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = q.Acquire(ctx)
+		Expect(err).To(MatchError(context.Canceled))
+
+		release()
+		Expect(q.Status()).To(Equal(model.QueueStatus{MaxConcurrency: 1}))
+	})
+})
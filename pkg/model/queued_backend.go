@@ -0,0 +1,97 @@
+package model
+
+import (
+	"context"
+
+	"github.com/mudler/LocalAI/pkg/grpc"
+	pb "github.com/mudler/LocalAI/pkg/grpc/proto"
+	ggrpc "google.golang.org/grpc"
+)
+
+// queuedBackend waits for its turn in the model queue before running the inference calls.
+// Health checks, status and model loading are not queued.
+type queuedBackend struct {
+	grpc.Backend
+	queue *Queue
+}
+
+func (b *queuedBackend) Embeddings(ctx context.Context, in *pb.PredictOptions, opts ...ggrpc.CallOption) (*pb.EmbeddingResult, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.Embeddings(ctx, in, opts...)
+}
+
+func (b *queuedBackend) Predict(ctx context.Context, in *pb.PredictOptions, opts ...ggrpc.CallOption) (*pb.Reply, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.Predict(ctx, in, opts...)
+}
+
+func (b *queuedBackend) PredictStream(ctx context.Context, in *pb.PredictOptions, f func(reply *pb.Reply), opts ...ggrpc.CallOption) error {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return b.Backend.PredictStream(ctx, in, f, opts...)
+}
+
+func (b *queuedBackend) GenerateImage(ctx context.Context, in *pb.GenerateImageRequest, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.GenerateImage(ctx, in, opts...)
+}
+
+func (b *queuedBackend) TTS(ctx context.Context, in *pb.TTSRequest, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.TTS(ctx, in, opts...)
+}
+
+func (b *queuedBackend) SoundGeneration(ctx context.Context, in *pb.SoundGenerationRequest, opts ...ggrpc.CallOption) (*pb.Result, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.SoundGeneration(ctx, in, opts...)
+}
+
+func (b *queuedBackend) AudioTranscription(ctx context.Context, in *pb.TranscriptRequest, opts ...ggrpc.CallOption) (*pb.TranscriptResult, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.AudioTranscription(ctx, in, opts...)
+}
+
+func (b *queuedBackend) Rerank(ctx context.Context, in *pb.RerankRequest, opts ...ggrpc.CallOption) (*pb.RerankResult, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.Rerank(ctx, in, opts...)
+}
+
+func (b *queuedBackend) VAD(ctx context.Context, in *pb.VADRequest, opts ...ggrpc.CallOption) (*pb.VADResponse, error) {
+	release, err := b.queue.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return b.Backend.VAD(ctx, in, opts...)
+}
@@ -31,6 +31,8 @@ type WatchDog struct {
 
 type ProcessManager interface {
 	ShutdownModel(modelName string) error
+	// IsPinned returns true if the model must not be shut down
+	IsPinned(modelName string) bool
 }
 
 func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy, idle bool) *WatchDog {
@@ -44,6 +46,8 @@ func NewWatchDog(pm ProcessManager, timeoutBusy, timeoutIdle time.Duration, busy
 		busyCheck:       busy,
 		idleCheck:       idle,
 		addressModelMap: make(map[string]string),
+		// buffered, so Shutdown doesn't block if Run already returned
+		stop: make(chan bool, 1),
 	}
 }
 
@@ -109,8 +113,12 @@ func (wd *WatchDog) checkIdle() {
 	for address, t := range wd.idleTime {
 		log.Debug().Msgf("[WatchDog] %s: idle connection", address)
 		if time.Since(t) > wd.idletimeout {
-			log.Warn().Msgf("[WatchDog] Address %s is idle for too long, killing it", address)
 			model, ok := wd.addressModelMap[address]
+			if ok && wd.pm.IsPinned(model) {
+				log.Debug().Msgf("[WatchDog] Model %s is idle for too long, but it is pinned", model)
+				continue
+			}
+			log.Warn().Msgf("[WatchDog] Address %s is idle for too long, killing it", address)
 			if ok {
 				if err := wd.pm.ShutdownModel(model); err != nil {
 					log.Error().Err(err).Str("model", model).Msg("[watchdog] error shutting down model")
@@ -138,6 +146,10 @@ func (wd *WatchDog) checkBusy() {
 		if time.Since(t) > wd.timeout {
 
 			model, ok := wd.addressModelMap[address]
+			if ok && wd.pm.IsPinned(model) {
+				log.Warn().Msgf("[WatchDog] Model %s is busy for too long, but it is pinned", model)
+				continue
+			}
 			if ok {
 				log.Warn().Msgf("[WatchDog] Model %s is busy for too long, killing it", model)
 				if err := wd.pm.ShutdownModel(model); err != nil {
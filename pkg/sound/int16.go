@@ -0,0 +1,55 @@
+package sound
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// BytesToInt16sLE decodes little-endian 16-bit PCM samples, a trailing odd byte is ignored
+func BytesToInt16sLE(bytes []byte) []int16 {
+	samples := make([]int16, len(bytes)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(bytes[2*i:]))
+	}
+	return samples
+}
+
+// Int16toBytesLE encodes the samples as little-endian 16-bit PCM
+func Int16toBytesLE(samples []int16) []byte {
+	bytes := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(bytes[2*i:], uint16(s))
+	}
+	return bytes
+}
+
+// Int16ToFloat32 converts the samples to floats in the [-1, 1] range, as expected by the VAD models
+func Int16ToFloat32(samples []int16) []float32 {
+	floats := make([]float32, len(samples))
+	for i, s := range samples {
+		floats[i] = float32(s) / math.MaxInt16
+	}
+	return floats
+}
+
+// ResampleInt16 converts the samples from the input to the output sample rate with a linear interpolation
+func ResampleInt16(input []int16, inputRate, outputRate int) []int16 {
+	if inputRate == outputRate || len(input) == 0 {
+		return input
+	}
+
+	outputLength := int(int64(len(input)) * int64(outputRate) / int64(inputRate))
+	output := make([]int16, outputLength)
+	ratio := float64(inputRate) / float64(outputRate)
+	for i := range output {
+		pos := float64(i) * ratio
+		index := int(pos)
+		if index >= len(input)-1 {
+			output[i] = input[len(input)-1]
+			continue
+		}
+		frac := pos - float64(index)
+		output[i] = int16(math.Round(float64(input[index])*(1-frac) + float64(input[index+1])*frac))
+	}
+	return output
+}
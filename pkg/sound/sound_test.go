@@ -0,0 +1,34 @@
+package sound_test
+
+import (
+	"path/filepath"
+
+	. "github.com/mudler/LocalAI/pkg/sound"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("sound tests", func() {
+	It("encodes and decodes little-endian PCM", func() {
+		samples := []int16{0, 1, -1, 32767, -32768}
+		bytes := Int16toBytesLE(samples)
+		Expect(bytes).To(HaveLen(10))
+		Expect(bytes[:4]).To(Equal([]byte{0, 0, 1, 0}))
+		Expect(BytesToInt16sLE(bytes)).To(Equal(samples))
+	})
+	It("resamples with a linear interpolation", func() {
+		Expect(ResampleInt16([]int16{0, 100, 200, 300}, 16000, 32000)).To(Equal([]int16{0, 50, 100, 150, 200, 250, 300, 300}))
+		Expect(ResampleInt16([]int16{0, 100, 200, 300, 400, 500}, 24000, 16000)).To(Equal([]int16{0, 150, 300, 450}))
+		Expect(ResampleInt16([]int16{1, 2}, 16000, 16000)).To(Equal([]int16{1, 2}))
+	})
+	It("writes and reads wav files", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "test.wav")
+		samples := []int16{0, 1000, -1000, 32767, -32768}
+		Expect(WriteWavInt16(path, samples, 16000)).To(Succeed())
+
+		read, sampleRate, err := ReadWavInt16(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sampleRate).To(Equal(16000))
+		Expect(read).To(Equal(samples))
+	})
+})
@@ -0,0 +1,72 @@
+package sound
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// WriteWavInt16 writes mono 16-bit PCM samples to a wav file
+func WriteWavInt16(path string, samples []int16, sampleRate int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := make([]int, len(samples))
+	for i, s := range samples {
+		data[i] = int(s)
+	}
+
+	enc := wav.NewEncoder(f, sampleRate, 16, 1, 1)
+	if err := enc.Write(&audio.IntBuffer{
+		Format:         &audio.Format{NumChannels: 1, SampleRate: sampleRate},
+		Data:           data,
+		SourceBitDepth: 16,
+	}); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// ReadWavInt16 reads a PCM wav file as mono 16-bit samples (the first channel is kept),
+// returning the samples along with the sample rate of the file
+func ReadWavInt16(path string) ([]int16, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	dec := wav.NewDecoder(f)
+	if !dec.IsValidFile() {
+		return nil, 0, fmt.Errorf("invalid wav file: %s", path)
+	}
+	buf, err := dec.FullPCMBuffer()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	channels := buf.Format.NumChannels
+	if channels < 1 {
+		channels = 1
+	}
+	bitDepth := int(dec.BitDepth)
+
+	samples := make([]int16, 0, len(buf.Data)/channels)
+	for i := 0; i < len(buf.Data); i += channels {
+		v := buf.Data[i]
+		switch {
+		case bitDepth == 8:
+			// 8-bit PCM is unsigned
+			v = (v - 128) << 8
+		case bitDepth > 16:
+			v >>= bitDepth - 16
+		}
+		samples = append(samples, int16(v))
+	}
+	return samples, buf.Format.SampleRate, nil
+}
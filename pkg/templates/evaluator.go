@@ -18,6 +18,7 @@ type PromptTemplateData struct {
 	SystemPrompt         string
 	SuppressSystemPrompt bool // used by chat specifically to indicate that SystemPrompt above should be _ignored_
 	Input                string
+	Suffix               string // used by fill-in-the-middle completions
 	Instruction          string
 	Functions            []functions.Function
 	MessageIndex         int
@@ -41,6 +42,7 @@ const (
 	CompletionPromptTemplate
 	EditPromptTemplate
 	FunctionsPromptTemplate
+	FIMPromptTemplate
 )
 
 type Evaluator struct {
@@ -78,6 +80,9 @@ func (e *Evaluator) EvaluateTemplateForPrompt(templateType TemplateType, config
 		if config.TemplateConfig.Functions != "" {
 			template = config.TemplateConfig.Functions
 		}
+	case FIMPromptTemplate:
+		// the template associated to the model file is a completion template
+		template = config.TemplateConfig.FIM
 	}
 
 	if template == "" {
@@ -129,6 +134,7 @@ func (e *Evaluator) evaluateJinjaTemplateForPrompt(templateType TemplateType, te
 
 	conversation["system_prompt"] = in.SystemPrompt
 	conversation["content"] = in.Input
+	conversation["suffix"] = in.Suffix
 
 	return e.cache.evaluateJinjaTemplate(templateType, templateName, conversation)
 }
@@ -237,6 +237,30 @@ var _ = Describe("Templates", func() {
 			})
 		}
 	})
+	Context("fill-in-the-middle", func() {
+		var evaluator *Evaluator
+		BeforeEach(func() {
+			evaluator = NewEvaluator("")
+		})
+		It("renders the prefix and the suffix", func() {
+			cfg := config.BackendConfig{TemplateConfig: config.TemplateConfig{
+				Completion: "{{.Input}}",
+				FIM:        "<PRE> {{.Input}} <SUF>{{.Suffix}} <MID>",
+			}}
+			templated, err := evaluator.EvaluateTemplateForPrompt(FIMPromptTemplate, cfg, PromptTemplateData{Input: "def add(a, b):", Suffix: "\n    return c"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(templated).To(Equal("<PRE> def add(a, b): <SUF>\n    return c <MID>"))
+		})
+		It("renders jinja templates", func() {
+			cfg := config.BackendConfig{TemplateConfig: config.TemplateConfig{
+				FIM:           "<|fim_prefix|>{{ content }}<|fim_suffix|>{{ suffix }}<|fim_middle|>",
+				JinjaTemplate: true,
+			}}
+			templated, err := evaluator.EvaluateTemplateForPrompt(FIMPromptTemplate, cfg, PromptTemplateData{Input: "a", Suffix: "b"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(templated).To(Equal("<|fim_prefix|>a<|fim_suffix|>b<|fim_middle|>"))
+		})
+	})
 	Context("chat message jinja", func() {
 		var evaluator *Evaluator
 		BeforeEach(func() {